@@ -0,0 +1,308 @@
+// Package analytics derives statistics from the stored history of sources.
+//
+// The runtime estimates reported by a UPS are often optimistic as its
+// batteries age. An Analyzer finds discharges in the history of each source,
+// which are periods during which it ran on battery, and compares the rate at
+// which the charge actually fell with the rate implied by the UPS's own
+// estimate of its remaining runtime. The result is reported as a battery
+// health score along with an indicator that the batteries should be replaced
+// soon.
+//
+// Analysis requires the OnBattery, EstimatedChargeRemaining and
+// EstimatedMinutesRemaining statistics of a source to be recorded in the
+// history. OutputPercentLoad is used when it is available.
+//
+// Histories are analyzed in the background, so deriving statistics never
+// waits for the history to be read.
+package analytics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/historyrecipient"
+)
+
+// Derived statistics
+var (
+	BatteryHealth = power.Statistic{
+		Name:  "BatteryHealth",
+		Unit:  "%",
+		Class: power.ClassUPS,
+	}
+	ReplaceBatterySoon = power.Statistic{
+		Name:  "ReplaceBatterySoon",
+		Unit:  "yes/no",
+		Class: power.ClassUPS,
+		Enum: map[int]string{
+			0: "no",
+			1: "yes",
+		},
+	}
+)
+
+// Options holds the settings of an analyzer.
+type Options struct {
+	Lookback     time.Duration // Age of the oldest discharges that are considered
+	Interval     time.Duration // Minimum time between analyses of a source
+	MinDuration  time.Duration // Minimum duration of a usable discharge
+	MinDrop      float64       // Minimum fall in charge of a usable discharge, in percent
+	MinLoad      float64       // Minimum average load of a usable discharge, in percent
+	ReplaceBelow float64       // Health below which the batteries should be replaced
+}
+
+// DefaultOptions are the options used when none are provided.
+var DefaultOptions = Options{
+	Lookback:     30 * 24 * time.Hour,
+	Interval:     time.Hour,
+	MinDuration:  2 * time.Minute,
+	MinDrop:      5,
+	MinLoad:      5,
+	ReplaceBelow: 80,
+}
+
+// requestQueueSize is the number of sources that may wait to be analyzed.
+const requestQueueSize = 64
+
+// resolutions are the resolutions of a history, from finest to coarsest.
+var resolutions = []historyrecipient.Resolution{
+	historyrecipient.Raw,
+	historyrecipient.FiveMinutes,
+	historyrecipient.Hour,
+}
+
+// state holds the analysis of a single source.
+type state struct {
+	mu         sync.Mutex
+	analyzed   time.Time   // Time of the latest analysis request
+	resume     time.Time   // Start of the history that has yet to be analyzed
+	discharges []Discharge // Usable discharges in order of time
+	onBattery  bool        // Whether the source was on battery when last polled
+	refresh    bool        // Whether to analyze the source when next polled
+	requested  bool        // Whether an analysis is waiting or in progress
+}
+
+// Analyzer derives battery health statistics from a history. It is safe for
+// concurrent use.
+type Analyzer struct {
+	history *historyrecipient.History
+	options Options
+	now     func() time.Time // Source of the current time, replaced in tests
+
+	mu     sync.Mutex
+	states map[string]*state // Keyed by source key
+
+	requests  chan string // Keys of sources waiting to be analyzed
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns an analyzer of the given history. Zero options are replaced by
+// their defaults. The analyzer must be closed when it is no longer needed.
+func New(history *historyrecipient.History, options Options) *Analyzer {
+	if options.Lookback <= 0 {
+		options.Lookback = DefaultOptions.Lookback
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultOptions.Interval
+	}
+	if options.MinDuration <= 0 {
+		options.MinDuration = DefaultOptions.MinDuration
+	}
+	if options.MinDrop <= 0 {
+		options.MinDrop = DefaultOptions.MinDrop
+	}
+	if options.MinLoad <= 0 {
+		options.MinLoad = DefaultOptions.MinLoad
+	}
+	if options.ReplaceBelow <= 0 {
+		options.ReplaceBelow = DefaultOptions.ReplaceBelow
+	}
+	a := &Analyzer{
+		history:  history,
+		options:  options,
+		now:      time.Now,
+		states:   make(map[string]*state),
+		requests: make(chan string, requestQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Close stops the analysis of histories and waits for an analysis in
+// progress to finish.
+func (a *Analyzer) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.stop)
+	})
+	<-a.done
+	return nil
+}
+
+// Derive returns the derived statistics of source, given the values that
+// were just retrieved from it. It returns nothing until a usable discharge
+// has been found.
+//
+// Derive returns the score of the discharges found so far and doesn't wait
+// for the history to be analyzed. The history of a source is analyzed in the
+// background at most once per interval, and again after the source returns
+// from battery so that the discharge is accounted for promptly. As the values
+// of the current poll have yet to be recorded, that analysis is requested
+// when the source is next polled.
+func (a *Analyzer) Derive(source power.Source, values []power.Value) []power.Value {
+	name := source.Key()
+	st := a.state(name)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := a.now()
+	if !st.requested && (st.refresh || st.analyzed.IsZero() || now.Sub(st.analyzed) >= a.options.Interval) {
+		select {
+		case a.requests <- name:
+			st.requested = true
+			st.analyzed = now
+			st.refresh = false
+		default:
+			// The request is made again when the source is next polled
+		}
+	}
+
+	for _, v := range values {
+		if v.Err != nil || v.Stat.Name != power.OnBattery.Name || v.Instance() != "" {
+			continue
+		}
+		onBattery := v.Value != 0
+		if st.onBattery && !onBattery {
+			st.refresh = true
+		}
+		st.onBattery = onBattery
+	}
+
+	health, ok := score(st.discharges)
+	if !ok {
+		return nil
+	}
+	replace := 0.0
+	if health < a.options.ReplaceBelow {
+		replace = 1
+	}
+	return []power.Value{
+		{Source: source, Stat: BatteryHealth, Time: now, Value: health},
+		{Source: source, Stat: ReplaceBatterySoon, Time: now, Value: replace},
+	}
+}
+
+// Discharges returns the usable discharges of the named source that have
+// been found, in order of time.
+func (a *Analyzer) Discharges(name string) []Discharge {
+	st := a.state(name)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]Discharge(nil), st.discharges...)
+}
+
+// state returns the state of the named source, creating it if necessary.
+func (a *Analyzer) state(name string) *state {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.states[name]
+	if !ok {
+		st = &state{}
+		a.states[name] = st
+	}
+	return st
+}
+
+// run analyzes the sources that are requested until the analyzer is closed.
+func (a *Analyzer) run() {
+	defer close(a.done)
+	for {
+		select {
+		case name := <-a.requests:
+			if err := a.analyze(name, a.state(name), a.now()); err != nil {
+				fmt.Printf("Analytics error: %s: %v\n", name, err)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// analyze examines the history of the named source that has been recorded
+// since its previous analysis. The history is read without holding st.mu.
+func (a *Analyzer) analyze(name string, st *state, now time.Time) (err error) {
+	st.mu.Lock()
+	resume := st.resume
+	st.mu.Unlock()
+
+	lookback := now.Add(-a.options.Lookback)
+	if resume.Before(lookback) {
+		resume = lookback
+	}
+
+	// The history is examined in spans that are each answered by the finest
+	// resolution retained throughout them, which takes one query per
+	// resolution rather than one per day
+	var found []Discharge
+	for end := resume; end.Before(now) && err == nil; {
+		// Each span begins where the previous one ended, although the
+		// query may begin earlier to include a discharge in progress
+		var resolution historyrecipient.Resolution
+		resolution, end = a.span(end, now)
+
+		var periods []period
+		if periods, resume, err = a.periods(name, resume, end, resolution); err != nil {
+			break
+		}
+		for _, p := range periods {
+			d, ok, dErr := a.discharge(name, p, resolution)
+			if dErr != nil {
+				err = dErr
+				break
+			}
+			if ok {
+				found = append(found, d)
+			}
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.requested = false
+	if err != nil {
+		return err
+	}
+	st.resume = resume
+	st.discharges = append(st.discharges, found...)
+
+	// Discharges that have aged out of the lookback are forgotten
+	i := 0
+	for i < len(st.discharges) && st.discharges[i].End.Before(lookback) {
+		i++
+	}
+	st.discharges = st.discharges[i:]
+
+	return nil
+}
+
+// span returns the finest resolution of the history that is retained at
+// from, along with the time at which a finer resolution becomes available.
+// The time is now if from is answered by the finest resolution.
+func (a *Analyzer) span(from, now time.Time) (resolution historyrecipient.Resolution, until time.Time) {
+	until = now
+	for i, r := range resolutions {
+		retention := a.history.Retention(r)
+		if now.Sub(from) <= retention || i == len(resolutions)-1 {
+			return r, until
+		}
+		until = now.Add(-retention)
+	}
+	return resolutions[len(resolutions)-1], until
+}
@@ -0,0 +1,167 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/historyrecipient"
+)
+
+func TestSpan(t *testing.T) {
+	a, _ := newTestAnalyzer(t, historyrecipient.Options{
+		Raw:        24 * time.Hour,
+		FiveMinute: 7 * 24 * time.Hour,
+		Hourly:     30 * 24 * time.Hour,
+	}, Options{})
+
+	tests := []struct {
+		age        time.Duration
+		resolution historyrecipient.Resolution
+		until      time.Time
+	}{
+		{age: time.Hour, resolution: historyrecipient.Raw, until: base},
+		{age: 24 * time.Hour, resolution: historyrecipient.Raw, until: base},
+		{age: 3 * 24 * time.Hour, resolution: historyrecipient.FiveMinutes, until: base.Add(-24 * time.Hour)},
+		{age: 20 * 24 * time.Hour, resolution: historyrecipient.Hour, until: base.Add(-7 * 24 * time.Hour)},
+		{age: 100 * 24 * time.Hour, resolution: historyrecipient.Hour, until: base.Add(-7 * 24 * time.Hour)},
+	}
+
+	for _, test := range tests {
+		resolution, until := a.span(base.Add(-test.age), base)
+		if resolution != test.resolution || !until.Equal(test.until) {
+			t.Errorf("%s: span returned %s until %s, want %s until %s", test.age, resolution, until, test.resolution, test.until)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	a, h := newTestAnalyzer(t, historyrecipient.Options{}, Options{})
+	name := testSource.Key()
+
+	// A discharge old enough to be analyzed from five minute rollups, one
+	// recent enough for raw samples, and one that hasn't finished
+	old := run{start: base.Add(-20 * 24 * time.Hour), minutes: 60, charge: falling(0.5), estimate: expecting(0.5, 0.5), load: 50}
+	recent := run{start: base.Add(-2 * 24 * time.Hour), minutes: 10, charge: falling(2), estimate: expecting(1, 2), load: 50}
+	old.record(h)
+	recent.record(h)
+	charge, estimate := falling(5), expecting(2.5, 5)
+	for i := 0; i < 5; i++ {
+		at := base.Add(time.Duration(i-5) * time.Minute)
+		h.Send(power.Value{Source: testSource, Stat: power.OnBattery, Time: at, Value: 1})
+		h.Send(power.Value{Source: testSource, Stat: power.EstimatedChargeRemaining, Time: at, Value: charge(i)})
+		h.Send(power.Value{Source: testSource, Stat: power.EstimatedMinutesRemaining, Time: at, Value: estimate(i)})
+	}
+
+	if err := a.analyze(name, a.state(name), base); err != nil {
+		t.Fatalf("analyze returned %v", err)
+	}
+	discharges := a.Discharges(name)
+	if len(discharges) != 2 {
+		t.Fatalf("found %d discharges %+v, want 2", len(discharges), discharges)
+	}
+	if d := discharges[0]; !d.Start.Equal(old.start) || !d.End.Equal(old.start.Add(time.Hour)) || d.Duration != 55*time.Minute || !near(d.Drop, 27.5) || !near(d.Health, 100) {
+		t.Errorf("rollup discharge is %+v", d)
+	}
+	if d := discharges[1]; !d.Start.Equal(recent.start) || d.Duration != 9*time.Minute || !near(d.Drop, 18) || !near(d.Health, 50) {
+		t.Errorf("raw discharge is %+v", d)
+	}
+	if resume := a.state(name).resume; !resume.Equal(base.Add(-5 * time.Minute)) {
+		t.Errorf("analysis resumes at %s, want the start of the discharge in progress", resume)
+	}
+
+	// The discharge in progress is found once it finishes, without finding
+	// the others again
+	h.Send(power.Value{Source: testSource, Stat: power.OnBattery, Time: base, Value: 0})
+	h.Send(power.Value{Source: testSource, Stat: power.EstimatedChargeRemaining, Time: base, Value: 100})
+	if err := a.analyze(name, a.state(name), base.Add(time.Minute)); err != nil {
+		t.Fatalf("analyze returned %v", err)
+	}
+	discharges = a.Discharges(name)
+	if len(discharges) != 3 {
+		t.Fatalf("found %d discharges %+v, want 3", len(discharges), discharges)
+	}
+	if d := discharges[2]; !d.Start.Equal(base.Add(-5*time.Minute)) || !d.End.Equal(base) || !near(d.Drop, 20) || !near(d.Health, 50) {
+		t.Errorf("finished discharge is %+v", d)
+	}
+
+	// Discharges are forgotten once they age out of the lookback
+	if err := a.analyze(name, a.state(name), old.start.Add(DefaultOptions.Lookback+2*time.Hour)); err != nil {
+		t.Fatalf("analyze returned %v", err)
+	}
+	if discharges = a.Discharges(name); len(discharges) != 2 || !discharges[0].Start.Equal(recent.start) {
+		t.Errorf("found %+v after the oldest discharge aged out", discharges)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		name         string
+		replaceBelow float64
+		replace      float64
+	}{
+		{name: "healthy", replaceBelow: 80, replace: 0},
+		{name: "replace", replaceBelow: 90, replace: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, h := newTestAnalyzer(t, historyrecipient.Options{}, Options{ReplaceBelow: test.replaceBelow})
+			a.Close() // Analyses are run by the test
+			name := testSource.Key()
+
+			if values := a.Derive(testSource, nil); values != nil {
+				t.Fatalf("Derive returned %v before any discharge was found", values)
+			}
+
+			// Health of 100 over a drop of 27.5 and 50 over a drop of 18
+			run{start: base.Add(-3 * 24 * time.Hour), minutes: 56, charge: falling(0.5), estimate: expecting(0.5, 0.5), load: 50}.record(h)
+			run{start: base.Add(-2 * 24 * time.Hour), minutes: 10, charge: falling(2), estimate: expecting(1, 2), load: 50}.record(h)
+			if err := a.analyze(name, a.state(name), base); err != nil {
+				t.Fatalf("analyze returned %v", err)
+			}
+
+			values := a.Derive(testSource, nil)
+			if len(values) != 2 {
+				t.Fatalf("Derive returned %v, want 2 values", values)
+			}
+			health := (100*27.5 + 50*18) / (27.5 + 18)
+			if v := values[0]; v.Stat.Name != BatteryHealth.Name || !near(v.Value, health) || !v.Time.Equal(base) || v.Source != testSource {
+				t.Errorf("Derive returned health %+v, want %g", v, health)
+			}
+			if v := values[1]; v.Stat.Name != ReplaceBatterySoon.Name || v.Value != test.replace {
+				t.Errorf("Derive returned %+v, want replacement %g", v, test.replace)
+			}
+		})
+	}
+}
+
+func TestDeriveRefresh(t *testing.T) {
+	a, _ := newTestAnalyzer(t, historyrecipient.Options{}, Options{})
+	a.Close() // Requests are left in the queue
+	st := a.state(testSource.Key())
+
+	onBattery := func(v float64) []power.Value {
+		return []power.Value{{Source: testSource, Stat: power.OnBattery, Time: base, Value: v}}
+	}
+
+	a.Derive(testSource, onBattery(1))
+	if !st.requested || st.refresh {
+		t.Fatalf("first poll left requested %v, refresh %v", st.requested, st.refresh)
+	}
+	st.requested = false // As when the analysis finishes
+
+	// Returning from battery requests another analysis when next polled,
+	// even within the interval
+	a.Derive(testSource, onBattery(0))
+	if !st.refresh {
+		t.Fatal("return from battery didn't request an analysis")
+	}
+	a.Derive(testSource, onBattery(0))
+	if !st.requested || st.refresh {
+		t.Errorf("poll after return left requested %v, refresh %v", st.requested, st.refresh)
+	}
+	if len(a.requests) != 2 {
+		t.Errorf("%d analyses were requested, want 2", len(a.requests))
+	}
+}
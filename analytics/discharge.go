@@ -0,0 +1,176 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/historyrecipient"
+)
+
+// maxGap is the longest interval between consecutive samples of a discharge
+// that is considered continuous. Longer gaps, such as those left while the
+// process was stopped, are excluded from the analysis.
+const maxGap = 15 * time.Minute
+
+// Discharge summarizes a period during which a source ran on battery.
+type Discharge struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"` // Time covered by charge samples
+	Load     float64       `json:"load"`     // Average percent load, zero if unknown
+	Drop     float64       `json:"drop"`     // Percent of charge consumed
+	Expected float64       `json:"expected"` // Percent of charge the UPS estimate implied would be consumed
+	Rate     float64       `json:"rate"`     // Percent of charge consumed per minute
+	Health   float64       `json:"health"`   // Expected drop as a percentage of the actual drop
+}
+
+// period is a span of time during which a source ran on battery.
+type period struct {
+	start time.Time
+	end   time.Time // Time of the first sample taken off battery
+}
+
+// periods returns the completed periods during which the named source ran on
+// battery within [from, to) according to the history of the given
+// resolution, along with the time from which the next analysis should
+// resume.
+//
+// Rollup intervals are only considered to be on battery if every sample
+// within them was. Periods that are still in progress, along with rollups of
+// intervals in progress, are left for a later analysis.
+func (a *Analyzer) periods(name string, from, to time.Time, resolution historyrecipient.Resolution) (periods []period, resume time.Time, err error) {
+	points, _, err := a.history.Range(historyrecipient.Query{
+		Source:     name,
+		Statistic:  power.OnBattery.Name,
+		From:       from,
+		To:         to,
+		Resolution: resolution,
+	})
+	if err != nil {
+		return nil, from, err
+	}
+
+	resume = to
+	if resolution != historyrecipient.Raw {
+		resume = to.Truncate(time.Duration(resolution))
+	}
+
+	var (
+		start time.Time
+		open  bool
+	)
+	for _, p := range points {
+		if !p.Time.Before(resume) {
+			break
+		}
+		switch onBattery := p.Min != 0; {
+		case onBattery && !open:
+			start, open = p.Time, true
+		case !onBattery && open:
+			periods = append(periods, period{start: start, end: p.Time})
+			open = false
+		}
+	}
+	if open {
+		resume = start
+	}
+
+	return periods, resume, nil
+}
+
+// discharge analyzes the charge of the named source during p. It returns
+// false if the period doesn't hold enough information to judge the health of
+// the source's batteries.
+//
+// The UPS estimate of the remaining runtime at each charge sample implies the
+// rate at which the charge should fall until the next sample. The sum of
+// those expected drops is compared with the actual drop in charge. The drop
+// rate itself is not normalized by load; the average load is only used to
+// exclude discharges too light to judge.
+func (a *Analyzer) discharge(name string, p period, resolution historyrecipient.Resolution) (d Discharge, ok bool, err error) {
+	series := func(stat power.Statistic) ([]historyrecipient.Point, error) {
+		points, _, err := a.history.Range(historyrecipient.Query{
+			Source:     name,
+			Statistic:  stat.Name,
+			From:       p.start,
+			To:         p.end,
+			Resolution: resolution,
+		})
+		return points, err
+	}
+
+	charge, err := series(power.EstimatedChargeRemaining)
+	if err != nil || len(charge) < 2 {
+		return d, false, err
+	}
+	estimate, err := series(power.EstimatedMinutesRemaining)
+	if err != nil || len(estimate) == 0 {
+		return d, false, err
+	}
+	load, err := series(power.OutputPercentLoad)
+	if err != nil {
+		return d, false, err
+	}
+
+	gap := maxGap
+	if g := 2 * time.Duration(resolution); g > gap {
+		gap = g
+	}
+
+	d.Start, d.End = p.start, p.end
+	j := 0
+	for i := 0; i+1 < len(charge); i++ {
+		c0, c1 := charge[i], charge[i+1]
+		dt := c1.Time.Sub(c0.Time)
+		if dt <= 0 || dt > gap {
+			continue
+		}
+		// The latest estimate made at or before the first sample applies
+		for j+1 < len(estimate) && !estimate[j+1].Time.After(c0.Time) {
+			j++
+		}
+		minutes := estimate[j].Avg
+		if minutes <= 0 {
+			continue
+		}
+		d.Drop += c0.Avg - c1.Avg
+		d.Expected += c0.Avg * dt.Minutes() / minutes
+		d.Duration += dt
+	}
+
+	if len(load) > 0 {
+		for _, l := range load {
+			d.Load += l.Avg
+		}
+		d.Load /= float64(len(load))
+		if d.Load < a.options.MinLoad {
+			return d, false, nil
+		}
+	}
+	if d.Duration < a.options.MinDuration || d.Drop < a.options.MinDrop {
+		return d, false, nil
+	}
+
+	d.Rate = d.Drop / d.Duration.Minutes()
+	d.Health = 100 * d.Expected / d.Drop
+	if d.Health > 100 {
+		d.Health = 100
+	}
+
+	return d, true, nil
+}
+
+// score returns the health of a source's batteries, which is the average of
+// the health of its discharges weighted by the charge each consumed. It
+// returns false if there are no discharges.
+func score(discharges []Discharge) (health float64, ok bool) {
+	var weight float64
+	for _, d := range discharges {
+		health += d.Health * d.Drop
+		weight += d.Drop
+	}
+	if weight <= 0 {
+		return 0, false
+	}
+	return health / weight, true
+}
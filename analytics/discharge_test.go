@@ -0,0 +1,215 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/historyrecipient"
+)
+
+// base is the time at which analyses of test histories take place.
+var base = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// testSource is the source whose history is analyzed.
+var testSource = power.Source{Host: "ups1", Name: "rack"}
+
+// run describes a discharge to be recorded in a history, with one sample per
+// minute. Samples are taken off battery a minute before start and at
+// start+minutes.
+type run struct {
+	start    time.Time
+	minutes  int
+	charge   func(i int) float64 // Charge at minute i, negative if not sampled
+	estimate func(i int) float64 // Estimated minutes remaining at minute i
+	load     float64             // Percent load, not sampled if zero
+}
+
+// record adds the samples of r to h.
+func (r run) record(h *historyrecipient.History) {
+	send := func(stat power.Statistic, at time.Time, value float64) {
+		h.Send(power.Value{Source: testSource, Stat: stat, Time: at, Value: value})
+	}
+	for i := -1; i <= r.minutes; i++ {
+		at := r.start.Add(time.Duration(i) * time.Minute)
+		onBattery := 0.0
+		if i >= 0 && i < r.minutes {
+			onBattery = 1
+		}
+		send(power.OnBattery, at, onBattery)
+		if c := r.charge(i); c >= 0 {
+			send(power.EstimatedChargeRemaining, at, c)
+			send(power.EstimatedMinutesRemaining, at, r.estimate(i))
+		}
+		if r.load > 0 {
+			send(power.OutputPercentLoad, at, r.load)
+		}
+	}
+}
+
+// falling returns a charge that starts at 100 and falls by rate each minute.
+func falling(rate float64) func(int) float64 {
+	return func(i int) float64 { return 100 - rate*float64(i) }
+}
+
+// expecting returns the estimate of a UPS that expects its charge to fall by
+// rate each minute when it actually falls by actual each minute.
+func expecting(rate, actual float64) func(int) float64 {
+	return func(i int) float64 { return (100 - actual*float64(i)) / rate }
+}
+
+// newTestAnalyzer returns an analyzer of a history stored in a temporary
+// directory, along with the history.
+func newTestAnalyzer(t *testing.T, history historyrecipient.Options, options Options) (*Analyzer, *historyrecipient.History) {
+	t.Helper()
+	h, err := historyrecipient.Open(t.TempDir(), history)
+	if err != nil {
+		t.Fatalf("Open returned %v", err)
+	}
+	a := New(h, options)
+	a.now = func() time.Time { return base }
+	t.Cleanup(func() {
+		a.Close()
+		h.Close()
+	})
+	return a, h
+}
+
+// near reports whether a and b are equal within rounding error.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestDischarge(t *testing.T) {
+	start := base.Add(-2 * 24 * time.Hour)
+	tests := []struct {
+		name     string
+		run      run
+		ok       bool
+		duration time.Duration
+		drop     float64
+		health   float64
+	}{
+		{
+			name:     "matches estimate",
+			run:      run{minutes: 10, charge: falling(2), estimate: expecting(2, 2), load: 50},
+			ok:       true,
+			duration: 9 * time.Minute,
+			drop:     18,
+			health:   100,
+		},
+		{
+			name:     "half the estimate",
+			run:      run{minutes: 10, charge: falling(2), estimate: expecting(1, 2), load: 50},
+			ok:       true,
+			duration: 9 * time.Minute,
+			drop:     18,
+			health:   50,
+		},
+		{
+			name:     "better than the estimate",
+			run:      run{minutes: 10, charge: falling(1), estimate: expecting(2, 1), load: 50},
+			ok:       true,
+			duration: 9 * time.Minute,
+			drop:     9,
+			health:   100,
+		},
+		{
+			name:     "load unknown",
+			run:      run{minutes: 10, charge: falling(2), estimate: expecting(1, 2)},
+			ok:       true,
+			duration: 9 * time.Minute,
+			drop:     18,
+			health:   50,
+		},
+		{
+			name: "gap excluded",
+			run: run{minutes: 40, estimate: expecting(1, 1), load: 50, charge: func(i int) float64 {
+				if i >= 10 && i < 30 {
+					return -1
+				}
+				return 100 - float64(i)
+			}},
+			ok:       true,
+			duration: 18 * time.Minute,
+			drop:     18,
+			health:   100,
+		},
+		{
+			name: "too short",
+			run:  run{minutes: 2, charge: falling(10), estimate: expecting(10, 10), load: 50},
+		},
+		{
+			name: "too shallow",
+			run:  run{minutes: 10, charge: falling(0.5), estimate: expecting(0.5, 0.5), load: 50},
+		},
+		{
+			name: "too light",
+			run:  run{minutes: 10, charge: falling(2), estimate: expecting(2, 2), load: 2},
+		},
+		{
+			name: "no estimate",
+			run:  run{minutes: 10, charge: falling(2), estimate: func(int) float64 { return 0 }, load: 50},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, h := newTestAnalyzer(t, historyrecipient.Options{}, Options{})
+			test.run.start = start
+			test.run.record(h)
+
+			if err := a.analyze(testSource.Key(), a.state(testSource.Key()), base); err != nil {
+				t.Fatalf("analyze returned %v", err)
+			}
+			discharges := a.Discharges(testSource.Key())
+			if !test.ok {
+				if len(discharges) != 0 {
+					t.Fatalf("found unusable discharges %+v", discharges)
+				}
+				return
+			}
+			if len(discharges) != 1 {
+				t.Fatalf("found %d discharges %+v, want 1", len(discharges), discharges)
+			}
+			d := discharges[0]
+			end := start.Add(time.Duration(test.run.minutes) * time.Minute)
+			if !d.Start.Equal(start) || !d.End.Equal(end) {
+				t.Errorf("discharge spans %s to %s, want %s to %s", d.Start, d.End, start, end)
+			}
+			if d.Duration != test.duration || !near(d.Drop, test.drop) || !near(d.Health, test.health) {
+				t.Errorf("discharge lasted %s, dropped %g with health %g, want %s, %g and %g", d.Duration, d.Drop, d.Health, test.duration, test.drop, test.health)
+			}
+			if !near(d.Rate, test.drop/test.duration.Minutes()) {
+				t.Errorf("discharge rate is %g", d.Rate)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		discharges []Discharge
+		health     float64
+		ok         bool
+	}{
+		{name: "none"},
+		{name: "no drop", discharges: []Discharge{{Health: 50}}},
+		{name: "single", discharges: []Discharge{{Health: 50, Drop: 10}}, health: 50, ok: true},
+		{
+			name:       "weighted by drop",
+			discharges: []Discharge{{Health: 100, Drop: 30}, {Health: 40, Drop: 10}},
+			health:     85,
+			ok:         true,
+		},
+	}
+
+	for _, test := range tests {
+		health, ok := score(test.discharges)
+		if ok != test.ok || !near(health, test.health) {
+			t.Errorf("%s: score returned %g, %v, want %g, %v", test.name, health, ok, test.health, test.ok)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/config"
+	"github.com/scjalliance/power/dispatch"
+	"github.com/scjalliance/power/stathatrecipient"
+)
+
+// configWatchInterval is the interval at which the configuration file is
+// checked for changes.
+const configWatchInterval = 5 * time.Second
+
+// flushInterval is the interval at which recipients that buffer values are
+// flushed. Flushing periodically rather than after each poll lets recipients
+// deliver the values of many sources together.
+const flushInterval = 10 * time.Second
+
+// reportInterval is the interval at which the queue depth and delivery
+// counters of recipients that have lost values are printed.
+const reportInterval = 5 * time.Minute
+
+// loadTargets loads the configuration file at path and returns the targets
+// described by it with the given overrides applied. If path is empty the
+// targets are described by the overrides alone.
+func loadTargets(path string, overrides config.Overrides) ([]config.Target, error) {
+	file := &config.File{}
+	if path != "" {
+		var err error
+		if file, err = config.Load(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(overrides.Sources) == 0 && len(file.Sources) == 0 {
+		overrides.Sources = []string{defaultSource}
+	}
+
+	return file.Targets(overrides)
+}
+
+// buildTargets prepares the given targets for polling.
+//
+// Each recipient definition is instantiated once and shared by all of the
+// targets that refer to it. Each recipient is wrapped by a dispatcher with the
+// given options so that it receives values from its own goroutine. Recipients
+// in existing are reused when their definition is unchanged. The returned map
+// holds the recipients in use by the targets, keyed by definition.
+func buildTargets(targets []config.Target, existing map[string]power.Recipient, opts dispatch.Options) ([]target, map[string]power.Recipient, error) {
+	var (
+		built      []target
+		recipients = make(map[string]power.Recipient)
+		created    = make(map[string]power.Recipient)
+	)
+
+	fail := func(err error) ([]target, map[string]power.Recipient, error) {
+		closeRecipients(created, nil)
+		return nil, nil, err
+	}
+
+	for _, t := range targets {
+		if len(t.Statistics) == 0 {
+			return fail(fmt.Errorf("no statistics specified for %s", t.Source))
+		}
+		bt := target{
+			source:          t.Source,
+			interval:        t.Interval,
+			batteryInterval: t.BatteryInterval,
+			stats:           t.Statistics,
+		}
+		for _, definition := range t.Recipients {
+			r, ok := recipients[definition]
+			if !ok {
+				if r, ok = existing[definition]; !ok {
+					parsed, err := power.ParseRecipient(definition)
+					if err != nil {
+						return fail(fmt.Errorf("recipients parsing error: %v", err))
+					}
+					r = dispatch.New(definition, parsed, opts)
+					created[definition] = r
+				}
+				recipients[definition] = r
+			}
+			bt.recipients = append(bt.recipients, r)
+		}
+		if len(bt.recipients) == 0 {
+			return fail(fmt.Errorf("no recipients specified for %s", t.Source))
+		}
+		built = append(built, bt)
+	}
+
+	return built, recipients, nil
+}
+
+// flush flushes each of the given recipients that buffers values.
+func flush(recipients ...power.Recipient) {
+	for _, r := range recipients {
+		if flusher, ok := r.(power.Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				fmt.Printf("Unable to flush recipient: %v\n", err)
+			}
+		}
+	}
+}
+
+// report prints the queue depth and delivery counters of each of the given
+// recipients that has lost values.
+func report(recipients map[string]power.Recipient) {
+	for definition, r := range recipients {
+		if d, ok := r.(*dispatch.Recipient); ok {
+			if s := d.Stats(); s.Dropped > 0 || s.TimedOut > 0 || s.Panics > 0 || s.Stalled {
+				state := ""
+				if s.Stalled {
+					state = ", stalled"
+				}
+				fmt.Printf("Recipient %s: %d/%d queued, %d sent, %d dropped, %d timed out, %d panicked%s\n", definition, s.Queued, s.Capacity, s.Sent, s.Dropped, s.TimedOut, s.Panics, state)
+			}
+			r = d.Recipient()
+		}
+		if s, ok := r.(*stathatrecipient.Recipient); ok {
+			if st := s.Stats(); st.Dropped > 0 || st.Failed > 0 {
+				fmt.Printf("Recipient %s: %d queued, %d sent, %d dropped, %d failed\n", definition, st.Queued, st.Sent, st.Dropped, st.Failed)
+			}
+		}
+	}
+}
+
+// closeRecipients flushes and closes the recipients in previous that are not
+// present in current.
+func closeRecipients(previous, current map[string]power.Recipient) {
+	for definition, r := range previous {
+		if _, ok := current[definition]; ok {
+			continue
+		}
+		if flusher, ok := r.(power.Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				fmt.Printf("Unable to flush recipient %s: %v\n", definition, err)
+			}
+		}
+		if closer, ok := r.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				fmt.Printf("Unable to close recipient %s: %v\n", definition, err)
+			}
+		}
+	}
+}
+
+// finish waits for polls in progress to complete and then flushes and closes
+// all of the recipients. It gives up if this takes longer than timeout.
+func finish(p *poller, recipients map[string]power.Recipient, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.update(nil)
+		closeRecipients(recipients, nil)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		fmt.Printf("Recipients were not closed within the %s shutdown timeout\n", timeout)
+	}
+
+	report(recipients)
+}
+
+// watch checks the file at path for changes to its modification time or size
+// at the given interval until shutdown is signaled. A value is sent on the
+// returned channel when a change is detected.
+func watch(shutdown signaler.Signal, path string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+
+	go func() {
+		last, _ := os.Stat(path)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-shutdown:
+				return
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				// The file may be in the middle of being replaced
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return changed
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/config"
+	"github.com/scjalliance/power/dispatch"
+)
+
+// testRecipient is a recipient that records its lifecycle in a journal.
+type testRecipient struct {
+	name    string
+	journal *journal
+	closed  bool
+}
+
+func (r *testRecipient) Send(power.Value) {}
+
+func (r *testRecipient) Flush() error {
+	if r.closed {
+		r.journal.add("flush after close " + r.name)
+	}
+	r.journal.add("flush " + r.name)
+	return nil
+}
+
+func (r *testRecipient) Close() error {
+	r.closed = true
+	r.journal.add("close " + r.name)
+	return nil
+}
+
+// journal records the lifecycle events of test recipients.
+type journal struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (j *journal) add(entry string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+}
+
+// take returns the distinct recorded entries in sorted order and clears
+// them.
+func (j *journal) take() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	seen := make(map[string]bool)
+	var entries []string
+	for _, entry := range j.entries {
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	j.entries = nil
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// recipients is the journal of recipients created from "test:" definitions.
+var recipients = &journal{}
+
+func init() {
+	power.RegisterRecipientType(func(address string) (power.Recipient, error) {
+		recipients.add("create " + address)
+		return &testRecipient{name: address, journal: recipients}, nil
+	}, "test")
+}
+
+var testDispatch = dispatch.Options{Logger: log.New(ioutil.Discard, "", 0)}
+
+// testTarget returns a config target for host that delivers to the given
+// recipient definitions.
+func testTarget(host string, definitions ...string) config.Target {
+	return config.Target{
+		Source:     power.Source{Host: host},
+		Statistics: []power.Statistic{power.OutputPercentLoad},
+		Recipients: definitions,
+	}
+}
+
+func TestBuildTargets(t *testing.T) {
+	recipients.take()
+
+	built, current, err := buildTargets([]config.Target{
+		testTarget("ups1", "test:a", "test:b"),
+		testTarget("ups2", "test:a"),
+	}, nil, testDispatch)
+	if err != nil {
+		t.Fatalf("buildTargets returned %v", err)
+	}
+	if got := recipients.take(); got != "create a,create b" {
+		t.Fatalf("recipients %s, want each created once", got)
+	}
+	if len(built) != 2 || len(built[0].recipients) != 2 || len(built[1].recipients) != 1 {
+		t.Fatalf("buildTargets returned %+v", built)
+	}
+	if built[0].recipients[0] != built[1].recipients[0] || built[0].recipients[0] != current["test:a"] {
+		t.Fatal("targets don't share the recipient with the same definition")
+	}
+	if d, ok := current["test:b"].(*dispatch.Recipient); !ok || d.Recipient().(*testRecipient).name != "b" {
+		t.Fatalf("recipient is %T, want a dispatcher", current["test:b"])
+	}
+
+	// A reload reuses the recipients whose definition is unchanged and
+	// closes the ones that are no longer used
+	previous := current
+	built, current, err = buildTargets([]config.Target{
+		testTarget("ups1", "test:a", "test:c"),
+	}, previous, testDispatch)
+	if err != nil {
+		t.Fatalf("buildTargets returned %v", err)
+	}
+	if got := recipients.take(); got != "create c" {
+		t.Fatalf("recipients %s, want c created", got)
+	}
+	if current["test:a"] != previous["test:a"] || built[0].recipients[0] != previous["test:a"] {
+		t.Fatal("unchanged recipient was not reused")
+	}
+	if _, ok := current["test:b"]; ok {
+		t.Fatal("unused recipient is still in use")
+	}
+
+	closeRecipients(previous, current)
+	if got := recipients.take(); got != "close b,flush b" {
+		t.Fatalf("recipients %s, want b flushed and closed", got)
+	}
+
+	// Shutdown closes the rest
+	closeRecipients(current, nil)
+	if got := recipients.take(); got != "close a,close c,flush a,flush c" {
+		t.Fatalf("recipients %s, want a and c flushed and closed", got)
+	}
+}
+
+func TestBuildTargetsInvalid(t *testing.T) {
+	existing := map[string]power.Recipient{}
+	recipients.take()
+
+	noStats := testTarget("ups2", "test:e")
+	noStats.Statistics = nil
+
+	tests := []struct {
+		name    string
+		targets []config.Target
+		created string // Recipients created and closed again before the error
+	}{
+		{
+			name:    "unknown recipient",
+			targets: []config.Target{testTarget("ups1", "test:d", "bogus:x")},
+			created: "close d,create d,flush d",
+		},
+		{
+			name:    "no statistics",
+			targets: []config.Target{testTarget("ups1", "test:d"), noStats},
+			created: "close d,create d,flush d",
+		},
+		{
+			name:    "no recipients",
+			targets: []config.Target{testTarget("ups1")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			built, current, err := buildTargets(test.targets, existing, testDispatch)
+			if err == nil {
+				t.Fatalf("buildTargets returned %+v and %v, want an error", built, current)
+			}
+			if got := recipients.take(); got != test.created {
+				t.Fatalf("recipients %s, want %s", got, test.created)
+			}
+		})
+	}
+
+	// Existing recipients are left alone when the new targets are invalid
+	existing = buildExisting(t, "test:a")
+	if _, _, err := buildTargets([]config.Target{testTarget("ups1", "test:a", "bogus:x")}, existing, testDispatch); err == nil {
+		t.Fatal("buildTargets succeeded with an unknown recipient")
+	}
+	if got := recipients.take(); got != "" {
+		t.Fatalf("recipients %s, want the existing recipient left alone", got)
+	}
+	closeRecipients(existing, nil)
+	recipients.take()
+}
+
+// buildExisting returns recipients for the given definitions as buildTargets
+// would after a previous load.
+func buildExisting(t *testing.T, definitions ...string) map[string]power.Recipient {
+	t.Helper()
+	_, current, err := buildTargets([]config.Target{testTarget("ups1", definitions...)}, nil, testDispatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients.take()
+	return current
+}
+
+func TestLoadTargets(t *testing.T) {
+	// Without a configuration file the default source is used
+	targets, err := loadTargets("", config.Overrides{})
+	if err != nil {
+		t.Fatalf("loadTargets returned %v", err)
+	}
+	if len(targets) != 1 || targets[0].Source.Host != defaultSource {
+		t.Fatalf("loadTargets returned %+v", targets)
+	}
+
+	path := filepath.Join(t.TempDir(), "power.yaml")
+	data := "defaults:\n  recipients: [test:a]\nsources:\n  - address: ups1\n  - address: ups2\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err = loadTargets(path, config.Overrides{})
+	if err != nil {
+		t.Fatalf("loadTargets returned %v", err)
+	}
+	if len(targets) != 2 || targets[1].Source.Host != "ups2" || targets[1].Recipients[0] != "test:a" {
+		t.Fatalf("loadTargets returned %+v", targets)
+	}
+
+	// Override sources replace those in the file
+	targets, err = loadTargets(path, config.Overrides{Sources: []string{"ups3"}})
+	if err != nil {
+		t.Fatalf("loadTargets returned %v", err)
+	}
+	if len(targets) != 1 || targets[0].Source.Host != "ups3" {
+		t.Fatalf("loadTargets returned %+v", targets)
+	}
+
+	if _, err := loadTargets(filepath.Join(t.TempDir(), "missing.yaml"), config.Overrides{}); err == nil {
+		t.Fatal("loadTargets succeeded with a missing file")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/scjalliance/power"
+)
+
+// Discovery defaults
+const (
+	defaultDiscoverWorkers = 32
+	defaultDiscoverRate    = 50
+	defaultDiscoverTimeout = 2 * time.Second
+	maxDiscoverAddresses   = 65536
+)
+
+// discovery is a power device found by the discover subcommand.
+type discovery struct {
+	ip     net.IP
+	source power.Source
+	probe  power.Probe
+}
+
+// discover implements the discover subcommand, which scans one or more
+// subnets for power devices and prints source definitions for them. It
+// returns the exit code for the program.
+func discover(args []string) int {
+	communities := os.Getenv("COMMUNITY")
+	if communities == "" {
+		communities = power.DefaultCommunity
+	}
+
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s discover [flags] <cidr>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&communities, "c", communities, "comma separated list of SNMP communities to try")
+	workers := fs.Int("w", defaultDiscoverWorkers, "number of addresses probed concurrently")
+	rate := fs.Int("r", defaultDiscoverRate, "maximum number of probes sent per second")
+	timeout := fs.Duration("timeout", defaultDiscoverTimeout, "time to wait for each response")
+	verbose := fs.Bool("v", false, "include agents that are not power devices")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *workers < 1 || *rate < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var addrs []net.IP
+	for _, cidr := range fs.Args() {
+		subnet, err := hosts(cidr)
+		if err != nil {
+			fmt.Printf("Subnet parsing error: %v\n", err)
+			return 2
+		}
+		addrs = append(addrs, subnet...)
+		if len(addrs) > maxDiscoverAddresses {
+			fmt.Printf("Too many addresses to scan (limit %d)\n", maxDiscoverAddresses)
+			return 2
+		}
+	}
+
+	shutdown := signaler.New().Capture(os.Interrupt, syscall.SIGTERM)
+	defer shutdown.Wait()
+	defer shutdown.Trigger()
+
+	ctx := shutdown.Context()
+	throttle := time.NewTicker(time.Second / time.Duration(*rate))
+	defer throttle.Stop()
+
+	var (
+		jobs    = make(chan net.IP)
+		results = make(chan discovery)
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range jobs {
+				for _, community := range strings.Split(communities, ",") {
+					source := power.Source{
+						Host:      ip.String(),
+						Port:      fmt.Sprint(power.DefaultPort),
+						Community: community,
+						Timeout:   *timeout,
+					}
+					select {
+					case <-throttle.C:
+					case <-shutdown.Signal:
+						return
+					}
+					probe, err := power.ProbeSource(ctx, source)
+					if err != nil {
+						continue
+					}
+					results <- discovery{ip: ip, source: source, probe: probe}
+					break
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, ip := range addrs {
+			select {
+			case jobs <- ip:
+			case <-shutdown.Signal:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var found []discovery
+	for result := range results {
+		if result.probe.Power || *verbose {
+			found = append(found, result)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return bytes.Compare(found[i].ip.To16(), found[j].ip.To16()) < 0
+	})
+
+	for _, result := range found {
+		definition := fmt.Sprintf("%s@%s", result.source.Community, result.source.Host)
+		if name := sourceName(result.probe.Name); name != "" {
+			definition += "~" + name
+		}
+		if result.probe.Power {
+			fmt.Printf("%s\t# %s %s\n", definition, result.probe.Class, result.probe.Identity)
+		} else {
+			fmt.Printf("# %s\t# not a power device: %s\n", definition, result.probe.Description)
+		}
+	}
+
+	return 0
+}
+
+// hosts returns the host addresses within the given CIDR subnet. The network
+// and broadcast addresses of IPv4 subnets are excluded.
+func hosts(cidr string) (addrs []net.IP, err error) {
+	ip, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := subnet.Mask.Size()
+	if bits-ones > 16 {
+		return nil, fmt.Errorf("subnet %s is too large to scan", cidr)
+	}
+
+	for addr := ip.Mask(subnet.Mask); subnet.Contains(addr); addr = next(addr) {
+		addrs = append(addrs, addr)
+	}
+
+	if ip.To4() != nil && len(addrs) > 2 {
+		addrs = addrs[1 : len(addrs)-1]
+	}
+
+	return addrs, nil
+}
+
+// next returns the address following ip.
+func next(ip net.IP) net.IP {
+	n := make(net.IP, len(ip))
+	copy(n, ip)
+	for i := len(n) - 1; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			break
+		}
+	}
+	return n
+}
+
+// sourceName returns name in a form that is safe to use in a source
+// definition list.
+func sourceName(name string) string {
+	return strings.NewReplacer(",", "-", "~", "-", " ", "-").Replace(strings.TrimSpace(name))
+}
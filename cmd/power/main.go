@@ -1,166 +1,325 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gentlemanautomaton/signaler"
 	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/analytics"
+	"github.com/scjalliance/power/config"
 	"github.com/scjalliance/power/consolerecipient"
+	"github.com/scjalliance/power/dispatch"
+	"github.com/scjalliance/power/historyrecipient"
+	"github.com/scjalliance/power/modbuscollector"
+	"github.com/scjalliance/power/nutcollector"
+	"github.com/scjalliance/power/redfishcollector"
+	"github.com/scjalliance/power/schedule"
 	"github.com/scjalliance/power/stathatrecipient"
+	"github.com/scjalliance/power/statusapi"
+	"github.com/scjalliance/power/trap"
 )
 
 const (
-	defaultSource     = "localhost"
-	defaultStatistics = "all"
-	defaultRecipients = "console"
+	defaultSource          = "localhost"
+	defaultShutdownTimeout = 10 * time.Second
 )
 
+// commands are the subcommands supported in addition to the default polling
+// behavior. Each returns an exit code for the program.
+var commands = map[string]func(args []string) int{
+	"walk":     walk,
+	"discover": discover,
+	"simulate": simulate,
+	"record":   record,
+	"replay":   replay,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	shutdown := signaler.New().Capture(os.Interrupt, syscall.SIGTERM)
 	defer shutdown.Wait()
 	defer shutdown.Trigger()
 
 	var (
+		configPath    = os.Getenv("CONFIG")
 		sourceStr     = os.Getenv("SOURCE")
 		statisticsStr = os.Getenv("STATISTICS")
 		community     = os.Getenv("COMMUNITY")
 		intervalStr   = os.Getenv("INTERVAL")
+		batteryStr    = os.Getenv("BATTERY_INTERVAL")
 		recipientStr  = os.Getenv("RECIPIENT")
-		interval      time.Duration
+		trapAddr      = os.Getenv("TRAP")
+		httpAddr      = os.Getenv("HTTP")
+		historyDir    = os.Getenv("HISTORY")
+		retentionStr  = os.Getenv("HISTORY_RETENTION")
+		shutdownStr   = os.Getenv("SHUTDOWN_TIMEOUT")
+		policyStr     = os.Getenv("RECIPIENT_POLICY")
+		sendStr       = os.Getenv("RECIPIENT_TIMEOUT")
+		dispatchOpts  = dispatch.DefaultOptions
+		timeout       = defaultShutdownTimeout
 		verbose       bool
 	)
 
-	if sourceStr == "" {
-		sourceStr = defaultSource
-	}
-	if statisticsStr == "" {
-		statisticsStr = defaultStatistics
-	}
-	if community == "" {
-		community = power.DefaultCommunity
-	}
-	if recipientStr == "" {
-		recipientStr = defaultRecipients
-	}
-
+	flag.StringVar(&configPath, "f", configPath, "path to a YAML configuration file")
 	//flag.StringVar(&sourceStr, "s", sourceStr, "comma separated list of power sources to query, in form [name]community@server:port")
-	flag.StringVar(&statisticsStr, "q", statisticsStr, "comma separated list of statistics to query, \"all\" to include all statistics")
+	flag.StringVar(&statisticsStr, "q", statisticsStr, "comma separated list of statistics to query, \"all\" to include all UPS statistics or a device class (\"ups\", \"pdu\", \"sensor\") to include its statistics")
 	flag.StringVar(&community, "c", community, "default SNMP community for sources")
 	flag.StringVar(&intervalStr, "n", intervalStr, "interval between executions, blank for single execution")
+	flag.StringVar(&batteryStr, "b", batteryStr, "interval between executions while a source is on battery, blank to use the normal interval")
 	flag.StringVar(&recipientStr, "r", recipientStr, "comma separated list of output recipients")
+	flag.StringVar(&trapAddr, "t", trapAddr, "address on which to listen for SNMP traps (such as \":162\"), blank to disable")
+	flag.StringVar(&httpAddr, "l", httpAddr, "address on which to serve the status API and dashboard (such as \":8080\"), blank to disable")
+	flag.StringVar(&historyDir, "hd", historyDir, "directory in which to store the history of all sources, blank to disable")
+	flag.StringVar(&retentionStr, "hr", retentionStr, "time for which raw history samples are kept, such as \"168h\"")
+	flag.StringVar(&shutdownStr, "w", shutdownStr, "time to wait for recipients to deliver pending values at shutdown")
+	flag.StringVar(&policyStr, "p", policyStr, "action taken when a recipient falls behind: \"drop\" or \"block\"")
+	flag.StringVar(&sendStr, "pt", sendStr, "time allowed for each delivery to a recipient")
 	flag.BoolVar(&verbose, "v", verbose, "show responses to unsupported queries")
 	flag.Parse()
 
-	power.DefaultCommunity = community
+	if shutdownStr != "" {
+		var err error
+		if timeout, err = time.ParseDuration(shutdownStr); err != nil {
+			fmt.Printf("Unable to parse shutdown timeout: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if policyStr != "" {
+		var err error
+		if dispatchOpts.Policy, err = dispatch.ParsePolicy(policyStr); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(2)
+		}
+	}
+	if sendStr != "" {
+		var err error
+		if dispatchOpts.Timeout, err = time.ParseDuration(sendStr); err != nil {
+			fmt.Printf("Unable to parse recipient timeout: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
 	power.RegisterRecipientType(stathatrecipient.Parse, "stathat")
 	power.RegisterRecipientType(consolerecipient.Parse, "console")
+	power.RegisterCollector(nutcollector.Collector, nutcollector.DefaultPort, nutcollector.Scheme)
+	power.RegisterCollector(modbuscollector.Collector, modbuscollector.DefaultPort, modbuscollector.Scheme)
+	redfish := redfishcollector.New(redfishcollector.Options{})
+	power.RegisterCollector(redfish, redfishcollector.DefaultPort, redfishcollector.Scheme, redfishcollector.SchemeHTTPS)
+	power.RegisterCollector(redfish, redfishcollector.DefaultHTTPPort, redfishcollector.SchemeHTTP)
 
-	var sources []power.Source
-	var err error
-	if flag.NArg() > 0 {
-		sources, err = power.ParseSources(flag.Args())
-	} else {
-		sources, err = power.ParseSources(strings.Split(sourceStr, ","))
+	// Settings provided by environment variables and flags override those
+	// in the configuration file
+	var overrides config.Overrides
+	switch {
+	case flag.NArg() > 0:
+		overrides.Sources = flag.Args()
+	case sourceStr != "":
+		overrides.Sources = strings.Split(sourceStr, ",")
 	}
-	if err != nil {
-		fmt.Printf("Source parsing error: %s\n", err)
-		os.Exit(2)
+	overrides.Community = community
+	overrides.Interval = intervalStr
+	overrides.BatteryInterval = batteryStr
+	if statisticsStr != "" {
+		overrides.Statistics = strings.Split(statisticsStr, ",")
 	}
-	if len(sources) == 0 {
-		fmt.Printf("No sources specified\n")
-		os.Exit(2)
+	if recipientStr != "" {
+		overrides.Recipients = strings.Split(recipientStr, ",")
 	}
 
-	recipients, err := power.ParseRecipients(strings.Split(recipientStr, ","))
+	configTargets, err := loadTargets(configPath, overrides)
 	if err != nil {
-		fmt.Printf("Recipients parsing error: %s\n", err)
-		os.Exit(2)
-	}
-	if len(recipients) == 0 {
-		fmt.Printf("No recipients specified\n")
+		fmt.Printf("Configuration error: %v\n", err)
 		os.Exit(2)
 	}
 
-	stats, err := power.ParseStatistics(strings.Split(statisticsStr, ","))
+	targets, recipients, err := buildTargets(configTargets, nil, dispatchOpts)
 	if err != nil {
-		fmt.Printf("Statistics parsing error: %s\n", err)
+		fmt.Printf("Configuration error: %v\n", err)
 		os.Exit(2)
 	}
-	if len(stats) == 0 {
-		fmt.Printf("No statistics specified\n")
-		os.Exit(2)
+
+	// The history is closed after recipients have finished, which lets it
+	// record the final poll. Like other recipients it receives values from
+	// its own goroutine, so writing to disk doesn't delay polling.
+	var (
+		history  *historyrecipient.History
+		recorder *dispatch.Recipient
+	)
+	if historyDir != "" {
+		options := historyrecipient.DefaultOptions
+		if retentionStr != "" {
+			if options.Raw, err = time.ParseDuration(retentionStr); err != nil {
+				fmt.Printf("Unable to parse history retention: %v\n", err)
+				os.Exit(2)
+			}
+		}
+		if history, err = historyrecipient.Open(historyDir, options); err != nil {
+			fmt.Printf("History error: %v\n", err)
+			os.Exit(2)
+		}
+		recorder = dispatch.New("history", history, dispatchOpts)
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				fmt.Printf("History error: %v\n", err)
+			}
+		}()
+	}
+
+	p := newPoller(verbose)
+	p.update(targets)
+	if history != nil {
+		p.observers = append(p.observers, recorder)
+		analyzer := analytics.New(history, analytics.DefaultOptions)
+		defer analyzer.Close()
+		p.derivers = append(p.derivers, analyzer)
 	}
 
-	if intervalStr != "" {
-		interval, err = time.ParseDuration(intervalStr)
+	var status *statusapi.Store
+	if httpAddr != "" {
+		status = statusapi.New()
+		status.SetSources(p.sources())
+		if history != nil {
+			status.SetHistory(history)
+		}
+		p.observers = append(p.observers, status)
+
+		ln, err := net.Listen("tcp", httpAddr)
 		if err != nil {
-			fmt.Printf("Unable to parse interval: %v", err)
+			fmt.Printf("Status server error: %v\n", err)
 			os.Exit(2)
 		}
+		server := &http.Server{Handler: status.Handler()}
+		server.RegisterOnShutdown(status.Close)
+		defer server.Close()
+		go func() {
+			<-shutdown.Signal
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+		go func() {
+			if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Status server error: %v\n", err)
+			}
+		}()
 	}
 
-	execute(shutdown.Signal, sources, stats, recipients, verbose)
+	// Recipients are given a chance to deliver pending values after polling
+	// stops
+	defer func() {
+		finish(p, recipients, timeout)
+	}()
 
-	if interval > 0 {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				execute(shutdown.Signal, sources, stats, recipients, verbose)
-			case <-shutdown.Signal:
-				return
-			}
+	var listener *trap.Listener
+	if trapAddr != "" {
+		listener, err = trap.Listen(trapAddr, p.sources())
+		if err != nil {
+			fmt.Printf("Trap listener error: %v\n", err)
+			os.Exit(2)
 		}
+		defer listener.Close()
+		go func() {
+			<-shutdown.Signal
+			listener.Close()
+		}()
+		go p.serveImmediate(shutdown.Signal)
+		go func() {
+			if err := listener.Serve(func(e power.Event) {
+				p.handleEvent(shutdown.Signal, e)
+			}); err != nil {
+				fmt.Printf("Trap listener error: %v\n", err)
+			}
+		}()
 	}
-}
 
-func execute(shutdown signaler.Signal, sources []power.Source, stats []power.Statistic, recipients []power.Recipient, verbose bool) {
-	if shutdown.Signaled() {
+	p.execute(shutdown.Signal)
+
+	jobs := p.jobs(shutdown.Signal)
+	if len(jobs) == 0 && trapAddr == "" && httpAddr == "" {
 		return
 	}
 
-	stop := shutdown.Derive()
-	defer stop.Wait()
-	defer stop.Trigger()
+	// Each target is polled independently at its own interval
+	scheduler := schedule.New(schedule.DefaultJitter, func(o schedule.Overrun) {
+		fmt.Printf("Polling overrun: %s took %s, which exceeds its %s interval\n", o.Key, o.Duration, o.Interval)
+	})
+	defer scheduler.Stop()
+	scheduler.Update(jobs)
 
-	ctx := stop.Context()
+	// The configuration is reloaded when SIGHUP is received or when the
+	// configuration file changes
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 
-	for i, source := range sources {
-		for _, r := range recipients {
-			if shutdown.Signaled() {
-				return
-			}
-			if handler, ok := r.(power.SourceHandler); ok {
-				handler.SendSource(i, source)
-			}
+	var changed <-chan struct{}
+	if configPath != "" {
+		changed = watch(shutdown.Signal, configPath, configWatchInterval)
+	}
+
+	reload := func() {
+		configTargets, err := loadTargets(configPath, overrides)
+		if err != nil {
+			fmt.Printf("Configuration reload error: %v\n", err)
+			return
+		}
+		targets, current, err := buildTargets(configTargets, recipients, dispatchOpts)
+		if err != nil {
+			fmt.Printf("Configuration reload error: %v\n", err)
+			return
+		}
+		p.update(targets)
+		scheduler.Update(p.jobs(shutdown.Signal))
+		closeRecipients(recipients, current)
+		recipients = current
+		if listener != nil {
+			listener.SetSources(p.sources())
 		}
+		if status != nil {
+			status.SetSources(p.sources())
+		}
+		fmt.Printf("Configuration reloaded: %d sources\n", len(targets))
+	}
 
-		var values []power.Value
-		values, err := power.Query(ctx, source, stats...)
-		for _, r := range recipients {
-			if err == nil {
-				for _, v := range values {
-					if shutdown.Signaled() {
-						return
-					}
-					if verbose || !power.IsNotSupported(v.Err) {
-						r.Send(v)
-					}
-				}
-			} else {
-				if shutdown.Signaled() {
-					return
-				}
-				if handler, ok := r.(power.ErrorHandler); ok {
-					handler.SendQueryError(i, source, err)
-				}
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	reportTicker := time.NewTicker(reportInterval)
+	defer reportTicker.Stop()
+
+	for {
+		select {
+		case <-hangup:
+			reload()
+		case <-changed:
+			reload()
+		case <-flushTicker.C:
+			for _, r := range recipients {
+				flush(r)
+			}
+			flush(p.observers...)
+		case <-reportTicker.C:
+			report(recipients)
+			if recorder != nil {
+				report(map[string]power.Recipient{"history": recorder})
 			}
+		case <-shutdown.Signal:
+			return
 		}
 	}
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/schedule"
+)
+
+// immediateQueueSize is the number of targets that may wait for an immediate
+// poll.
+const immediateQueueSize = 64
+
+// target is a source along with the statistics queried from it and the
+// recipients its results are delivered to.
+type target struct {
+	key             string // Identifies the target's polling job
+	source          power.Source
+	interval        time.Duration
+	batteryInterval time.Duration
+	stats           []power.Statistic
+	recipients      []power.Recipient
+}
+
+// poller queries a set of targets and delivers the results to their
+// recipients.
+//
+// Recipients are not expected to be safe for concurrent use, so polling
+// cycles and out-of-cycle polls triggered by events are serialized.
+//
+// The targets may be replaced while the poller is running. Replacement waits
+// for polls in progress to finish.
+//
+// Immediate polls requested by events are made by serveImmediate. Requests
+// for a target that is already waiting to be polled are merged.
+type poller struct {
+	mu        sync.Mutex   // Serializes delivery to recipients
+	cycle     sync.RWMutex // Held for reading while polling and for writing while updating
+	targets   []target
+	observers []power.Recipient // Receive the results of every target
+	derivers  []deriver         // Add derived values to the results of every target
+	verbose   bool
+
+	immediate chan string     // Keys of targets waiting for an immediate poll
+	pendingMu sync.Mutex      // Guards pending
+	pending   map[string]bool // Keys present in immediate
+}
+
+// newPoller returns a poller without targets.
+func newPoller(verbose bool) *poller {
+	return &poller{
+		verbose:   verbose,
+		immediate: make(chan string, immediateQueueSize),
+		pending:   make(map[string]bool),
+	}
+}
+
+// deriver produces values derived from those retrieved from a source.
+type deriver interface {
+	Derive(source power.Source, values []power.Value) []power.Value
+}
+
+// update replaces the targets of the poller. It blocks until polls in
+// progress have finished.
+//
+// Each target is assigned a key derived from its source, which remains the
+// same across updates.
+func (p *poller) update(targets []target) {
+	seen := make(map[string]int)
+	for i := range targets {
+		key := targets[i].source.String()
+		if n := seen[key]; n > 0 {
+			targets[i].key = fmt.Sprintf("%s#%d", key, n)
+		} else {
+			targets[i].key = key
+		}
+		seen[key]++
+	}
+
+	p.cycle.Lock()
+	defer p.cycle.Unlock()
+	p.targets = targets
+}
+
+// sources returns the sources of all targets.
+func (p *poller) sources() (sources []power.Source) {
+	p.cycle.RLock()
+	defer p.cycle.RUnlock()
+	for _, t := range p.targets {
+		sources = append(sources, t.source)
+	}
+	return
+}
+
+// jobs returns a polling job for each target with a polling interval.
+func (p *poller) jobs(shutdown signaler.Signal) (jobs []schedule.Job) {
+	p.cycle.RLock()
+	defer p.cycle.RUnlock()
+
+	for _, t := range p.targets {
+		if t.interval <= 0 {
+			continue
+		}
+		key := t.key
+		jobs = append(jobs, schedule.Job{
+			Key:      key,
+			Interval: t.interval,
+			Fast:     t.batteryInterval,
+			Run: func(ctx context.Context) bool {
+				return p.pollKey(ctx, shutdown, key)
+			},
+		})
+	}
+	return
+}
+
+// execute polls each of the targets in turn.
+func (p *poller) execute(shutdown signaler.Signal) {
+	if shutdown.Signaled() {
+		return
+	}
+
+	p.cycle.RLock()
+	defer p.cycle.RUnlock()
+
+	stop := shutdown.Derive()
+	defer stop.Wait()
+	defer stop.Trigger()
+
+	ctx := stop.Context()
+
+	for i, t := range p.targets {
+		if shutdown.Signaled() {
+			return
+		}
+		p.poll(ctx, shutdown, i, t)
+	}
+}
+
+// pollKey polls the target with the given key. It returns true if the
+// target's source is running on battery.
+func (p *poller) pollKey(ctx context.Context, shutdown signaler.Signal, key string) bool {
+	if shutdown.Signaled() {
+		return false
+	}
+
+	p.cycle.RLock()
+	defer p.cycle.RUnlock()
+
+	for i, t := range p.targets {
+		if t.key != key {
+			continue
+		}
+
+		return onBattery(p.poll(ctx, shutdown, i, t))
+	}
+
+	return false
+}
+
+// handleEvent delivers an event to the recipients of the source that produced
+// it and then requests an immediate poll of the source. It does not wait for
+// the poll.
+func (p *poller) handleEvent(shutdown signaler.Signal, e power.Event) {
+	if shutdown.Signaled() {
+		return
+	}
+
+	p.cycle.RLock()
+	defer p.cycle.RUnlock()
+
+	for _, t := range p.targets {
+		if t.source.String() != e.Source.String() {
+			continue
+		}
+
+		recipients := p.recipients(t)
+		p.mu.Lock()
+		for _, r := range recipients {
+			if handler, ok := r.(power.EventHandler); ok {
+				handler.SendEvent(e)
+			}
+		}
+		p.mu.Unlock()
+
+		p.requestImmediate(t.key)
+		return
+	}
+}
+
+// requestImmediate queues an immediate poll of the target with the given key
+// unless one is already waiting. The request is discarded if the queue is
+// full.
+func (p *poller) requestImmediate(key string) {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	if p.pending[key] {
+		return
+	}
+	select {
+	case p.immediate <- key:
+		p.pending[key] = true
+	default:
+	}
+}
+
+// serveImmediate makes the immediate polls that have been requested until
+// shutdown is signaled.
+func (p *poller) serveImmediate(shutdown signaler.Signal) {
+	for {
+		var key string
+		select {
+		case key = <-p.immediate:
+		case <-shutdown:
+			return
+		}
+
+		// Requests received from now on call for another poll
+		p.pendingMu.Lock()
+		delete(p.pending, key)
+		p.pendingMu.Unlock()
+
+		stop := shutdown.Derive()
+		p.pollKey(stop.Context(), shutdown, key)
+		stop.Trigger()
+		stop.Wait()
+	}
+}
+
+// poll queries a single target and delivers the results to its recipients.
+// It returns the values that were retrieved.
+func (p *poller) poll(ctx context.Context, shutdown signaler.Signal, i int, t target) (values []power.Value) {
+	source := t.source
+	recipients := p.recipients(t)
+
+	id, values, err := power.QueryIdentity(ctx, source, t.stats...)
+	if err == nil {
+		for _, d := range p.derivers {
+			values = append(values, d.Derive(source, values)...)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, r := range recipients {
+		if shutdown.Signaled() {
+			return
+		}
+		if handler, ok := r.(power.SourceHandler); ok {
+			handler.SendSource(i, source)
+		}
+		if handler, ok := r.(power.IdentityHandler); ok && id.Vendor != nil {
+			handler.SendIdentity(i, source, id)
+		}
+	}
+
+	for _, r := range recipients {
+		if err == nil {
+			for _, v := range values {
+				if shutdown.Signaled() {
+					return
+				}
+				if p.verbose || !power.IsNotSupported(v.Err) {
+					r.Send(v)
+				}
+			}
+		} else {
+			if shutdown.Signaled() {
+				return
+			}
+			if handler, ok := r.(power.ErrorHandler); ok {
+				handler.SendQueryError(i, source, err)
+			}
+		}
+	}
+
+	for _, r := range recipients {
+		if handler, ok := r.(power.CycleHandler); ok {
+			handler.EndCycle(i, source)
+		}
+	}
+
+	return
+}
+
+// recipients returns the recipients of t followed by the observers of the
+// poller.
+func (p *poller) recipients(t target) []power.Recipient {
+	if len(p.observers) == 0 {
+		return t.recipients
+	}
+	recipients := make([]power.Recipient, 0, len(t.recipients)+len(p.observers))
+	recipients = append(recipients, t.recipients...)
+	return append(recipients, p.observers...)
+}
+
+// onBattery returns true if values indicate that a source is running on
+// battery.
+func onBattery(values []power.Value) bool {
+	for _, v := range values {
+		if v.Err == nil && v.Stat.Name == power.OnBattery.Name && v.Value != 0 {
+			return true
+		}
+	}
+	return false
+}
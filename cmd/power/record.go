@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/snmprec"
+)
+
+// record implements the record subcommand, which walks a source and saves
+// its variables in snmprec format. It returns the exit code for the program.
+func record(args []string) int {
+	community := os.Getenv("COMMUNITY")
+	if community == "" {
+		community = power.DefaultCommunity
+	}
+
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s record [flags] <source> [oid]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&community, "c", community, "default SNMP community for the source")
+	output := fs.String("o", "", "path of the snmprec file to write, blank for standard output")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	power.DefaultCommunity = community
+	source, err := power.ParseSource(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Source parsing error: %s\n", err)
+		return 2
+	}
+
+	root := power.DefaultWalkRoot
+	if fs.NArg() == 2 {
+		root, err = snmpgo.NewOid(fs.Arg(1))
+		if err != nil {
+			fmt.Printf("Unable to parse OID: %v\n", err)
+			return 2
+		}
+	}
+
+	shutdown := signaler.New().Capture(os.Interrupt, syscall.SIGTERM)
+	defer shutdown.Wait()
+	defer shutdown.Trigger()
+
+	bindings, err := power.Walk(shutdown.Context(), source, root)
+	if err != nil {
+		fmt.Printf("Walk error: %v\n", err)
+		return 1
+	}
+
+	var (
+		w io.Writer = os.Stdout
+		f *os.File
+	)
+	if *output != "" {
+		f, err = os.Create(*output)
+		if err != nil {
+			fmt.Printf("Output error: %v\n", err)
+			return 1
+		}
+		w = f
+	}
+
+	if err := snmprec.Write(w, bindings); err != nil {
+		if f != nil {
+			f.Close()
+		}
+		fmt.Printf("Output error: %v\n", err)
+		return 1
+	}
+
+	if f != nil {
+		// Errors that were deferred by the file system surface when the
+		// file is closed
+		if err := f.Close(); err != nil {
+			fmt.Printf("Output error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Recorded %d variables from %s to %s\n", len(bindings), source, *output)
+	}
+
+	return 0
+}
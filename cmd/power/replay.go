@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/scjalliance/power/powertest"
+)
+
+// replay implements the replay subcommand, which runs an SNMP agent that
+// serves the variables in an snmprec file. It returns the exit code for the
+// program.
+func replay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay [flags] <file>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	address := fs.String("a", "127.0.0.1:1161", "UDP address on which to listen")
+	community := fs.String("c", powertest.DefaultCommunity, "SNMP community to accept")
+	user := fs.String("u", "", "SNMPv3 user to accept, blank to disable SNMPv3; privacy (authPriv) is not supported")
+	authPassword := fs.String("ua", "", "SNMPv3 authentication password to require, blank to accept noAuthNoPriv")
+	authProtocol := fs.String("up", powertest.AuthMD5, "SNMPv3 authentication protocol: \"MD5\" or \"SHA\"")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	mib, err := powertest.Replay(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Recording error: %v\n", err)
+		return 1
+	}
+
+	agent, err := powertest.Start(mib, powertest.Config{
+		Address:      *address,
+		Community:    *community,
+		User:         *user,
+		AuthPassword: *authPassword,
+		AuthProtocol: *authProtocol,
+	})
+	if err != nil {
+		fmt.Printf("Agent error: %v\n", err)
+		return 1
+	}
+	defer agent.Close()
+
+	fmt.Printf("Replaying %d variables at %s@%s\n", len(mib.Variables()), *community, agent.Addr())
+
+	shutdown := signaler.New().Capture(os.Interrupt, syscall.SIGTERM)
+	defer shutdown.Wait()
+	defer shutdown.Trigger()
+
+	<-shutdown.Signal
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/scjalliance/power/powertest"
+)
+
+// simulate implements the simulate subcommand, which runs an SNMP agent that
+// serves a simulated UPS. It returns the exit code for the program.
+func simulate(args []string) int {
+	ups := powertest.NewUPS()
+
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s simulate [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	address := fs.String("a", "127.0.0.1:1161", "UDP address on which to listen")
+	community := fs.String("c", powertest.DefaultCommunity, "SNMP community to accept")
+	user := fs.String("u", "", "SNMPv3 user to accept, blank to disable SNMPv3; privacy (authPriv) is not supported")
+	authPassword := fs.String("ua", "", "SNMPv3 authentication password to require, blank to accept noAuthNoPriv")
+	authProtocol := fs.String("up", powertest.AuthMD5, "SNMPv3 authentication protocol: \"MD5\" or \"SHA\"")
+	fs.DurationVar(&ups.MainsFailure, "fail", ups.MainsFailure, "time at which mains power fails, negative to never fail")
+	fs.DurationVar(&ups.MainsRestore, "restore", ups.MainsRestore, "time at which mains power returns, zero to never return")
+	fs.DurationVar(&ups.Runtime, "runtime", ups.Runtime, "runtime of a fully charged battery")
+	fs.Parse(args)
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	agent, err := powertest.Start(ups, powertest.Config{
+		Address:      *address,
+		Community:    *community,
+		User:         *user,
+		AuthPassword: *authPassword,
+		AuthProtocol: *authProtocol,
+	})
+	if err != nil {
+		fmt.Printf("Agent error: %v\n", err)
+		return 1
+	}
+	defer agent.Close()
+
+	fmt.Printf("Simulating a UPS at %s@%s\n", *community, agent.Addr())
+
+	shutdown := signaler.New().Capture(os.Interrupt, syscall.SIGTERM)
+	defer shutdown.Wait()
+	defer shutdown.Trigger()
+
+	<-shutdown.Signal
+	return 0
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/gentlemanautomaton/signaler"
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+)
+
+// walkVariable is the JSON representation of a walked variable.
+type walkVariable struct {
+	OID        string          `json:"oid"`
+	Type       string          `json:"type"`
+	Value      string          `json:"value"`
+	Statistics []walkStatistic `json:"statistics,omitempty"`
+}
+
+// walkStatistic is the JSON representation of a statistic matched to a
+// walked variable.
+type walkStatistic struct {
+	Name  string  `json:"name"`
+	Index string  `json:"index,omitempty"`
+	Unit  string  `json:"unit,omitempty"`
+	Value float64 `json:"value"`
+	Text  string  `json:"text"`
+}
+
+// walk implements the walk subcommand, which dumps the variables within a
+// subtree of a source. It returns the exit code for the program.
+func walk(args []string) int {
+	community := os.Getenv("COMMUNITY")
+	if community == "" {
+		community = power.DefaultCommunity
+	}
+
+	fs := flag.NewFlagSet("walk", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s walk [flags] <source> [oid]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&community, "c", community, "default SNMP community for the source")
+	format := fs.String("o", "text", "output format: \"text\" or \"json\"")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Printf("Unknown output format: %s\n", *format)
+		return 2
+	}
+
+	power.DefaultCommunity = community
+	source, err := power.ParseSource(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Source parsing error: %s\n", err)
+		return 2
+	}
+
+	root := power.DefaultWalkRoot
+	if fs.NArg() == 2 {
+		root, err = snmpgo.NewOid(fs.Arg(1))
+		if err != nil {
+			fmt.Printf("Unable to parse OID: %v\n", err)
+			return 2
+		}
+	}
+
+	shutdown := signaler.New().Capture(os.Interrupt, syscall.SIGTERM)
+	defer shutdown.Wait()
+	defer shutdown.Trigger()
+
+	bindings, err := power.Walk(shutdown.Context(), source, root)
+	if err != nil {
+		fmt.Printf("Walk error: %v\n", err)
+		return 1
+	}
+
+	switch *format {
+	case "json":
+		variables := make([]walkVariable, 0, len(bindings))
+		for _, binding := range bindings {
+			v := walkVariable{
+				OID:   binding.Oid.String(),
+				Type:  binding.Variable.Type(),
+				Value: binding.Variable.String(),
+			}
+			for _, match := range power.MatchStatistics(binding) {
+				if match.Err != nil {
+					continue
+				}
+				v.Statistics = append(v.Statistics, walkStatistic{
+					Name:  match.Stat.Name,
+					Index: match.Index,
+					Unit:  match.Stat.Unit,
+					Value: match.Value,
+					Text:  match.String(),
+				})
+			}
+			variables = append(variables, v)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(variables); err != nil {
+			fmt.Printf("Output error: %v\n", err)
+			return 1
+		}
+	default:
+		for _, binding := range bindings {
+			fmt.Printf("%s = %s: %s", binding.Oid, binding.Variable.Type(), binding.Variable)
+			for _, match := range power.MatchStatistics(binding) {
+				if match.Err != nil {
+					continue
+				}
+				if match.Index != "" {
+					fmt.Printf(" [%s %s: %s]", match.Stat.Name, match.Index, match)
+				} else {
+					fmt.Printf(" [%s: %s]", match.Stat.Name, match)
+				}
+			}
+			fmt.Printf("\n")
+		}
+	}
+
+	return 0
+}
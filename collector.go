@@ -0,0 +1,100 @@
+package power
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemeSNMP is the scheme of sources that are queried via SNMP. Sources
+// without a scheme are treated as SNMP sources.
+const SchemeSNMP = "snmp"
+
+// Collector retrieves statistics from sources that use a particular protocol.
+//
+// Collect returns a value for each of the given statistics. Failures that
+// affect individual statistics are reported through the error of each value,
+// while failures that prevent any statistics from being collected are
+// returned as an error.
+type Collector interface {
+	Collect(ctx context.Context, source Source, stats []Statistic) ([]Value, error)
+}
+
+// Identifier is a collector that is able to identify the devices behind its
+// sources.
+type Identifier interface {
+	Identify(ctx context.Context, source Source) (Identity, error)
+}
+
+// IdentifyingCollector is a collector that is able to identify the device
+// behind a source while collecting its statistics, which avoids connecting to
+// the source more than once.
+//
+// CollectIdentity behaves like Collect. Failure to identify the device is not
+// an error; a zero identity is returned instead.
+type IdentifyingCollector interface {
+	Collector
+	CollectIdentity(ctx context.Context, source Source, stats []Statistic) (Identity, []Value, error)
+}
+
+// CollectorFunc is a function that implements the Collector interface.
+type CollectorFunc func(ctx context.Context, source Source, stats []Statistic) ([]Value, error)
+
+// Collect calls f(ctx, source, stats).
+func (f CollectorFunc) Collect(ctx context.Context, source Source, stats []Statistic) ([]Value, error) {
+	return f(ctx, source, stats)
+}
+
+// collectorEntry is a registered collector.
+type collectorEntry struct {
+	collector   Collector
+	defaultPort string
+}
+
+var collectors = struct {
+	sync.RWMutex
+	m map[string]collectorEntry
+}{m: make(map[string]collectorEntry)}
+
+func init() {
+	RegisterCollector(snmpCollector{}, DefaultPort, SchemeSNMP)
+}
+
+// RegisterCollector registers c as the collector for sources with the given
+// schemes. The default port is assigned to sources of those schemes that
+// don't specify one.
+//
+// Schemes are case insensitive. Registering a scheme again replaces its
+// collector, which allows the SNMP collector to be replaced with a fake.
+func RegisterCollector(c Collector, defaultPort int, schemes ...string) {
+	collectors.Lock()
+	defer collectors.Unlock()
+	for _, scheme := range schemes {
+		collectors.m[strings.ToLower(scheme)] = collectorEntry{
+			collector:   c,
+			defaultPort: strconv.Itoa(defaultPort),
+		}
+	}
+}
+
+// lookupCollector returns the registered collector for scheme.
+func lookupCollector(scheme string) (collectorEntry, bool) {
+	if scheme == "" {
+		scheme = SchemeSNMP
+	}
+	collectors.RLock()
+	defer collectors.RUnlock()
+	entry, ok := collectors.m[strings.ToLower(scheme)]
+	return entry, ok
+}
+
+// collectorFor returns the collector for the given source.
+func collectorFor(source Source) (Collector, error) {
+	entry, ok := lookupCollector(source.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("no collector registered for scheme \"%s\"", source.Scheme)
+	}
+	return entry.collector, nil
+}
@@ -0,0 +1,98 @@
+// Package config loads power monitoring configuration files.
+//
+// A configuration file is written in YAML. It declares a list of sources,
+// each of which may override the default community, credentials, timeouts,
+// polling interval, statistics and recipients. Statistics and recipients may
+// be given names so that they can be referred to by sources.
+//
+//	defaults:
+//	  community: tripplite
+//	  interval: 1m
+//	  batteryinterval: 5s
+//	  statistics: [ups]
+//	  recipients: [console]
+//	statistics:
+//	  WidgetPower: name:WidgetPower,oid:1.3.6.1.4.1.99999.1.1.0,unit:watts
+//	recipients:
+//	  stathat: stathat:EZKEY
+//	sources:
+//	  - address: lcy-rack2n-ups
+//	    recipients: [console, stathat]
+//	  - host: lcy-rack2s-pdu
+//	    community: private
+//	    interval: 5m
+//	    statistics: [pdu, WidgetPower]
+//
+// Statistic and recipient definitions use the same formats that are accepted
+// on the command line and are parsed by the corresponding Parse functions in
+// the power package.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// File is the content of a configuration file.
+type File struct {
+	Defaults   Settings          `yaml:"defaults"`
+	Statistics map[string]string `yaml:"statistics"` // Named statistic definitions
+	Recipients map[string]string `yaml:"recipients"` // Named recipient definitions
+	Sources    []Source          `yaml:"sources"`
+}
+
+// Settings hold source settings that can be specified as defaults or on a
+// per-source basis.
+type Settings struct {
+	Community       string      `yaml:"community"`
+	Credentials     Credentials `yaml:"credentials"`
+	Timeout         string      `yaml:"timeout"`
+	Retries         *uint       `yaml:"retries"`
+	Interval        string      `yaml:"interval"`
+	BatteryInterval string      `yaml:"batteryinterval"` // Interval while on battery
+	Statistics      []string    `yaml:"statistics"`
+	Recipients      []string    `yaml:"recipients"`
+}
+
+// Credentials hold SNMPv3 user-based security settings.
+type Credentials struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	AuthProtocol string `yaml:"auth"`
+	PrivPassword string `yaml:"privpassword"`
+	PrivProtocol string `yaml:"priv"`
+}
+
+// Source describes a single source within a configuration file.
+//
+// The source may be described by an address in any of the forms accepted by
+// power.ParseSource, or by its individual host, port and name fields. When
+// both are present the individual fields replace the corresponding parts of
+// the address, and the rest of the address, such as its community, is kept.
+type Source struct {
+	Address  string `yaml:"address"`
+	Name     string `yaml:"name"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Settings `yaml:",inline"`
+}
+
+// Load reads the configuration file at path.
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses the given configuration file content.
+func Parse(data []byte) (*File, error) {
+	var f File
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return &f, nil
+}
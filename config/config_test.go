@@ -0,0 +1,96 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const example = `
+defaults:
+  community: tripplite
+  interval: 1m
+  batteryinterval: 5s
+  statistics: [ups]
+  recipients: [console]
+statistics:
+  WidgetPower: name:WidgetPower,oid:1.3.6.1.4.1.99999.1.1.0,unit:watts
+recipients:
+  stathat: stathat:EZKEY
+sources:
+  - address: lcy-rack2n-ups
+    recipients: [console, stathat]
+  - host: lcy-rack2s-pdu
+    community: private
+    interval: 5m
+    retries: 0
+    statistics: [pdu, WidgetPower]
+    credentials:
+      username: monitor
+      password: authpass
+      auth: SHA
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+
+	if f.Defaults.Community != "tripplite" || f.Defaults.Interval != "1m" || f.Defaults.BatteryInterval != "5s" {
+		t.Errorf("Parse returned defaults %+v", f.Defaults)
+	}
+	if f.Statistics["WidgetPower"] == "" || f.Recipients["stathat"] != "stathat:EZKEY" {
+		t.Errorf("Parse returned definitions %v and %v", f.Statistics, f.Recipients)
+	}
+	if len(f.Sources) != 2 {
+		t.Fatalf("Parse returned %d sources, want 2", len(f.Sources))
+	}
+
+	s := f.Sources[1]
+	if s.Host != "lcy-rack2s-pdu" || s.Community != "private" || s.Interval != "5m" {
+		t.Errorf("Parse returned source %+v", s)
+	}
+	if s.Retries == nil || *s.Retries != 0 {
+		t.Errorf("Parse returned retries %v, want 0", s.Retries)
+	}
+	if s.Credentials.Username != "monitor" || s.Credentials.AuthProtocol != "SHA" {
+		t.Errorf("Parse returned credentials %+v", s.Credentials)
+	}
+	if f.Sources[0].Retries != nil {
+		t.Errorf("Parse returned retries for a source without them")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		"sources: [",
+		"sources:\n  - adress: ups1\n",
+		"defaults:\n  interval: [1m]\n",
+		"unknown: true\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse accepted %q", data)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "power.yaml")
+	if err := ioutil.WriteFile(path, []byte(example), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	if len(f.Sources) != 2 {
+		t.Fatalf("Load returned %d sources, want 2", len(f.Sources))
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("Load of a missing file returned %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// Settings used when neither the configuration file nor the overrides
+// provide them.
+var (
+	DefaultStatistics = []string{"all"}
+	DefaultRecipients = []string{"console"}
+)
+
+// Overrides hold settings that take precedence over those in a configuration
+// file, such as settings provided by environment variables and flags. Empty
+// values are ignored.
+type Overrides struct {
+	Sources []string // Source definitions that replace the sources in the file
+	Settings
+}
+
+// Target is a source along with the settings needed to poll it.
+type Target struct {
+	Source          power.Source
+	Interval        time.Duration // Polling interval, zero for a single execution
+	BatteryInterval time.Duration // Polling interval while on battery, zero to use Interval
+	Statistics      []power.Statistic
+	Recipients      []string // Recipient definitions
+}
+
+// Targets returns a target for each source in the configuration file.
+//
+// Each setting is taken from the overrides, the source, or the file defaults,
+// in that order of precedence. A community embedded in a source address takes
+// precedence over all other community settings.
+func (f *File) Targets(o Overrides) (targets []Target, err error) {
+	sources := f.Sources
+	if len(o.Sources) > 0 {
+		sources = make([]Source, 0, len(o.Sources))
+		for _, address := range o.Sources {
+			sources = append(sources, Source{Address: address})
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("no sources specified")
+	}
+
+	for i, s := range sources {
+		target, tErr := f.target(s, merge(f.Defaults, s.Settings, o.Settings))
+		if tErr != nil {
+			return nil, fmt.Errorf("source %d: %v", i, tErr)
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// target returns the target for s with the given settings.
+func (f *File) target(s Source, settings Settings) (t Target, err error) {
+	address := s.Address
+	if address == "" {
+		address = s.Host
+	}
+	if address == "" {
+		return t, errors.New("no address or host specified")
+	}
+
+	t.Source, err = power.ParseSource(address)
+	if err != nil {
+		return
+	}
+	if s.Host != "" {
+		t.Source.Host = s.Host
+	}
+	if s.Name != "" {
+		t.Source.Name = s.Name
+	}
+	if s.Port != "" {
+		t.Source.Port = s.Port
+	}
+
+	hasCommunity := strings.Contains(strings.SplitN(address, "~", 2)[0], "@")
+	if !hasCommunity && settings.Community != "" && t.Source.IsSNMP() {
+		t.Source.Community = settings.Community
+	}
+
+	// Credentials embedded in a source URL are kept unless overridden
+	if settings.Credentials != (Credentials{}) {
+		t.Source.Credentials = power.Credentials(settings.Credentials)
+	}
+
+	if settings.Retries != nil {
+		t.Source.Retries = *settings.Retries
+	}
+
+	if settings.Timeout != "" {
+		if t.Source.Timeout, err = time.ParseDuration(settings.Timeout); err != nil {
+			return t, fmt.Errorf("unable to parse timeout: %v", err)
+		}
+	}
+
+	if settings.Interval != "" {
+		if t.Interval, err = time.ParseDuration(settings.Interval); err != nil {
+			return t, fmt.Errorf("unable to parse interval: %v", err)
+		}
+	}
+
+	if settings.BatteryInterval != "" {
+		if t.BatteryInterval, err = time.ParseDuration(settings.BatteryInterval); err != nil {
+			return t, fmt.Errorf("unable to parse battery interval: %v", err)
+		}
+	}
+
+	statistics := settings.Statistics
+	if len(statistics) == 0 {
+		statistics = DefaultStatistics
+	}
+	if t.Statistics, err = f.statistics(statistics); err != nil {
+		return
+	}
+
+	recipients := settings.Recipients
+	if len(recipients) == 0 {
+		recipients = DefaultRecipients
+	}
+	for _, recipient := range recipients {
+		if definition, ok := f.Recipients[recipient]; ok {
+			recipient = definition
+		}
+		t.Recipients = append(t.Recipients, recipient)
+	}
+
+	return
+}
+
+// statistics parses the given statistic list. Each item may refer to a named
+// statistic definition within the file or be any value accepted by
+// power.ParseStatistics.
+func (f *File) statistics(items []string) (stats []power.Statistic, err error) {
+	for _, item := range items {
+		if definition, ok := f.Statistics[item]; ok {
+			stat, pErr := power.ParseStatistic(definition)
+			if pErr != nil {
+				return nil, fmt.Errorf("unable to parse statistic definition \"%s\": %v", item, pErr)
+			}
+			if stat.Name == "" {
+				stat.Name = item
+			}
+			stats = append(stats, stat)
+			continue
+		}
+
+		parsed, pErr := power.ParseStatistics([]string{item})
+		if pErr != nil {
+			return nil, pErr
+		}
+		stats = append(stats, parsed...)
+	}
+	return
+}
+
+// merge returns the combination of the given settings. Non-empty values in
+// later settings take precedence over those in earlier settings.
+func merge(layers ...Settings) (s Settings) {
+	for _, layer := range layers {
+		if layer.Community != "" {
+			s.Community = layer.Community
+		}
+		if layer.Credentials != (Credentials{}) {
+			s.Credentials = layer.Credentials
+		}
+		if layer.Timeout != "" {
+			s.Timeout = layer.Timeout
+		}
+		if layer.Retries != nil {
+			s.Retries = layer.Retries
+		}
+		if layer.Interval != "" {
+			s.Interval = layer.Interval
+		}
+		if layer.BatteryInterval != "" {
+			s.BatteryInterval = layer.BatteryInterval
+		}
+		if len(layer.Statistics) > 0 {
+			s.Statistics = layer.Statistics
+		}
+		if len(layer.Recipients) > 0 {
+			s.Recipients = layer.Recipients
+		}
+	}
+	return
+}
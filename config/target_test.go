@@ -0,0 +1,241 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// names returns the names of the given statistics.
+func names(stats []power.Statistic) string {
+	var names []string
+	for _, stat := range stats {
+		names = append(names, stat.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// set returns the names of the statistics in the named statistic set.
+func set(t *testing.T, name string) string {
+	t.Helper()
+	stats, err := power.ParseStatistics([]string{name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names(stats)
+}
+
+// retries returns a pointer to n.
+func retries(n uint) *uint {
+	return &n
+}
+
+func TestTargets(t *testing.T) {
+	f, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := f.Targets(Overrides{})
+	if err != nil {
+		t.Fatalf("Targets returned %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("Targets returned %d targets, want 2", len(targets))
+	}
+
+	// The first source takes its settings from the defaults
+	ups := targets[0]
+	if ups.Source.Host != "lcy-rack2n-ups" || ups.Source.Port != "161" || ups.Source.Community != "tripplite" {
+		t.Errorf("first source is %+v", ups.Source)
+	}
+	if ups.Source.Retries != power.DefaultRetries || ups.Source.Timeout != power.DefaultTimeout {
+		t.Errorf("first source has %d retries and a %s timeout", ups.Source.Retries, ups.Source.Timeout)
+	}
+	if ups.Interval != time.Minute || ups.BatteryInterval != 5*time.Second {
+		t.Errorf("first source has intervals %s and %s", ups.Interval, ups.BatteryInterval)
+	}
+	if want := set(t, "ups"); names(ups.Statistics) != want {
+		t.Errorf("first source has statistics %s, want %s", names(ups.Statistics), want)
+	}
+	if strings.Join(ups.Recipients, ",") != "console,stathat:EZKEY" {
+		t.Errorf("first source has recipients %q", ups.Recipients)
+	}
+
+	// The second source overrides them
+	pdu := targets[1]
+	if pdu.Source.Host != "lcy-rack2s-pdu" || pdu.Source.Community != "private" || pdu.Source.Retries != 0 {
+		t.Errorf("second source is %+v", pdu.Source)
+	}
+	if pdu.Source.Credentials != (power.Credentials{Username: "monitor", Password: "authpass", AuthProtocol: "SHA"}) {
+		t.Errorf("second source has credentials %+v", pdu.Source.Credentials)
+	}
+	if pdu.Interval != 5*time.Minute || pdu.BatteryInterval != 5*time.Second {
+		t.Errorf("second source has intervals %s and %s", pdu.Interval, pdu.BatteryInterval)
+	}
+	if stats := names(pdu.Statistics); !strings.HasSuffix(stats, ",WidgetPower") || strings.Contains(stats, power.EstimatedChargeRemaining.Name) {
+		t.Errorf("second source has statistics %s", stats)
+	}
+	if strings.Join(pdu.Recipients, ",") != "console" {
+		t.Errorf("second source has recipients %q", pdu.Recipients)
+	}
+}
+
+func TestTargetsSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		want   power.Source
+		fail   bool
+	}{
+		{
+			name:   "address",
+			source: Source{Address: "private@ups1:1161~Rack"},
+			want:   power.Source{Host: "ups1", Port: "1161", Name: "Rack", Community: "private"},
+		},
+		{
+			name:   "host",
+			source: Source{Host: "ups1", Port: "1161", Name: "Rack"},
+			want:   power.Source{Host: "ups1", Port: "1161", Name: "Rack", Community: "default"},
+		},
+		{
+			name:   "address and host",
+			source: Source{Address: "private@ups1~Rack", Host: "ups2"},
+			want:   power.Source{Host: "ups2", Port: "161", Name: "Rack", Community: "private"},
+		},
+		{
+			name:   "address and fields",
+			source: Source{Address: "snmp://private@ups1:1161~Rack", Host: "ups2", Port: "2161", Name: "Row"},
+			want:   power.Source{Host: "ups2", Port: "2161", Name: "Row", Community: "private"},
+		},
+		{
+			name:   "community setting",
+			source: Source{Address: "ups1", Settings: Settings{Community: "source"}},
+			want:   power.Source{Host: "ups1", Port: "161", Community: "source"},
+		},
+		{name: "missing", source: Source{Name: "Rack"}, fail: true},
+		{name: "invalid", source: Source{Address: "ups1:port:161"}, fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &File{
+				Defaults: Settings{Community: "default"},
+				Sources:  []Source{test.source},
+			}
+			targets, err := f.Targets(Overrides{})
+			if test.fail {
+				if err == nil {
+					t.Fatalf("Targets returned %+v, want an error", targets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Targets returned %v", err)
+			}
+
+			got := targets[0].Source
+			got.Retries, got.Timeout = 0, 0
+			if got != test.want {
+				t.Fatalf("Targets returned %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTargetsOverrides(t *testing.T) {
+	f, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Override settings take precedence over the source and the defaults
+	targets, err := f.Targets(Overrides{Settings: Settings{
+		Community:  "override",
+		Timeout:    "2s",
+		Retries:    retries(3),
+		Interval:   "30s",
+		Statistics: []string{"OutputPercentLoad"},
+		Recipients: []string{"stathat"},
+	}})
+	if err != nil {
+		t.Fatalf("Targets returned %v", err)
+	}
+	for _, target := range targets {
+		if target.Source.Community != "override" || target.Source.Timeout != 2*time.Second || target.Source.Retries != 3 {
+			t.Errorf("%s: source is %+v", target.Source.Host, target.Source)
+		}
+		if target.Interval != 30*time.Second || target.BatteryInterval != 5*time.Second {
+			t.Errorf("%s: intervals are %s and %s", target.Source.Host, target.Interval, target.BatteryInterval)
+		}
+		if names(target.Statistics) != "OutputPercentLoad" {
+			t.Errorf("%s: statistics are %s", target.Source.Host, names(target.Statistics))
+		}
+		if strings.Join(target.Recipients, ",") != "stathat:EZKEY" {
+			t.Errorf("%s: recipients are %q", target.Source.Host, target.Recipients)
+		}
+	}
+
+	// Override sources replace the sources in the file, but a community in
+	// their address is kept
+	targets, err = f.Targets(Overrides{Sources: []string{"ups3", "secret@ups4"}})
+	if err != nil {
+		t.Fatalf("Targets returned %v", err)
+	}
+	if len(targets) != 2 || targets[0].Source.Host != "ups3" || targets[1].Source.Host != "ups4" {
+		t.Fatalf("Targets returned %+v", targets)
+	}
+	if targets[0].Source.Community != "tripplite" || targets[1].Source.Community != "secret" {
+		t.Fatalf("Targets returned communities %s and %s", targets[0].Source.Community, targets[1].Source.Community)
+	}
+}
+
+func TestTargetsDefaults(t *testing.T) {
+	f := &File{Sources: []Source{{Address: "ups1"}}}
+	targets, err := f.Targets(Overrides{})
+	if err != nil {
+		t.Fatalf("Targets returned %v", err)
+	}
+
+	target := targets[0]
+	if target.Source.Community != power.DefaultCommunity || target.Interval != 0 || target.BatteryInterval != 0 {
+		t.Errorf("Targets returned %+v", target)
+	}
+	if want := set(t, "ups"); names(target.Statistics) != want {
+		t.Errorf("Targets returned statistics %s, want %s", names(target.Statistics), want)
+	}
+	if strings.Join(target.Recipients, ",") != strings.Join(DefaultRecipients, ",") {
+		t.Errorf("Targets returned recipients %q", target.Recipients)
+	}
+
+	if _, err := (&File{}).Targets(Overrides{}); err == nil {
+		t.Error("Targets succeeded without sources")
+	}
+}
+
+func TestTargetsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file File
+	}{
+		{name: "timeout", file: File{Defaults: Settings{Timeout: "soon"}}},
+		{name: "interval", file: File{Defaults: Settings{Interval: "1 minute"}}},
+		{name: "battery interval", file: File{Defaults: Settings{BatteryInterval: "5"}}},
+		{name: "statistic", file: File{Defaults: Settings{Statistics: []string{"NoSuchStatistic"}}}},
+		{name: "statistic definition", file: File{
+			Defaults:   Settings{Statistics: []string{"Widget"}},
+			Statistics: map[string]string{"Widget": "oid:not.an.oid"},
+		}},
+	}
+
+	for _, test := range tests {
+		test.file.Sources = []Source{{Address: "ups1"}}
+		if targets, err := test.file.Targets(Overrides{}); err == nil {
+			t.Errorf("%s: Targets returned %+v, want an error", test.name, targets)
+		} else if !strings.HasPrefix(err.Error(), "source 0: ") {
+			t.Errorf("%s: Targets returned %v, want an error for source 0", test.name, err)
+		}
+	}
+}
@@ -18,6 +18,10 @@ func Parse(address string) (power.Recipient, error) {
 
 func (r recipient) Send(v power.Value) {
 	//fmt.Fprintf(r.w, r.format, v.Stat.Name, v)
+	if instance := v.Instance(); instance != "" {
+		fmt.Printf("  %s [%s]: %s\n", v.Stat.Name, instance, v)
+		return
+	}
 	fmt.Printf("  %s: %s\n", v.Stat.Name, v)
 }
 
@@ -25,6 +29,14 @@ func (r recipient) SendSource(i int, s power.Source) {
 	fmt.Printf("Source %d (%s):\n", i, s)
 }
 
+func (r recipient) SendIdentity(i int, s power.Source, id power.Identity) {
+	fmt.Printf("  Device: %s\n", id)
+}
+
 func (r recipient) SendQueryError(i int, s power.Source, err error) {
 	fmt.Printf("  Error: %v\n", err)
 }
+
+func (r recipient) SendEvent(e power.Event) {
+	fmt.Printf("Event from %s: %s\n", e.Source, e)
+}
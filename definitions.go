@@ -18,6 +18,31 @@ const (
 	OutputSourceReducer
 )
 
+// Labels for enumerated statistic values
+var (
+	yesNoLabels = map[int]string{
+		0: "no",
+		1: "yes",
+	}
+	outputSourceLabels = map[int]string{
+		OutputSourceOther:   "other",
+		OutputSourceNone:    "none",
+		OutputSourceNormal:  "normal",
+		OutputSourceBypass:  "bypass",
+		OutputSourceBattery: "battery",
+		OutputSourceBooster: "booster",
+		OutputSourceReducer: "reducer",
+	}
+)
+
+// upsOutputSource is shared by all of the statistics derived from the UPS
+// output source, which allows them to be retrieved with a single SNMP fetch.
+var upsOutputSource = snmpgo.Oids{
+	snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.1.0"),
+	apcBasicOutputStatus,
+	eatonOutputSource,
+}
+
 var (
 	statMap  = make(map[string]Statistic)
 	statList []Statistic
@@ -34,7 +59,10 @@ func init() {
 	registerStat(EstimatedChargeRemaining)
 	registerStat(BatteryVoltage)
 	registerStat(BatteryTemperature)
+	registerStat(OutputSource)
 	registerStat(OnBattery)
+	registerStat(OnBypass)
+	registerStat(Regulating)
 	registerStat(InputVoltage)
 	registerStat(InputCurrent)
 	registerStat(OutputVoltage)
@@ -46,70 +74,161 @@ func init() {
 // Preconfigured power management statistics
 var (
 	EstimatedMinutesRemaining = Statistic{
-		Name:   "EstimatedMinutesRemaining",
-		Unit:   "minutes",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.3.0")},
-		Mapper: snmpvar.Ident,
+		Name: "EstimatedMinutesRemaining",
+		Unit: "minutes",
+		OID: snmpgo.Oids{
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.3.0"),
+			apcAdvBatteryRunTimeRemaining,
+			eatonBatTimeRemaining,
+			trippLiteRunTimeRemaining,
+		},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{nil, apcTicksToMinutes, snmpvar.Div(60)},
 	}
 	EstimatedChargeRemaining = Statistic{
-		Name:   "EstimatedChargeRemaining",
-		Unit:   "%",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.4.0")},
-		Mapper: snmpvar.Ident,
+		Name: "EstimatedChargeRemaining",
+		Unit: "%",
+		OID: snmpgo.Oids{
+			apcHighPrecBatteryCapacity,
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.4.0"),
+			apcAdvBatteryCapacity,
+			eatonBatCapacity,
+			trippLiteEstimatedChargeRemaining,
+		},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
 	}
 	BatteryVoltage = Statistic{
-		Name:   "BatteryVoltage",
-		Unit:   "volts (DC)",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.5.0")},
-		Mapper: snmpvar.Div(10),
+		Name: "BatteryVoltage",
+		Unit: "volts (DC)",
+		OID: snmpgo.Oids{
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.5.0"),
+			apcHighPrecBatteryActualVoltage,
+			apcAdvBatteryActualVoltage,
+			eatonBatVoltage,
+			trippLiteBatteryVoltage,
+		},
+		Mapper:  snmpvar.Div(10),
+		Mappers: []snmpvar.Float64{nil, nil, snmpvar.Ident, snmpvar.Ident},
 	}
 	BatteryTemperature = Statistic{
-		Name:   "BatteryTemperature",
-		Unit:   "°C",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.7.0")},
-		Mapper: snmpvar.Ident,
+		Name: "BatteryTemperature",
+		Unit: "°C",
+		OID: snmpgo.Oids{
+			apcHighPrecBatteryTemperature,
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.2.7.0"),
+			apcAdvBatteryTemperature,
+			trippLiteBatteryTemperatureC,
+		},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
 	}
 	InputVoltage = Statistic{
-		Name:   "InputVoltage",
-		Unit:   "volts",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.3.3.1.3.1")},
-		Mapper: snmpvar.Ident,
+		Name: "InputVoltage",
+		Unit: "volts",
+		OID: snmpgo.Oids{
+			apcHighPrecInputLineVoltage,
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.3.3.1.3.1"),
+			apcAdvInputLineVoltage,
+			eatonInputVoltage,
+		},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
 	}
 	InputCurrent = Statistic{
-		Name:   "InputCurrent",
-		Unit:   "volts",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.3.3.1.4.1")},
+		Name: "InputCurrent",
+		Unit: "volts",
+		OID: snmpgo.Oids{
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.3.3.1.4.1"),
+			eatonInputCurrent,
+		},
+		Mapper: snmpvar.Ident,
+	}
+	OutputSource = Statistic{
+		Name:   "OutputSource",
+		Unit:   "source",
+		OID:    upsOutputSource,
 		Mapper: snmpvar.Ident,
+		Mappers: []snmpvar.Float64{
+			nil,
+			snmpvar.Lookup(apcOutputStatusSources, OutputSourceOther),
+			snmpvar.Lookup(eatonOutputSources, OutputSourceOther),
+		},
+		Enum: outputSourceLabels,
 	}
 	OnBattery = Statistic{
-		Name:   "OnBattery",
+		Name:    "OnBattery",
+		Unit:    "yes/no",
+		OID:     upsOutputSource,
+		Mapper:  snmpvar.Match(OutputSourceBattery),
+		Mappers: []snmpvar.Float64{nil, snmpvar.Match(apcOutputStatusOnBattery)},
+		Enum:    yesNoLabels,
+	}
+	OnBypass = Statistic{
+		Name:   "OnBypass",
 		Unit:   "yes/no",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.1.0")},
+		OID:    upsOutputSource,
 		Mapper: snmpvar.Match(OutputSourceBypass),
+		Mappers: []snmpvar.Float64{
+			nil,
+			snmpvar.Match(apcOutputStatusSoftwareBypass, apcOutputStatusSwitchedBypass, apcOutputStatusHardwareFailureBypass),
+			snmpvar.Match(OutputSourceBypass, eatonOutputSourceMaintenanceBypass),
+		},
+		Enum: yesNoLabels,
+	}
+	Regulating = Statistic{
+		Name:    "Regulating",
+		Unit:    "yes/no",
+		OID:     upsOutputSource,
+		Mapper:  snmpvar.Match(OutputSourceBooster, OutputSourceReducer),
+		Mappers: []snmpvar.Float64{nil, snmpvar.Match(apcOutputStatusOnSmartBoost, apcOutputStatusOnSmartTrim)},
+		Enum:    yesNoLabels,
 	}
 	OutputVoltage = Statistic{
-		Name:   "OutputVoltage",
-		Unit:   "volts",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.2.1")},
-		Mapper: snmpvar.Ident,
+		Name: "OutputVoltage",
+		Unit: "volts",
+		OID: snmpgo.Oids{
+			apcHighPrecOutputVoltage,
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.2.1"),
+			apcAdvOutputVoltage,
+			eatonOutputVoltage,
+		},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
 	}
 	OutputCurrent = Statistic{
-		Name:   "OutputCurrent",
-		Unit:   "amps",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.3.1")},
-		Mapper: snmpvar.Div(10),
+		Name: "OutputCurrent",
+		Unit: "amps",
+		OID: snmpgo.Oids{
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.3.1"),
+			apcHighPrecOutputCurrent,
+			apcAdvOutputCurrent,
+			eatonOutputCurrent,
+		},
+		Mapper:  snmpvar.Div(10),
+		Mappers: []snmpvar.Float64{nil, nil, snmpvar.Ident, snmpvar.Ident},
 	}
 	OutputPower = Statistic{
-		Name:   "OutputPower",
-		Unit:   "watts",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.4.1")},
+		Name: "OutputPower",
+		Unit: "watts",
+		OID: snmpgo.Oids{
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.4.1"),
+			eatonOutputWatts,
+		},
 		Mapper: snmpvar.Ident,
 	}
 	OutputPercentLoad = Statistic{
-		Name:   "OutputPercentLoad",
-		Unit:   "%",
-		OID:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.5.1")},
-		Mapper: snmpvar.Ident,
+		Name: "OutputPercentLoad",
+		Unit: "%",
+		OID: snmpgo.Oids{
+			apcHighPrecOutputLoad,
+			snmpgo.MustNewOid("1.3.6.1.2.1.33.1.4.4.1.5.1"),
+			apcAdvOutputLoad,
+			eatonOutputLoad,
+			trippLiteOutputPercentLoad,
+		},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
 	}
 )
 
@@ -0,0 +1,137 @@
+package power
+
+import (
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power/snmpvar"
+)
+
+// APC PowerNet-MIB object identifiers
+//
+// The high precision objects report values in tenths of their unit and are
+// preferred over their advanced counterparts when an agent supports them.
+var (
+	apcAdvBatteryCapacity           = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.2.1.0")
+	apcAdvBatteryTemperature        = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.2.2.0")
+	apcAdvBatteryRunTimeRemaining   = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.2.3.0")
+	apcAdvBatteryReplaceIndicator   = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.2.4.0")
+	apcAdvBatteryActualVoltage      = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.2.8.0")
+	apcHighPrecBatteryCapacity      = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.3.1.0")
+	apcHighPrecBatteryTemperature   = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.3.2.0")
+	apcHighPrecBatteryActualVoltage = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.2.3.4.0")
+	apcAdvInputLineVoltage          = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.3.2.1.0")
+	apcAdvInputFrequency            = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.3.2.4.0")
+	apcAdvInputLineFailCause        = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.3.2.5.0")
+	apcHighPrecInputLineVoltage     = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.3.3.1.0")
+	apcHighPrecInputFrequency       = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.3.3.4.0")
+	apcBasicOutputStatus            = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.1.1.0")
+	apcAdvOutputVoltage             = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.2.1.0")
+	apcAdvOutputLoad                = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.2.3.0")
+	apcAdvOutputCurrent             = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.2.4.0")
+	apcHighPrecOutputVoltage        = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.3.1.0")
+	apcHighPrecOutputLoad           = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.3.3.0")
+	apcHighPrecOutputCurrent        = VendorAPC.oid("1.3.6.1.4.1.318.1.1.1.4.3.4.0")
+)
+
+// APC upsBasicOutputStatus enumeration
+const (
+	apcOutputStatusUnknown = iota + 1
+	apcOutputStatusOnLine
+	apcOutputStatusOnBattery
+	apcOutputStatusOnSmartBoost
+	apcOutputStatusTimedSleeping
+	apcOutputStatusSoftwareBypass
+	apcOutputStatusOff
+	apcOutputStatusRebooting
+	apcOutputStatusSwitchedBypass
+	apcOutputStatusHardwareFailureBypass
+	apcOutputStatusSleepingUntilPowerReturn
+	apcOutputStatusOnSmartTrim
+)
+
+// apcOutputStatusSources maps APC output status values onto the output source
+// enumeration used by UPS-MIB.
+var apcOutputStatusSources = map[int]float64{
+	apcOutputStatusOnLine:                   OutputSourceNormal,
+	apcOutputStatusOnBattery:                OutputSourceBattery,
+	apcOutputStatusOnSmartBoost:             OutputSourceBooster,
+	apcOutputStatusTimedSleeping:            OutputSourceNone,
+	apcOutputStatusSoftwareBypass:           OutputSourceBypass,
+	apcOutputStatusOff:                      OutputSourceNone,
+	apcOutputStatusSwitchedBypass:           OutputSourceBypass,
+	apcOutputStatusHardwareFailureBypass:    OutputSourceBypass,
+	apcOutputStatusSleepingUntilPowerReturn: OutputSourceNone,
+	apcOutputStatusOnSmartTrim:              OutputSourceReducer,
+}
+
+// apcLineFailCauseLabels are the labels for the APC upsAdvInputLineFailCause
+// enumeration.
+var apcLineFailCauseLabels = map[int]string{
+	1:  "noTransfer",
+	2:  "highLineVoltage",
+	3:  "brownout",
+	4:  "blackout",
+	5:  "smallMomentarySag",
+	6:  "deepMomentarySag",
+	7:  "smallMomentarySpike",
+	8:  "largeMomentarySpike",
+	9:  "selfTest",
+	10: "rateOfVoltageChange",
+}
+
+// apcTicksToMinutes converts APC run time values, which are reported in
+// hundredths of a second, to minutes.
+var apcTicksToMinutes = snmpvar.Div(100 * 60)
+
+func init() {
+	registerStat(APCBatteryCapacity)
+	registerStat(APCRuntimeRemaining)
+	registerStat(APCInternalTemperature)
+	registerStat(APCReplaceBattery)
+	registerStat(APCInputFrequency)
+	registerStat(APCLineFailCause)
+}
+
+// Preconfigured APC PowerNet-MIB statistics
+var (
+	APCBatteryCapacity = Statistic{
+		Name:    "APCBatteryCapacity",
+		Unit:    "%",
+		OID:     snmpgo.Oids{apcHighPrecBatteryCapacity, apcAdvBatteryCapacity},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
+	}
+	APCRuntimeRemaining = Statistic{
+		Name:   "APCRuntimeRemaining",
+		Unit:   "minutes",
+		OID:    snmpgo.Oids{apcAdvBatteryRunTimeRemaining},
+		Mapper: apcTicksToMinutes,
+	}
+	APCInternalTemperature = Statistic{
+		Name:    "APCInternalTemperature",
+		Unit:    "°C",
+		OID:     snmpgo.Oids{apcHighPrecBatteryTemperature, apcAdvBatteryTemperature},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
+	}
+	APCReplaceBattery = Statistic{
+		Name:   "APCReplaceBattery",
+		Unit:   "yes/no",
+		OID:    snmpgo.Oids{apcAdvBatteryReplaceIndicator},
+		Mapper: snmpvar.Match(2), // batteryNeedsReplacing
+		Enum:   yesNoLabels,
+	}
+	APCInputFrequency = Statistic{
+		Name:    "APCInputFrequency",
+		Unit:    "Hz",
+		OID:     snmpgo.Oids{apcHighPrecInputFrequency, apcAdvInputFrequency},
+		Mapper:  snmpvar.Ident,
+		Mappers: []snmpvar.Float64{snmpvar.Div(10)},
+	}
+	APCLineFailCause = Statistic{
+		Name:   "APCLineFailCause",
+		Unit:   "cause",
+		OID:    snmpgo.Oids{apcAdvInputLineFailCause},
+		Mapper: snmpvar.Ident,
+		Enum:   apcLineFailCauseLabels,
+	}
+)
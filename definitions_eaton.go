@@ -0,0 +1,131 @@
+package power
+
+import (
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power/snmpvar"
+)
+
+// Eaton XUPS-MIB object identifiers
+var (
+	eatonBatTimeRemaining   = VendorEaton.oid("1.3.6.1.4.1.534.1.2.1.0")
+	eatonBatVoltage         = VendorEaton.oid("1.3.6.1.4.1.534.1.2.2.0")
+	eatonBatCurrent         = VendorEaton.oid("1.3.6.1.4.1.534.1.2.3.0")
+	eatonBatCapacity        = VendorEaton.oid("1.3.6.1.4.1.534.1.2.4.0")
+	eatonBatteryAbmStatus   = VendorEaton.oid("1.3.6.1.4.1.534.1.2.5.0")
+	eatonInputFrequency     = VendorEaton.oid("1.3.6.1.4.1.534.1.3.1.0")
+	eatonInputVoltage       = VendorEaton.oid("1.3.6.1.4.1.534.1.3.4.1.2.1")
+	eatonInputCurrent       = VendorEaton.oid("1.3.6.1.4.1.534.1.3.4.1.3.1")
+	eatonOutputLoad         = VendorEaton.oid("1.3.6.1.4.1.534.1.4.1.0")
+	eatonOutputFrequency    = VendorEaton.oid("1.3.6.1.4.1.534.1.4.2.0")
+	eatonOutputVoltage      = VendorEaton.oid("1.3.6.1.4.1.534.1.4.4.1.2.1")
+	eatonOutputCurrent      = VendorEaton.oid("1.3.6.1.4.1.534.1.4.4.1.3.1")
+	eatonOutputWatts        = VendorEaton.oid("1.3.6.1.4.1.534.1.4.4.1.4.1")
+	eatonOutputSource       = VendorEaton.oid("1.3.6.1.4.1.534.1.4.5.0")
+	eatonEnvAmbientTemp     = VendorEaton.oid("1.3.6.1.4.1.534.1.6.1.0")
+	eatonEnvAmbientHumidity = VendorEaton.oid("1.3.6.1.4.1.534.1.6.4.0")
+	eatonEnvRemoteTemp      = VendorEaton.oid("1.3.6.1.4.1.534.1.6.5.0")
+	eatonEnvRemoteHumidity  = VendorEaton.oid("1.3.6.1.4.1.534.1.6.6.0")
+)
+
+// Eaton xupsOutputSource values that extend the UPS-MIB enumeration
+const (
+	eatonOutputSourceParallelCapacity = iota + OutputSourceReducer + 1
+	eatonOutputSourceParallelRedundant
+	eatonOutputSourceHighEfficiencyMode
+	eatonOutputSourceMaintenanceBypass
+	eatonOutputSourceESSMode
+)
+
+// eatonOutputSources maps Eaton xupsOutputSource values onto the output source
+// enumeration used by UPS-MIB. The first seven values are identical.
+var eatonOutputSources = map[int]float64{
+	OutputSourceOther:                   OutputSourceOther,
+	OutputSourceNone:                    OutputSourceNone,
+	OutputSourceNormal:                  OutputSourceNormal,
+	OutputSourceBypass:                  OutputSourceBypass,
+	OutputSourceBattery:                 OutputSourceBattery,
+	OutputSourceBooster:                 OutputSourceBooster,
+	OutputSourceReducer:                 OutputSourceReducer,
+	eatonOutputSourceParallelCapacity:   OutputSourceNormal,
+	eatonOutputSourceParallelRedundant:  OutputSourceNormal,
+	eatonOutputSourceHighEfficiencyMode: OutputSourceNormal,
+	eatonOutputSourceMaintenanceBypass:  OutputSourceBypass,
+	eatonOutputSourceESSMode:            OutputSourceNormal,
+}
+
+// eatonBatteryStatusLabels are the labels for the Eaton xupsBatteryAbmStatus
+// enumeration.
+var eatonBatteryStatusLabels = map[int]string{
+	1: "charging",
+	2: "discharging",
+	3: "floating",
+	4: "resting",
+	5: "unknown",
+	6: "disconnected",
+	7: "underTest",
+	8: "checkBattery",
+}
+
+func init() {
+	registerStat(EatonBatteryCurrent)
+	registerStat(EatonBatteryStatus)
+	registerStat(EatonInputFrequency)
+	registerStat(EatonOutputFrequency)
+	registerStat(EatonAmbientTemperature)
+	registerStat(EatonAmbientHumidity)
+	registerStat(EatonRemoteTemperature)
+	registerStat(EatonRemoteHumidity)
+}
+
+// Preconfigured Eaton XUPS-MIB statistics
+var (
+	EatonBatteryCurrent = Statistic{
+		Name:   "EatonBatteryCurrent",
+		Unit:   "amps (DC)",
+		OID:    snmpgo.Oids{eatonBatCurrent},
+		Mapper: snmpvar.Ident,
+	}
+	EatonBatteryStatus = Statistic{
+		Name:   "EatonBatteryStatus",
+		Unit:   "status",
+		OID:    snmpgo.Oids{eatonBatteryAbmStatus},
+		Mapper: snmpvar.Ident,
+		Enum:   eatonBatteryStatusLabels,
+	}
+	EatonInputFrequency = Statistic{
+		Name:   "EatonInputFrequency",
+		Unit:   "Hz",
+		OID:    snmpgo.Oids{eatonInputFrequency},
+		Mapper: snmpvar.Div(10),
+	}
+	EatonOutputFrequency = Statistic{
+		Name:   "EatonOutputFrequency",
+		Unit:   "Hz",
+		OID:    snmpgo.Oids{eatonOutputFrequency},
+		Mapper: snmpvar.Div(10),
+	}
+	EatonAmbientTemperature = Statistic{
+		Name:   "EatonAmbientTemperature",
+		Unit:   "°C",
+		OID:    snmpgo.Oids{eatonEnvAmbientTemp},
+		Mapper: snmpvar.Ident,
+	}
+	EatonAmbientHumidity = Statistic{
+		Name:   "EatonAmbientHumidity",
+		Unit:   "%RH",
+		OID:    snmpgo.Oids{eatonEnvAmbientHumidity},
+		Mapper: snmpvar.Ident,
+	}
+	EatonRemoteTemperature = Statistic{
+		Name:   "EatonRemoteTemperature",
+		Unit:   "°C",
+		OID:    snmpgo.Oids{eatonEnvRemoteTemp},
+		Mapper: snmpvar.Ident,
+	}
+	EatonRemoteHumidity = Statistic{
+		Name:   "EatonRemoteHumidity",
+		Unit:   "%RH",
+		OID:    snmpgo.Oids{eatonEnvRemoteHumidity},
+		Mapper: snmpvar.Ident,
+	}
+)
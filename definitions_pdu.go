@@ -0,0 +1,111 @@
+package power
+
+import (
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power/snmpvar"
+)
+
+// APC PowerNet-MIB rPDU2 table columns
+var (
+	apcPDUBankStatusCurrent          = VendorAPC.oid("1.3.6.1.4.1.318.1.1.26.8.3.1.5")
+	apcPDUOutletSwitchedStatusName   = VendorAPC.oid("1.3.6.1.4.1.318.1.1.26.9.2.3.1.3")
+	apcPDUOutletSwitchedStatusState  = VendorAPC.oid("1.3.6.1.4.1.318.1.1.26.9.2.3.1.5")
+	apcPDUOutletMeteredStatusName    = VendorAPC.oid("1.3.6.1.4.1.318.1.1.26.9.4.3.1.3")
+	apcPDUOutletMeteredStatusCurrent = VendorAPC.oid("1.3.6.1.4.1.318.1.1.26.9.4.3.1.5")
+	apcPDUOutletMeteredStatusPower   = VendorAPC.oid("1.3.6.1.4.1.318.1.1.26.9.4.3.1.6")
+)
+
+// Raritan PDU-MIB outlet table columns
+var (
+	raritanOutletLabel            = VendorRaritan.oid("1.3.6.1.4.1.13742.4.1.2.2.1.2")
+	raritanOutletOperationalState = VendorRaritan.oid("1.3.6.1.4.1.13742.4.1.2.2.1.3")
+	raritanOutletCurrent          = VendorRaritan.oid("1.3.6.1.4.1.13742.4.1.2.2.1.4")
+	raritanOutletActivePower      = VendorRaritan.oid("1.3.6.1.4.1.13742.4.1.2.2.1.7")
+)
+
+// Server Technology Sentry3-MIB infeed and outlet table columns
+var (
+	sentryInfeedName      = VendorServerTech.oid("1.3.6.1.4.1.1718.3.2.2.1.3")
+	sentryInfeedLoadValue = VendorServerTech.oid("1.3.6.1.4.1.1718.3.2.2.1.7")
+	sentryOutletName      = VendorServerTech.oid("1.3.6.1.4.1.1718.3.2.3.1.3")
+	sentryOutletStatus    = VendorServerTech.oid("1.3.6.1.4.1.1718.3.2.3.1.5")
+	sentryOutletLoadValue = VendorServerTech.oid("1.3.6.1.4.1.1718.3.2.3.1.7")
+)
+
+// Outlet state values that indicate an outlet is switched on
+const (
+	apcPDUOutletSwitchedStatusStateOn = 2
+	raritanOutletStateOn              = 1
+	sentryOutletStatusOn              = 1
+)
+
+func init() {
+	registerStat(OutletCurrent)
+	registerStat(OutletPower)
+	registerStat(OutletOn)
+	registerStat(BankCurrent)
+}
+
+// Preconfigured switched and metered PDU statistics
+//
+// These are table statistics that produce a value for each outlet or bank.
+var (
+	OutletCurrent = Statistic{
+		Name:  "OutletCurrent",
+		Unit:  "amps",
+		Class: ClassPDU,
+		OID: snmpgo.Oids{
+			apcPDUOutletMeteredStatusCurrent,
+			raritanOutletCurrent,
+			sentryOutletLoadValue,
+		},
+		Mapper:   snmpvar.Div(10),
+		Mappers:  []snmpvar.Float64{nil, snmpvar.Div(1000), snmpvar.Div(100)},
+		Table:    true,
+		LabelOID: snmpgo.Oids{apcPDUOutletMeteredStatusName, raritanOutletLabel, sentryOutletName},
+	}
+	OutletPower = Statistic{
+		Name:  "OutletPower",
+		Unit:  "watts",
+		Class: ClassPDU,
+		OID: snmpgo.Oids{
+			apcPDUOutletMeteredStatusPower,
+			raritanOutletActivePower,
+		},
+		Mapper:   snmpvar.Ident,
+		Table:    true,
+		LabelOID: snmpgo.Oids{apcPDUOutletMeteredStatusName, raritanOutletLabel},
+	}
+	OutletOn = Statistic{
+		Name:  "OutletOn",
+		Unit:  "yes/no",
+		Class: ClassPDU,
+		OID: snmpgo.Oids{
+			apcPDUOutletSwitchedStatusState,
+			raritanOutletOperationalState,
+			sentryOutletStatus,
+		},
+		Mapper: snmpvar.Match(apcPDUOutletSwitchedStatusStateOn),
+		Mappers: []snmpvar.Float64{
+			nil,
+			snmpvar.Match(raritanOutletStateOn),
+			snmpvar.Match(sentryOutletStatusOn),
+		},
+		Enum:     yesNoLabels,
+		Table:    true,
+		LabelOID: snmpgo.Oids{apcPDUOutletSwitchedStatusName, raritanOutletLabel, sentryOutletName},
+	}
+	BankCurrent = Statistic{
+		Name:  "BankCurrent",
+		Unit:  "amps",
+		Class: ClassPDU,
+		OID: snmpgo.Oids{
+			apcPDUBankStatusCurrent,
+			sentryInfeedLoadValue,
+		},
+		Mapper:   snmpvar.Div(10),
+		Mappers:  []snmpvar.Float64{nil, snmpvar.Div(100)},
+		Table:    true,
+		LabelOID: snmpgo.Oids{nil, sentryInfeedName},
+	}
+)
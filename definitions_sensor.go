@@ -0,0 +1,90 @@
+package power
+
+import (
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power/snmpvar"
+)
+
+// APC PowerNet-MIB environmental monitoring table columns
+//
+// The uio tables describe probes attached to the universal I/O ports of
+// AP96xx network management cards. The iem tables describe probes attached
+// to older integrated environmental monitors, which report temperatures in
+// the unit configured on the card; Celsius is assumed.
+var (
+	apcUIOSensorStatusSensorName      = VendorAPC.oid("1.3.6.1.4.1.318.1.1.25.1.2.1.3")
+	apcUIOSensorStatusTemperatureDegC = VendorAPC.oid("1.3.6.1.4.1.318.1.1.25.1.2.1.6")
+	apcUIOSensorStatusHumidity        = VendorAPC.oid("1.3.6.1.4.1.318.1.1.25.1.2.1.7")
+	apcUIOInputContactStatusName      = VendorAPC.oid("1.3.6.1.4.1.318.1.1.25.2.2.1.3")
+	apcUIOInputContactStatusState     = VendorAPC.oid("1.3.6.1.4.1.318.1.1.25.2.2.1.5")
+	apcIEMStatusProbeName             = VendorAPC.oid("1.3.6.1.4.1.318.1.1.10.2.3.2.1.2")
+	apcIEMStatusProbeCurrentTemp      = VendorAPC.oid("1.3.6.1.4.1.318.1.1.10.2.3.2.1.4")
+	apcIEMStatusProbeCurrentHumidity  = VendorAPC.oid("1.3.6.1.4.1.318.1.1.10.2.3.2.1.6")
+)
+
+// Eaton XUPS-MIB contact sense table columns
+var (
+	eatonContactState = VendorEaton.oid("1.3.6.1.4.1.534.1.6.8.1.3")
+	eatonContactDescr = VendorEaton.oid("1.3.6.1.4.1.534.1.6.8.1.4")
+)
+
+// Contact state values that indicate a contact is open
+const (
+	apcUIOInputContactStateOpen     = 2
+	eatonContactStateOpen           = 1
+	eatonContactStateOpenWithNotice = 3
+)
+
+func init() {
+	registerStat(ProbeTemperature)
+	registerStat(ProbeHumidity)
+	registerStat(ContactOpen)
+}
+
+// Preconfigured environmental probe statistics
+//
+// These are table statistics that produce a value for each attached probe
+// or contact, labeled with the name configured for it on the device.
+var (
+	ProbeTemperature = Statistic{
+		Name:  "ProbeTemperature",
+		Unit:  "°C",
+		Class: ClassSensor,
+		OID: snmpgo.Oids{
+			apcUIOSensorStatusTemperatureDegC,
+			apcIEMStatusProbeCurrentTemp,
+		},
+		Mapper:   snmpvar.Ident,
+		Table:    true,
+		LabelOID: snmpgo.Oids{apcUIOSensorStatusSensorName, apcIEMStatusProbeName},
+	}
+	ProbeHumidity = Statistic{
+		Name:  "ProbeHumidity",
+		Unit:  "%RH",
+		Class: ClassSensor,
+		OID: snmpgo.Oids{
+			apcUIOSensorStatusHumidity,
+			apcIEMStatusProbeCurrentHumidity,
+		},
+		Mapper:   snmpvar.Ident,
+		Table:    true,
+		LabelOID: snmpgo.Oids{apcUIOSensorStatusSensorName, apcIEMStatusProbeName},
+	}
+	ContactOpen = Statistic{
+		Name:  "ContactOpen",
+		Unit:  "yes/no",
+		Class: ClassSensor,
+		OID: snmpgo.Oids{
+			apcUIOInputContactStatusState,
+			eatonContactState,
+		},
+		Mapper: snmpvar.Match(apcUIOInputContactStateOpen),
+		Mappers: []snmpvar.Float64{
+			nil,
+			snmpvar.Match(eatonContactStateOpen, eatonContactStateOpenWithNotice),
+		},
+		Enum:     yesNoLabels,
+		Table:    true,
+		LabelOID: snmpgo.Oids{apcUIOInputContactStatusName, eatonContactDescr},
+	}
+)
@@ -0,0 +1,75 @@
+package power
+
+import (
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power/snmpvar"
+)
+
+// Tripp Lite TRIPPLITE-PRODUCTS object identifiers
+//
+// The tables in TRIPPLITE-PRODUCTS are indexed by device. Only the first
+// device of each agent is queried.
+var (
+	trippLiteBatteryStatus            = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.1.1")
+	trippLiteSecondsOnBattery         = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.2.1")
+	trippLiteRunTimeRemaining         = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.3.1")
+	trippLiteEstimatedChargeRemaining = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.4.1")
+	trippLiteBatteryVoltage           = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.5.1")
+	trippLiteBatteryTemperatureC      = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.8.1")
+	trippLiteBatteryAge               = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.9.1")
+	trippLiteOutputPercentLoad        = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.3.1.3.3.2.1.5.1.1")
+	trippLiteEnvTemperatureC          = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.4.1.1.1.1.1.1")
+	trippLiteEnvHumidity              = VendorTrippLite.oid("1.3.6.1.4.1.850.1.1.4.1.1.1.1.3.1")
+)
+
+// trippLiteBatteryStatusLabels are the labels for the Tripp Lite
+// tlpUpsBatteryStatus enumeration.
+var trippLiteBatteryStatusLabels = map[int]string{
+	1: "unknown",
+	2: "batteryNormal",
+	3: "batteryLow",
+	4: "batteryDepleted",
+}
+
+func init() {
+	registerStat(TrippLiteBatteryStatus)
+	registerStat(TrippLiteSecondsOnBattery)
+	registerStat(TrippLiteBatteryAge)
+	registerStat(TrippLiteAmbientTemperature)
+	registerStat(TrippLiteAmbientHumidity)
+}
+
+// Preconfigured Tripp Lite TRIPPLITE-PRODUCTS statistics
+var (
+	TrippLiteBatteryStatus = Statistic{
+		Name:   "TrippLiteBatteryStatus",
+		Unit:   "status",
+		OID:    snmpgo.Oids{trippLiteBatteryStatus},
+		Mapper: snmpvar.Ident,
+		Enum:   trippLiteBatteryStatusLabels,
+	}
+	TrippLiteSecondsOnBattery = Statistic{
+		Name:   "TrippLiteSecondsOnBattery",
+		Unit:   "seconds",
+		OID:    snmpgo.Oids{trippLiteSecondsOnBattery},
+		Mapper: snmpvar.Ident,
+	}
+	TrippLiteBatteryAge = Statistic{
+		Name:   "TrippLiteBatteryAge",
+		Unit:   "months",
+		OID:    snmpgo.Oids{trippLiteBatteryAge},
+		Mapper: snmpvar.Ident,
+	}
+	TrippLiteAmbientTemperature = Statistic{
+		Name:   "TrippLiteAmbientTemperature",
+		Unit:   "°C",
+		OID:    snmpgo.Oids{trippLiteEnvTemperatureC},
+		Mapper: snmpvar.Ident,
+	}
+	TrippLiteAmbientHumidity = Statistic{
+		Name:   "TrippLiteAmbientHumidity",
+		Unit:   "%RH",
+		OID:    snmpgo.Oids{trippLiteEnvHumidity},
+		Mapper: snmpvar.Ident,
+	}
+)
@@ -0,0 +1,355 @@
+// Package dispatch isolates power recipients from one another.
+//
+// Each recipient wrapped by the package receives values from its own
+// goroutine through a bounded queue, so a slow or hung recipient cannot delay
+// polling or the delivery of values to other recipients. When a queue is full
+// values are either dropped or the sender is blocked, depending on the
+// configured policy. Calls that exceed the send timeout are abandoned and
+// calls that panic are recovered. Calls are never made concurrently: while an
+// abandoned call is still running, the calls that follow it are dropped.
+package dispatch
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// Policy determines what happens when a recipient's queue is full.
+type Policy int
+
+// Queue policies
+const (
+	Drop  Policy = iota // Discard the value
+	Block               // Wait for space in the queue
+)
+
+// String returns a string representation of the policy.
+func (p Policy) String() string {
+	switch p {
+	case Drop:
+		return "drop"
+	case Block:
+		return "block"
+	default:
+		return fmt.Sprintf("policy %d", int(p))
+	}
+}
+
+// ParsePolicy parses the given string as a queue policy.
+func ParsePolicy(s string) (Policy, error) {
+	switch strings.ToLower(s) {
+	case "drop":
+		return Drop, nil
+	case "block":
+		return Block, nil
+	default:
+		return Drop, fmt.Errorf("unknown queue policy: \"%s\"", s)
+	}
+}
+
+// DefaultOptions are the options used when none are specified.
+var DefaultOptions = Options{
+	QueueSize: 1000,
+	Policy:    Drop,
+	Timeout:   30 * time.Second,
+}
+
+// Options hold the dispatch settings for a recipient. A zero queue size or
+// timeout is replaced by the value in DefaultOptions.
+type Options struct {
+	QueueSize int           // Maximum number of calls waiting to be made
+	Policy    Policy        // Behavior when the queue is full
+	Timeout   time.Duration // Time allowed for each call
+	Logger    *log.Logger   // Defaults to a logger writing to stderr
+}
+
+// Stats hold dispatch counters for a recipient.
+type Stats struct {
+	Name     string
+	Queued   int    // Calls waiting to be made
+	Capacity int    // Size of the queue
+	Sent     uint64 // Calls that completed
+	Dropped  uint64 // Calls discarded because the queue was full or closed, or the recipient stalled
+	TimedOut uint64 // Calls abandoned after exceeding the timeout
+	Panics   uint64 // Calls that panicked
+	Stalled  bool   // Whether an abandoned call has yet to return
+}
+
+// call is a queued call to a recipient.
+type call func(r power.Recipient)
+
+// Recipient delivers values to another recipient from its own goroutine.
+//
+// A Recipient implements all of the optional recipient interfaces in the
+// power package. Calls are only forwarded when the wrapped recipient
+// implements the corresponding interface.
+//
+// A call that is abandoned after exceeding the timeout continues to run in
+// the background. The recipient is considered stalled until it returns, and
+// calls are dropped rather than made in the meantime.
+type Recipient struct {
+	name  string
+	inner power.Recipient
+	opts  Options
+
+	mu     sync.RWMutex
+	queue  chan call
+	closed bool
+	done   chan struct{}
+
+	abandoned <-chan bool // Completion of an abandoned call, nil if none is running
+	stalled   int32       // Set while an abandoned call is running
+
+	sent     uint64
+	dropped  uint64
+	timedOut uint64
+	panics   uint64
+}
+
+// New returns a recipient that delivers to r from its own goroutine. The
+// name identifies r in log messages and statistics.
+func New(name string, r power.Recipient, opts Options) *Recipient {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultOptions.QueueSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "dispatch: ", log.LstdFlags)
+	}
+
+	d := &Recipient{
+		name:  name,
+		inner: r,
+		opts:  opts,
+		queue: make(chan call, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Recipient returns the wrapped recipient.
+func (d *Recipient) Recipient() power.Recipient {
+	return d.inner
+}
+
+// Send queues the value for delivery.
+func (d *Recipient) Send(v power.Value) {
+	d.enqueue(func(r power.Recipient) {
+		r.Send(v)
+	})
+}
+
+// SendSource queues the source for delivery if the wrapped recipient is a
+// power.SourceHandler.
+func (d *Recipient) SendSource(i int, s power.Source) {
+	if _, ok := d.inner.(power.SourceHandler); !ok {
+		return
+	}
+	d.enqueue(func(r power.Recipient) {
+		r.(power.SourceHandler).SendSource(i, s)
+	})
+}
+
+// SendIdentity queues the identity for delivery if the wrapped recipient is a
+// power.IdentityHandler.
+func (d *Recipient) SendIdentity(i int, s power.Source, id power.Identity) {
+	if _, ok := d.inner.(power.IdentityHandler); !ok {
+		return
+	}
+	d.enqueue(func(r power.Recipient) {
+		r.(power.IdentityHandler).SendIdentity(i, s, id)
+	})
+}
+
+// SendQueryError queues the error for delivery if the wrapped recipient is a
+// power.ErrorHandler.
+func (d *Recipient) SendQueryError(i int, s power.Source, err error) {
+	if _, ok := d.inner.(power.ErrorHandler); !ok {
+		return
+	}
+	d.enqueue(func(r power.Recipient) {
+		r.(power.ErrorHandler).SendQueryError(i, s, err)
+	})
+}
+
+// SendEvent queues the event for delivery if the wrapped recipient is a
+// power.EventHandler.
+func (d *Recipient) SendEvent(e power.Event) {
+	if _, ok := d.inner.(power.EventHandler); !ok {
+		return
+	}
+	d.enqueue(func(r power.Recipient) {
+		r.(power.EventHandler).SendEvent(e)
+	})
+}
+
+// EndCycle queues the end of a cycle if the wrapped recipient is a
+// power.CycleHandler.
+func (d *Recipient) EndCycle(i int, s power.Source) {
+	if _, ok := d.inner.(power.CycleHandler); !ok {
+		return
+	}
+	d.enqueue(func(r power.Recipient) {
+		r.(power.CycleHandler).EndCycle(i, s)
+	})
+}
+
+// Flush queues a flush if the wrapped recipient is a power.Flusher. It does
+// not wait for the flush to happen.
+func (d *Recipient) Flush() error {
+	if _, ok := d.inner.(power.Flusher); !ok {
+		return nil
+	}
+	d.enqueue(func(r power.Recipient) {
+		if err := r.(power.Flusher).Flush(); err != nil {
+			d.opts.Logger.Printf("%s: flush failed: %v", d.name, err)
+		}
+	})
+	return nil
+}
+
+// Close stops accepting calls and waits for the queued calls to be made. It
+// then flushes and closes the wrapped recipient if it implements power.Flusher
+// or io.Closer. If an abandoned call is still running the wrapped recipient is
+// left alone and an error is returned.
+func (d *Recipient) Close() error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	<-d.done
+
+	if d.abandoned != nil {
+		select {
+		case <-d.abandoned:
+			d.abandoned = nil
+			atomic.StoreInt32(&d.stalled, 0)
+		default:
+			return fmt.Errorf("%s: an abandoned call is still running", d.name)
+		}
+	}
+
+	if flusher, ok := d.inner.(power.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+	if closer, ok := d.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Stats returns the dispatch counters of the recipient.
+func (d *Recipient) Stats() Stats {
+	return Stats{
+		Name:     d.name,
+		Queued:   len(d.queue),
+		Capacity: cap(d.queue),
+		Sent:     atomic.LoadUint64(&d.sent),
+		Dropped:  atomic.LoadUint64(&d.dropped),
+		TimedOut: atomic.LoadUint64(&d.timedOut),
+		Panics:   atomic.LoadUint64(&d.panics),
+		Stalled:  atomic.LoadInt32(&d.stalled) != 0,
+	}
+}
+
+// enqueue adds c to the queue according to the queue policy.
+func (d *Recipient) enqueue(c call) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		atomic.AddUint64(&d.dropped, 1)
+		return
+	}
+
+	if d.opts.Policy == Block {
+		d.queue <- c
+		return
+	}
+
+	select {
+	case d.queue <- c:
+	default:
+		atomic.AddUint64(&d.dropped, 1)
+	}
+}
+
+// run makes queued calls until the queue is closed. Calls are dropped while
+// an abandoned call is running.
+func (d *Recipient) run() {
+	defer close(d.done)
+
+	var reported uint64
+	for c := range d.queue {
+		if d.abandoned != nil {
+			select {
+			case <-d.abandoned:
+				d.abandoned = nil
+				atomic.StoreInt32(&d.stalled, 0)
+				d.opts.Logger.Printf("%s: abandoned call returned, resuming delivery", d.name)
+			default:
+				atomic.AddUint64(&d.dropped, 1)
+				continue
+			}
+		}
+
+		d.abandoned = d.invoke(c)
+		if d.abandoned != nil {
+			atomic.StoreInt32(&d.stalled, 1)
+		}
+
+		if dropped := atomic.LoadUint64(&d.dropped); dropped != reported {
+			d.opts.Logger.Printf("%s: %d calls dropped because the queue was full or the recipient stalled", d.name, dropped-reported)
+			reported = dropped
+		}
+	}
+}
+
+// invoke makes a single call, waiting no longer than the timeout. If the call
+// is abandoned it returns a channel that receives a value when the call
+// returns.
+func (d *Recipient) invoke(c call) <-chan bool {
+	finished := make(chan bool, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				atomic.AddUint64(&d.panics, 1)
+				d.opts.Logger.Printf("%s: recovered from panic: %v", d.name, p)
+				finished <- false
+			}
+		}()
+		c(d.inner)
+		finished <- true
+	}()
+
+	timer := time.NewTimer(d.opts.Timeout)
+	defer timer.Stop()
+
+	select {
+	case ok := <-finished:
+		if ok {
+			atomic.AddUint64(&d.sent, 1)
+		}
+		return nil
+	case <-timer.C:
+		atomic.AddUint64(&d.timedOut, 1)
+		d.opts.Logger.Printf("%s: call abandoned after %s", d.name, d.opts.Timeout)
+		return finished
+	}
+}
@@ -0,0 +1,393 @@
+package dispatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// recorder is a recipient that records the calls made to it. Values named
+// "hang" block until the gate is closed, and values named "panic" panic.
+type recorder struct {
+	gate    chan struct{}
+	started chan string // Receives the name of each value as its call starts
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		gate:    make(chan struct{}),
+		started: make(chan string, 100),
+	}
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+// Calls returns the calls that have completed.
+func (r *recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+func (r *recorder) Send(v power.Value) {
+	r.started <- v.Stat.Name
+	switch v.Stat.Name {
+	case "hang":
+		<-r.gate
+	case "panic":
+		panic("recipient failure")
+	}
+	r.record("send " + v.Stat.Name)
+}
+
+func (r *recorder) SendSource(i int, s power.Source) {
+	r.record(fmt.Sprintf("source %d %s", i, s.Name))
+}
+
+func (r *recorder) SendIdentity(i int, s power.Source, id power.Identity) {
+	r.record(fmt.Sprintf("identity %d %s", i, id.Model))
+}
+
+func (r *recorder) SendQueryError(i int, s power.Source, err error) {
+	r.record(fmt.Sprintf("error %d %v", i, err))
+}
+
+func (r *recorder) SendEvent(e power.Event) {
+	r.record("event " + e.Name)
+}
+
+func (r *recorder) EndCycle(i int, s power.Source) {
+	r.record(fmt.Sprintf("end %d", i))
+}
+
+func (r *recorder) Flush() error {
+	r.record("flush")
+	return nil
+}
+
+func (r *recorder) Close() error {
+	r.record("close")
+	return nil
+}
+
+// value returns a value for a statistic with the given name.
+func value(name string) power.Value {
+	return power.Value{Stat: power.Statistic{Name: name}}
+}
+
+// wait waits for the recipient to start a call for the named value.
+func (r *recorder) wait(t *testing.T, name string) {
+	t.Helper()
+	select {
+	case started := <-r.started:
+		if started != name {
+			t.Fatalf("call for %s started, want %s", started, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("call for %s did not start", name)
+	}
+}
+
+// eventually waits for cond to become true.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// quiet returns options with the given settings and a logger that writes to
+// buf, or discards its output if buf is nil.
+func quiet(queueSize int, policy Policy, timeout time.Duration, buf *bytes.Buffer) Options {
+	var w io.Writer = io.Discard
+	if buf != nil {
+		w = buf
+	}
+	return Options{
+		QueueSize: queueSize,
+		Policy:    policy,
+		Timeout:   timeout,
+		Logger:    log.New(w, "", 0),
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Policy
+		fail bool
+	}{
+		{s: "drop", want: Drop},
+		{s: "Block", want: Block},
+		{s: "wait", fail: true},
+	}
+
+	for _, test := range tests {
+		policy, err := ParsePolicy(test.s)
+		switch {
+		case test.fail:
+			if err == nil {
+				t.Errorf("ParsePolicy(%q) returned %s, want an error", test.s, policy)
+			}
+		case err != nil:
+			t.Errorf("ParsePolicy(%q) returned %v", test.s, err)
+		case policy != test.want || policy.String() != strings.ToLower(test.s):
+			t.Errorf("ParsePolicy(%q) returned %s", test.s, policy)
+		}
+	}
+}
+
+func TestForward(t *testing.T) {
+	r := newRecorder()
+	d := New("recorder", r, quiet(0, Drop, 0, nil))
+
+	source := power.Source{Name: "ups1"}
+	d.SendSource(0, source)
+	d.SendIdentity(0, source, power.Identity{Model: "Smart-UPS"})
+	d.Send(value("a"))
+	d.SendQueryError(0, source, errors.New("timeout"))
+	d.SendEvent(power.Event{Name: "upsTrapOnBattery"})
+	d.EndCycle(0, source)
+	d.Flush()
+	d.Send(value("b"))
+
+	// Close makes the queued calls, then flushes and closes the recipient
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	want := []string{
+		"source 0 ups1",
+		"identity 0 Smart-UPS",
+		"send a",
+		"error 0 timeout",
+		"event upsTrapOnBattery",
+		"end 0",
+		"flush",
+		"send b",
+		"flush",
+		"close",
+	}
+	if calls := r.Calls(); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("recipient received %q, want %q", calls, want)
+	}
+
+	// Calls made after Close are dropped
+	d.Send(value("c"))
+	if stats := d.Stats(); stats.Sent != 8 || stats.Dropped != 1 {
+		t.Fatalf("Stats returned %+v, want 8 sent and 1 dropped", stats)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("second Close returned %v", err)
+	}
+}
+
+// sendOnly is a recipient that implements none of the optional interfaces.
+type sendOnly struct {
+	values chan power.Value
+}
+
+func (r sendOnly) Send(v power.Value) {
+	r.values <- v
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	r := sendOnly{values: make(chan power.Value, 1)}
+	d := New("send only", r, quiet(0, Drop, 0, nil))
+
+	source := power.Source{Name: "ups1"}
+	d.SendSource(0, source)
+	d.SendIdentity(0, source, power.Identity{})
+	d.SendQueryError(0, source, errors.New("timeout"))
+	d.SendEvent(power.Event{})
+	d.EndCycle(0, source)
+	if err := d.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	d.Send(value("a"))
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	// Only the value is queued and delivered
+	if stats := d.Stats(); stats.Sent != 1 || stats.Dropped != 0 {
+		t.Fatalf("Stats returned %+v, want 1 sent", stats)
+	}
+	if v := <-r.values; v.Stat.Name != "a" {
+		t.Fatalf("recipient received %s", v.Stat.Name)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRecorder()
+	d := New("recorder", r, quiet(2, Drop, time.Minute, &buf))
+
+	// The first call blocks the recipient, the next two fill the queue and
+	// the rest are dropped
+	d.Send(value("hang"))
+	r.wait(t, "hang")
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		d.Send(value(name))
+	}
+	if stats := d.Stats(); stats.Queued != 2 || stats.Capacity != 2 || stats.Dropped != 3 {
+		t.Fatalf("Stats returned %+v, want 2 queued and 3 dropped", stats)
+	}
+
+	close(r.gate)
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	want := []string{"send hang", "send a", "send b", "flush", "close"}
+	if calls := r.Calls(); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("recipient received %q, want %q", calls, want)
+	}
+	if stats := d.Stats(); stats.Sent != 3 || stats.Queued != 0 {
+		t.Fatalf("Stats returned %+v, want 3 sent", stats)
+	}
+	if !strings.Contains(buf.String(), "3 calls dropped") {
+		t.Fatalf("drops were not logged: %q", buf.String())
+	}
+}
+
+func TestBlock(t *testing.T) {
+	r := newRecorder()
+	d := New("recorder", r, quiet(1, Block, time.Minute, nil))
+
+	d.Send(value("hang"))
+	r.wait(t, "hang")
+	d.Send(value("a"))
+
+	// The queue is full, so the sender waits until there's space
+	sent := make(chan struct{})
+	go func() {
+		d.Send(value("b"))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("Send returned while the queue was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(r.gate)
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not return once the queue had space")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	want := []string{"send hang", "send a", "send b", "flush", "close"}
+	if calls := r.Calls(); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("recipient received %q, want %q", calls, want)
+	}
+	if stats := d.Stats(); stats.Sent != 3 || stats.Dropped != 0 {
+		t.Fatalf("Stats returned %+v, want 3 sent and none dropped", stats)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRecorder()
+	d := New("recorder", r, quiet(10, Drop, 10*time.Millisecond, &buf))
+
+	d.Send(value("hang"))
+	r.wait(t, "hang")
+	eventually(t, "the call to be abandoned", func() bool { return d.Stats().TimedOut == 1 })
+	if stats := d.Stats(); !stats.Stalled || stats.Sent != 0 {
+		t.Fatalf("Stats returned %+v, want a stalled recipient", stats)
+	}
+
+	// Calls are dropped rather than made while the abandoned call runs
+	d.Send(value("a"))
+	d.Send(value("b"))
+	eventually(t, "the calls to be dropped", func() bool { return d.Stats().Dropped == 2 })
+	select {
+	case name := <-r.started:
+		t.Fatalf("call for %s was made while the recipient was stalled", name)
+	default:
+	}
+
+	// Delivery resumes once the abandoned call returns
+	close(r.gate)
+	eventually(t, "the abandoned call to return", func() bool { return len(r.Calls()) == 1 })
+	d.Send(value("c"))
+	r.wait(t, "c")
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	want := []string{"send hang", "send c", "flush", "close"}
+	if calls := r.Calls(); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("recipient received %q, want %q", calls, want)
+	}
+	if stats := d.Stats(); stats.Stalled || stats.Sent != 1 || stats.TimedOut != 1 || stats.Dropped != 2 {
+		t.Fatalf("Stats returned %+v", stats)
+	}
+	for _, msg := range []string{"call abandoned after 10ms", "abandoned call returned", "2 calls dropped"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("log does not include %q: %q", msg, buf.String())
+		}
+	}
+}
+
+func TestCloseStalled(t *testing.T) {
+	r := newRecorder()
+	defer close(r.gate)
+	d := New("recorder", r, quiet(10, Drop, 10*time.Millisecond, nil))
+
+	d.Send(value("hang"))
+	r.wait(t, "hang")
+
+	// The recipient is left alone while the abandoned call runs
+	if err := d.Close(); err == nil {
+		t.Fatal("Close succeeded while an abandoned call was running")
+	}
+	if calls := r.Calls(); len(calls) != 0 {
+		t.Fatalf("recipient received %q while stalled", calls)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRecorder()
+	d := New("recorder", r, quiet(10, Drop, time.Minute, &buf))
+
+	d.Send(value("panic"))
+	d.Send(value("a"))
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	want := []string{"send a", "flush", "close"}
+	if calls := r.Calls(); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("recipient received %q, want %q", calls, want)
+	}
+	if stats := d.Stats(); stats.Panics != 1 || stats.Sent != 1 {
+		t.Fatalf("Stats returned %+v, want 1 panic and 1 sent", stats)
+	}
+	if !strings.Contains(buf.String(), "recovered from panic: recipient failure") {
+		t.Fatalf("panic was not logged: %q", buf.String())
+	}
+}
@@ -0,0 +1,25 @@
+package power
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event describes something that happened to a power source outside of the
+// regular polling cycle, such as a transition to battery power announced by
+// an SNMP trap.
+type Event struct {
+	Source  Source
+	Time    time.Time
+	Name    string // Short name of the event, such as "upsTrapOnBattery"
+	Message string // Human readable description of the event
+	OID     string // Notification object identifier, if any
+}
+
+// String returns a string representation of the event.
+func (e Event) String() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return fmt.Sprintf("%s: %s", e.Name, e.Message)
+}
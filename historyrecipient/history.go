@@ -0,0 +1,229 @@
+// Package historyrecipient stores the history of power statistics on disk.
+//
+// Raw samples are kept for a limited time, while rollups holding the
+// minimum, average and maximum of each statistic over five minute and one
+// hour intervals are kept for longer. Each is stored in append-only segment
+// files that cover a fixed span of time, with one JSON record per line.
+// Segments are deleted once all of their records have expired:
+//
+//	DIR/raw/20261019.jsonl   One day of raw samples
+//	DIR/5m/20261012.jsonl    One week of five minute rollups
+//	DIR/1h/20260926.jsonl    Thirty days of hourly rollups
+//
+// Rollups of intervals that are in progress are held in memory and written
+// when the interval ends or the history is closed.
+package historyrecipient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// Options holds the retention settings of a history.
+type Options struct {
+	Raw        time.Duration // Retention of raw samples
+	FiveMinute time.Duration // Retention of five minute rollups
+	Hourly     time.Duration // Retention of hourly rollups
+}
+
+// DefaultOptions are the options used when none are provided.
+var DefaultOptions = Options{
+	Raw:        7 * 24 * time.Hour,
+	FiveMinute: 90 * 24 * time.Hour,
+	Hourly:     5 * 365 * 24 * time.Hour,
+}
+
+// pruneInterval is the minimum time between removals of expired segments.
+const pruneInterval = time.Hour
+
+// series identifies the values of a single statistic of a single source.
+type series struct {
+	source    string
+	statistic string
+	instance  string
+}
+
+// bucket accumulates the values of a series within a rollup interval.
+type bucket struct {
+	start time.Time
+	min   float64
+	max   float64
+	sum   float64
+	count int
+}
+
+// add includes value in the bucket.
+func (b *bucket) add(value float64) {
+	if b.count == 0 || value < b.min {
+		b.min = value
+	}
+	if b.count == 0 || value > b.max {
+		b.max = value
+	}
+	b.sum += value
+	b.count++
+}
+
+// History is a recipient that stores the values it receives on disk and
+// answers queries about them. It is safe for concurrent use.
+type History struct {
+	dir   string
+	tiers []*tier
+	now   func() time.Time // Source of the current time, replaced in tests
+
+	mu      sync.Mutex
+	pending []map[series]*bucket // Rollups in progress, parallel to tiers
+	pruned  time.Time
+	closed  bool
+}
+
+// Open returns a history stored in dir with the given options, creating the
+// directory if necessary. Zero retentions are replaced by their defaults.
+//
+// A directory must not be used by more than one history at a time.
+func Open(dir string, options Options) (*History, error) {
+	if options.Raw <= 0 {
+		options.Raw = DefaultOptions.Raw
+	}
+	if options.FiveMinute <= 0 {
+		options.FiveMinute = DefaultOptions.FiveMinute
+	}
+	if options.Hourly <= 0 {
+		options.Hourly = DefaultOptions.Hourly
+	}
+
+	h := &History{
+		dir: dir,
+		now: time.Now,
+		tiers: []*tier{
+			{name: "raw", span: 24 * time.Hour, retention: options.Raw},
+			{name: "5m", resolution: FiveMinutes, span: 7 * 24 * time.Hour, retention: options.FiveMinute},
+			{name: "1h", resolution: Hour, span: 30 * 24 * time.Hour, retention: options.Hourly},
+		},
+	}
+	for _, t := range h.tiers {
+		t.dir = filepath.Join(dir, t.name)
+		if err := os.MkdirAll(t.dir, 0755); err != nil {
+			return nil, fmt.Errorf("unable to create history directory: %v", err)
+		}
+		h.pending = append(h.pending, make(map[series]*bucket))
+	}
+
+	return h, nil
+}
+
+// Send records v. Values that carry an error are ignored.
+func (h *History) Send(v power.Value) {
+	if v.Err != nil {
+		return
+	}
+
+	s := series{
+		source:    v.Source.Key(),
+		statistic: v.Stat.Name,
+		instance:  v.Instance(),
+	}
+	t := v.Time
+	if t.IsZero() {
+		t = h.now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	for i, tier := range h.tiers {
+		if tier.resolution == Raw {
+			h.write(tier, rawRecord(s, t, v.Value))
+			continue
+		}
+
+		start := t.Truncate(time.Duration(tier.resolution))
+		b := h.pending[i][s]
+		if b != nil && !b.start.Equal(start) {
+			h.write(tier, rollupRecord(s, b))
+			b = nil
+		}
+		if b == nil {
+			b = &bucket{start: start}
+			h.pending[i][s] = b
+		}
+		b.add(v.Value)
+	}
+}
+
+// Flush writes buffered records to disk and removes expired segments.
+func (h *History) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+
+	err := h.flush()
+	if now := h.now(); now.Sub(h.pruned) >= pruneInterval {
+		h.pruned = now
+		for _, tier := range h.tiers {
+			if pErr := tier.prune(now); pErr != nil && err == nil {
+				err = pErr
+			}
+		}
+	}
+	return err
+}
+
+// Close writes the rollups of intervals in progress along with any buffered
+// records and closes the history's segment files. Queries may still be made
+// after a history has been closed.
+func (h *History) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+
+	for i, tier := range h.tiers {
+		for s, b := range h.pending[i] {
+			h.write(tier, rollupRecord(s, b))
+		}
+		h.pending[i] = make(map[series]*bucket)
+	}
+
+	err := h.flush()
+	for _, tier := range h.tiers {
+		if cErr := tier.close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// write appends r to the tier, reporting failures. The caller must hold
+// h.mu.
+func (h *History) write(t *tier, r record) {
+	if err := t.append(r); err != nil {
+		fmt.Printf("History error: %v\n", err)
+	}
+}
+
+// flush writes the buffered records of every tier. The caller must hold
+// h.mu.
+func (h *History) flush() (err error) {
+	for _, tier := range h.tiers {
+		if fErr := tier.flush(); fErr != nil && err == nil {
+			err = fErr
+		}
+	}
+	return
+}
@@ -0,0 +1,264 @@
+package historyrecipient
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// base is the time at which test histories begin recording.
+var base = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// testHistory is a history with a clock controlled by the test.
+type testHistory struct {
+	*History
+	clock time.Time
+}
+
+// open returns a history stored in dir whose clock starts at base.
+func open(t *testing.T, dir string, options Options) *testHistory {
+	t.Helper()
+	h, err := Open(dir, options)
+	if err != nil {
+		t.Fatalf("Open returned %v", err)
+	}
+	th := &testHistory{History: h, clock: base}
+	h.now = func() time.Time { return th.clock }
+	return th
+}
+
+// value returns a value of the test statistic recorded at base plus offset.
+func value(offset time.Duration, v float64) power.Value {
+	return power.Value{
+		Source: power.Source{Host: "ups1", Name: "rack"},
+		Stat:   power.Statistic{Name: "OutputPercentLoad"},
+		Time:   base.Add(offset),
+		Value:  v,
+	}
+}
+
+// lines returns the lines of the file at path.
+func lines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestSegmentFormat(t *testing.T) {
+	dir := t.TempDir()
+	h := open(t, dir, Options{})
+
+	h.Send(value(time.Minute, 10))
+	h.Send(value(2*time.Minute, 20))
+	table := value(3*time.Minute, 1.5)
+	table.Stat.Name, table.Label = "OutletCurrent", "Outlet 1"
+	h.Send(table)
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		lines []string
+	}{
+		{
+			path: filepath.Join(dir, "raw", "20261019.jsonl"),
+			lines: []string{
+				`{"s":"rack","n":"OutputPercentLoad","t":1792368060000,"v":10}`,
+				`{"s":"rack","n":"OutputPercentLoad","t":1792368120000,"v":20}`,
+				`{"s":"rack","n":"OutletCurrent","i":"Outlet 1","t":1792368180000,"v":1.5}`,
+			},
+		},
+		{
+			path: filepath.Join(dir, "5m", "20261019.jsonl"),
+			lines: []string{
+				`{"s":"rack","n":"OutletCurrent","i":"Outlet 1","t":1792368000000,"min":1.5,"avg":1.5,"max":1.5,"c":1}`,
+				`{"s":"rack","n":"OutputPercentLoad","t":1792368000000,"min":10,"avg":15,"max":20,"c":2}`,
+			},
+		},
+		{
+			path: filepath.Join(dir, "1h", "20261002.jsonl"),
+			lines: []string{
+				`{"s":"rack","n":"OutletCurrent","i":"Outlet 1","t":1792368000000,"min":1.5,"avg":1.5,"max":1.5,"c":1}`,
+				`{"s":"rack","n":"OutputPercentLoad","t":1792368000000,"min":10,"avg":15,"max":20,"c":2}`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := lines(t, test.path)
+		if len(got) == 2 && got[0] > got[1] {
+			// Rollups in progress are written in no particular order
+			got[0], got[1] = got[1], got[0]
+		}
+		if strings.Join(got, "\n") != strings.Join(test.lines, "\n") {
+			t.Errorf("%s holds:\n%s\nwant:\n%s", test.path, strings.Join(got, "\n"), strings.Join(test.lines, "\n"))
+		}
+	}
+}
+
+func TestRollup(t *testing.T) {
+	h := open(t, t.TempDir(), Options{})
+	defer h.Close()
+
+	h.Send(value(1*time.Minute, 10))
+	h.Send(value(4*time.Minute, 20))
+	h.Send(value(6*time.Minute, 30))
+	h.Send(value(62*time.Minute, 40))
+	failed := value(7*time.Minute, 1000)
+	failed.Err = errors.New("timeout")
+	h.Send(failed)
+	h.clock = base.Add(2 * time.Hour)
+
+	tests := []struct {
+		resolution Resolution
+		want       []Point
+	}{
+		{
+			resolution: Raw,
+			want: []Point{
+				{Time: base.Add(1 * time.Minute), Min: 10, Avg: 10, Max: 10, Count: 1},
+				{Time: base.Add(4 * time.Minute), Min: 20, Avg: 20, Max: 20, Count: 1},
+				{Time: base.Add(6 * time.Minute), Min: 30, Avg: 30, Max: 30, Count: 1},
+				{Time: base.Add(62 * time.Minute), Min: 40, Avg: 40, Max: 40, Count: 1},
+			},
+		},
+		{
+			resolution: FiveMinutes,
+			want: []Point{
+				{Time: base, Min: 10, Avg: 15, Max: 20, Count: 2},
+				{Time: base.Add(5 * time.Minute), Min: 30, Avg: 30, Max: 30, Count: 1},
+				{Time: base.Add(time.Hour), Min: 40, Avg: 40, Max: 40, Count: 1},
+			},
+		},
+		{
+			resolution: Hour,
+			want: []Point{
+				{Time: base, Min: 10, Avg: 20, Max: 30, Count: 3},
+				{Time: base.Add(time.Hour), Min: 40, Avg: 40, Max: 40, Count: 1},
+			},
+		},
+	}
+
+	// Completed rollups are read from disk and those in progress from
+	// memory, then all of them are read from disk once the history closes
+	for _, stage := range []string{"open", "closed"} {
+		if stage == "closed" {
+			if err := h.Close(); err != nil {
+				t.Fatalf("Close returned %v", err)
+			}
+		}
+		for _, test := range tests {
+			points, resolution, err := h.Range(Query{Source: "rack", Statistic: "OutputPercentLoad", From: base, Resolution: test.resolution})
+			if err != nil {
+				t.Fatalf("%s %s: Range returned %v", stage, test.resolution, err)
+			}
+			if resolution != test.resolution {
+				t.Errorf("%s %s: Range returned resolution %s", stage, test.resolution, resolution)
+			}
+			checkPoints(t, stage+" "+test.resolution.String(), points, test.want)
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	// A rollup that is written by Close before its interval ends is merged
+	// with the rollup of the same interval written after the history is
+	// reopened
+	h := open(t, dir, Options{})
+	h.Send(value(1*time.Minute, 10))
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	h.Send(value(2*time.Minute, 1000)) // Ignored once closed
+
+	h = open(t, dir, Options{})
+	h.Send(value(3*time.Minute, 30))
+	h.Send(value(4*time.Minute, 50))
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	points, _, err := h.Range(Query{Source: "rack", Statistic: "OutputPercentLoad", From: base, To: base.Add(time.Hour), Resolution: FiveMinutes})
+	if err != nil {
+		t.Fatalf("Range returned %v", err)
+	}
+	checkPoints(t, "5m", points, []Point{{Time: base, Min: 10, Avg: 30, Max: 50, Count: 3}})
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	h := open(t, dir, Options{Raw: 24 * time.Hour, FiveMinute: 7 * 24 * time.Hour, Hourly: 30 * 24 * time.Hour})
+	defer h.Close()
+
+	for day := 0; day < 3; day++ {
+		h.Send(value(time.Duration(day)*24*time.Hour+time.Hour, float64(day)))
+	}
+
+	// The first day has expired, while the second still holds unexpired
+	// records
+	h.clock = base.Add(3*24*time.Hour - 30*time.Minute)
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	for _, test := range []struct {
+		name   string
+		exists bool
+	}{
+		{name: "raw/20261019.jsonl", exists: false},
+		{name: "raw/20261020.jsonl", exists: true},
+		{name: "raw/20261021.jsonl", exists: true},
+		{name: "5m/20261019.jsonl", exists: true},
+	} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(test.name)))
+		if exists := err == nil; exists != test.exists {
+			t.Errorf("%s exists: %v, want %v", test.name, exists, test.exists)
+		}
+	}
+
+	points, _, err := h.Range(Query{Source: "rack", Statistic: "OutputPercentLoad", From: base, Resolution: Raw})
+	if err != nil {
+		t.Fatalf("Range returned %v", err)
+	}
+	checkPoints(t, "raw", points, []Point{
+		{Time: base.Add(25 * time.Hour), Min: 1, Avg: 1, Max: 1, Count: 1},
+		{Time: base.Add(49 * time.Hour), Min: 2, Avg: 2, Max: 2, Count: 1},
+	})
+
+	// Pruning happens no more than once per interval
+	h.clock = base.Add(3 * 24 * time.Hour)
+	h.Flush()
+	if _, err := os.Stat(filepath.Join(dir, "raw", "20261020.jsonl")); err != nil {
+		t.Errorf("segment pruned again within %s: %v", pruneInterval, err)
+	}
+	h.clock = h.clock.Add(pruneInterval)
+	h.Flush()
+	if _, err := os.Stat(filepath.Join(dir, "raw", "20261020.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("expired segment was not pruned: %v", err)
+	}
+}
+
+// checkPoints compares points with want.
+func checkPoints(t *testing.T, name string, points, want []Point) {
+	t.Helper()
+	if len(points) != len(want) {
+		t.Fatalf("%s: got %d points %+v, want %d", name, len(points), points, len(want))
+	}
+	for i := range want {
+		got := points[i]
+		if !got.Time.Equal(want[i].Time) || got.Min != want[i].Min || got.Avg != want[i].Avg || got.Max != want[i].Max || got.Count != want[i].Count || got.Instance != want[i].Instance {
+			t.Errorf("%s: point %d is %+v, want %+v", name, i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package historyrecipient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Resolution is the interval between the points returned by a query. Raw
+// points are returned as they were recorded.
+type Resolution time.Duration
+
+// Resolutions
+const (
+	Auto        Resolution = -1
+	Raw         Resolution = 0
+	FiveMinutes            = Resolution(5 * time.Minute)
+	Hour                   = Resolution(time.Hour)
+)
+
+// String returns the name of the resolution.
+func (r Resolution) String() string {
+	switch r {
+	case Auto:
+		return "auto"
+	case Raw:
+		return "raw"
+	case FiveMinutes:
+		return "5m"
+	case Hour:
+		return "1h"
+	}
+	return time.Duration(r).String()
+}
+
+// ParseResolution parses "auto", "raw", "5m" or "1h" as a resolution.
+func ParseResolution(s string) (Resolution, error) {
+	for _, r := range []Resolution{Auto, Raw, FiveMinutes, Hour} {
+		if strings.EqualFold(s, r.String()) {
+			return r, nil
+		}
+	}
+	return Auto, fmt.Errorf("unknown resolution \"%s\"", s)
+}
+
+// Query selects the stored values of a single statistic of a single source.
+type Query struct {
+	Source     string // Key of the source
+	Statistic  string
+	Instance   string // Row label or index of table statistics
+	From       time.Time
+	To         time.Time
+	Resolution Resolution // Auto selects the finest resolution retained for From
+}
+
+// Point is a stored value, or a summary of the values within an interval.
+// Raw points have the same minimum, average and maximum.
+type Point struct {
+	Instance string    `json:"instance,omitempty"`
+	Time     time.Time `json:"time"` // Start of the interval for rollups
+	Min      float64   `json:"min"`
+	Avg      float64   `json:"avg"`
+	Max      float64   `json:"max"`
+	Count    int       `json:"count"` // Number of raw values summarized by the point
+}
+
+// merge combines the summary in o with p.
+func (p *Point) merge(o Point) {
+	if o.Min < p.Min {
+		p.Min = o.Min
+	}
+	if o.Max > p.Max {
+		p.Max = o.Max
+	}
+	total := p.Count + o.Count
+	if total > 0 {
+		p.Avg = (p.Avg*float64(p.Count) + o.Avg*float64(o.Count)) / float64(total)
+	}
+	p.Count = total
+}
+
+// Range returns the points of the query's statistic within [From, To) in
+// order of time. A zero To is treated as the current time.
+//
+// Rollups of intervals in progress are included, so the last point of a
+// rollup query may summarize a partial interval.
+//
+// Segment files are read without holding the history's lock, so queries
+// don't delay the recording of values. Only the records present when the
+// query began are read.
+func (h *History) Range(q Query) (points []Point, resolution Resolution, err error) {
+	if q.To.IsZero() {
+		q.To = h.now()
+	}
+	if !q.From.Before(q.To) {
+		return nil, q.Resolution, nil
+	}
+
+	i, err := h.tierFor(q)
+	if err != nil {
+		return nil, q.Resolution, err
+	}
+	t := h.tiers[i]
+	resolution = t.resolution
+
+	from, to := unixMilli(q.From), unixMilli(q.To)
+	if t.resolution != Raw {
+		// Rollups that began before From overlap the range
+		from = unixMilli(q.From.Truncate(time.Duration(t.resolution)))
+	}
+
+	s := series{source: q.Source, statistic: q.Statistic, instance: q.Instance}
+
+	// Buffered records are written, then the segments and the rollup in
+	// progress are captured together so that a rollup completed while the
+	// segments are being read is neither missed nor counted twice
+	var (
+		segments []segmentFile
+		pending  *bucket
+	)
+	h.mu.Lock()
+	if !h.closed {
+		err = t.flush()
+	}
+	if err == nil {
+		segments, err = t.overlapping(time.Unix(0, from*int64(time.Millisecond)), q.To)
+	}
+	if b := h.pending[i][s]; b != nil {
+		copied := *b
+		pending = &copied
+	}
+	h.mu.Unlock()
+	if err != nil {
+		return nil, resolution, err
+	}
+
+	match := func(r record) bool {
+		return r.Source == q.Source && r.Statistic == q.Statistic && r.Instance == q.Instance && r.Time >= from && r.Time < to
+	}
+
+	// Rollups of the same interval may be recorded more than once if the
+	// history was closed before the interval ended
+	byTime := make(map[int64]int)
+	add := func(p Point) {
+		key := unixMilli(p.Time)
+		if t.resolution != Raw {
+			if j, ok := byTime[key]; ok {
+				points[j].merge(p)
+				return
+			}
+			byTime[key] = len(points)
+		}
+		points = append(points, p)
+	}
+
+	for _, segment := range segments {
+		err = readSegment(segment, func(r record) {
+			if match(r) {
+				add(r.point())
+			}
+		})
+		if err != nil {
+			return nil, resolution, err
+		}
+	}
+
+	if pending != nil && match(rollupRecord(s, pending)) {
+		add(rollupRecord(s, pending).point())
+	}
+
+	sort.SliceStable(points, func(a, b int) bool {
+		return points[a].Time.Before(points[b].Time)
+	})
+
+	return points, resolution, nil
+}
+
+// Retention returns the time for which points of the given resolution are
+// kept. It returns zero for resolutions that are not stored.
+func (h *History) Retention(r Resolution) time.Duration {
+	for _, t := range h.tiers {
+		if t.resolution == r {
+			return t.retention
+		}
+	}
+	return 0
+}
+
+// tierFor returns the index of the tier that answers q.
+func (h *History) tierFor(q Query) (int, error) {
+	if q.Resolution == Auto {
+		age := h.now().Sub(q.From)
+		for i, t := range h.tiers {
+			if age <= t.retention {
+				return i, nil
+			}
+		}
+		return len(h.tiers) - 1, nil
+	}
+	for i, t := range h.tiers {
+		if t.resolution == q.Resolution {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported resolution %s", q.Resolution)
+}
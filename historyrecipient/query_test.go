@@ -0,0 +1,131 @@
+package historyrecipient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseResolution(t *testing.T) {
+	for _, r := range []Resolution{Auto, Raw, FiveMinutes, Hour} {
+		parsed, err := ParseResolution(r.String())
+		if err != nil || parsed != r {
+			t.Errorf("ParseResolution(%q) returned %s (%v)", r.String(), parsed, err)
+		}
+	}
+	if r, err := ParseResolution("1m"); err == nil {
+		t.Errorf("ParseResolution(\"1m\") returned %s, want an error", r)
+	}
+}
+
+func TestRange(t *testing.T) {
+	h := open(t, t.TempDir(), Options{})
+	defer h.Close()
+
+	// One value every ten minutes for three days
+	for offset := time.Duration(0); offset < 3*24*time.Hour; offset += 10 * time.Minute {
+		h.Send(value(offset, float64(offset/time.Minute)))
+	}
+	other := value(time.Hour, 1000)
+	other.Source.Name = "other"
+	h.Send(other)
+	h.clock = base.Add(3 * 24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		query      Query
+		resolution Resolution
+		want       []Point
+	}{
+		{
+			name:  "raw range is half open",
+			query: Query{From: base.Add(time.Hour), To: base.Add(90 * time.Minute), Resolution: Raw},
+			want: []Point{
+				{Time: base.Add(60 * time.Minute), Min: 60, Avg: 60, Max: 60, Count: 1},
+				{Time: base.Add(70 * time.Minute), Min: 70, Avg: 70, Max: 70, Count: 1},
+				{Time: base.Add(80 * time.Minute), Min: 80, Avg: 80, Max: 80, Count: 1},
+			},
+		},
+		{
+			name:  "rollup overlapping the start",
+			query: Query{From: base.Add(time.Hour + 30*time.Minute), To: base.Add(2 * time.Hour), Resolution: Hour},
+			want: []Point{
+				{Time: base.Add(time.Hour), Min: 60, Avg: 85, Max: 110, Count: 6},
+			},
+		},
+		{
+			name:  "across segments",
+			query: Query{From: base.Add(24*time.Hour - 10*time.Minute), To: base.Add(24*time.Hour + 10*time.Minute), Resolution: Raw},
+			want: []Point{
+				{Time: base.Add(24*time.Hour - 10*time.Minute), Min: 1430, Avg: 1430, Max: 1430, Count: 1},
+				{Time: base.Add(24 * time.Hour), Min: 1440, Avg: 1440, Max: 1440, Count: 1},
+			},
+		},
+		{
+			name:       "auto selects raw for recent ranges",
+			query:      Query{From: base.Add(3*24*time.Hour - 20*time.Minute), Resolution: Auto},
+			resolution: Raw,
+			want: []Point{
+				{Time: base.Add(3*24*time.Hour - 20*time.Minute), Min: 4300, Avg: 4300, Max: 4300, Count: 1},
+				{Time: base.Add(3*24*time.Hour - 10*time.Minute), Min: 4310, Avg: 4310, Max: 4310, Count: 1},
+			},
+		},
+		{
+			name:  "empty range",
+			query: Query{From: base.Add(time.Hour), To: base.Add(time.Hour), Resolution: Raw},
+		},
+	}
+
+	for _, test := range tests {
+		test.query.Source, test.query.Statistic = "rack", "OutputPercentLoad"
+		points, resolution, err := h.Range(test.query)
+		if err != nil {
+			t.Fatalf("%s: Range returned %v", test.name, err)
+		}
+		if test.query.Resolution != Auto && resolution != test.query.Resolution {
+			t.Errorf("%s: Range returned resolution %s", test.name, resolution)
+		}
+		if test.query.Resolution == Auto && resolution != test.resolution {
+			t.Errorf("%s: Range returned resolution %s, want %s", test.name, resolution, test.resolution)
+		}
+		checkPoints(t, test.name, points, test.want)
+	}
+
+	if _, _, err := h.Range(Query{Source: "rack", Statistic: "OutputPercentLoad", From: base, Resolution: Resolution(time.Minute)}); err == nil {
+		t.Error("Range accepted an unsupported resolution")
+	}
+}
+
+func TestAutoResolution(t *testing.T) {
+	h := open(t, t.TempDir(), Options{Raw: 24 * time.Hour, FiveMinute: 7 * 24 * time.Hour, Hourly: 30 * 24 * time.Hour})
+	defer h.Close()
+	h.clock = base.Add(365 * 24 * time.Hour)
+
+	tests := []struct {
+		age  time.Duration
+		want Resolution
+	}{
+		{age: time.Hour, want: Raw},
+		{age: 24 * time.Hour, want: Raw},
+		{age: 2 * 24 * time.Hour, want: FiveMinutes},
+		{age: 7 * 24 * time.Hour, want: FiveMinutes},
+		{age: 8 * 24 * time.Hour, want: Hour},
+		{age: 300 * 24 * time.Hour, want: Hour}, // Beyond every retention
+	}
+
+	for _, test := range tests {
+		_, resolution, err := h.Range(Query{Source: "rack", Statistic: "OutputPercentLoad", From: h.clock.Add(-test.age), Resolution: Auto})
+		if err != nil {
+			t.Fatalf("%s: Range returned %v", test.age, err)
+		}
+		if resolution != test.want {
+			t.Errorf("%s: Range selected %s, want %s", test.age, resolution, test.want)
+		}
+	}
+
+	if got := h.Retention(FiveMinutes); got != 7*24*time.Hour {
+		t.Errorf("Retention(5m) returned %s", got)
+	}
+	if got := h.Retention(Resolution(time.Minute)); got != 0 {
+		t.Errorf("Retention(1m) returned %s", got)
+	}
+}
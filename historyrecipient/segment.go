@@ -0,0 +1,274 @@
+package historyrecipient
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// segmentExt is the file extension of segment files.
+const segmentExt = ".jsonl"
+
+// segmentLayout is the time layout of segment file names.
+const segmentLayout = "20060102"
+
+// tier is a set of segments holding records of a single resolution.
+type tier struct {
+	name       string
+	dir        string
+	resolution Resolution
+	span       time.Duration // Span of time covered by each segment
+	retention  time.Duration
+
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time // Start of the segment that is open for writing
+}
+
+// record is a single line of a segment file. Raw records carry a value,
+// while rollup records carry the minimum, average and maximum of the values
+// within an interval.
+type record struct {
+	Source    string   `json:"s"`
+	Statistic string   `json:"n"`
+	Instance  string   `json:"i,omitempty"`
+	Time      int64    `json:"t"` // Unix milliseconds, the start of the interval for rollups
+	Value     *float64 `json:"v,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Avg       *float64 `json:"avg,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Count     int      `json:"c,omitempty"`
+}
+
+// rawRecord returns a record of a single value.
+func rawRecord(s series, t time.Time, value float64) record {
+	return record{
+		Source:    s.source,
+		Statistic: s.statistic,
+		Instance:  s.instance,
+		Time:      unixMilli(t),
+		Value:     &value,
+	}
+}
+
+// rollupRecord returns a record of the values in b.
+func rollupRecord(s series, b *bucket) record {
+	min, max, avg := b.min, b.max, b.sum/float64(b.count)
+	return record{
+		Source:    s.source,
+		Statistic: s.statistic,
+		Instance:  s.instance,
+		Time:      unixMilli(b.start),
+		Min:       &min,
+		Avg:       &avg,
+		Max:       &max,
+		Count:     b.count,
+	}
+}
+
+// point returns the point described by the record.
+func (r record) point() Point {
+	p := Point{
+		Instance: r.Instance,
+		Time:     time.Unix(0, r.Time*int64(time.Millisecond)),
+		Count:    r.Count,
+	}
+	switch {
+	case r.Value != nil:
+		p.Min, p.Avg, p.Max, p.Count = *r.Value, *r.Value, *r.Value, 1
+	case r.Min != nil && r.Avg != nil && r.Max != nil:
+		p.Min, p.Avg, p.Max = *r.Min, *r.Avg, *r.Max
+	}
+	return p
+}
+
+// segmentStart returns the start of the segment that holds records at t.
+func (t *tier) segmentStart(at time.Time) time.Time {
+	return at.UTC().Truncate(t.span)
+}
+
+// path returns the path of the segment that starts at start.
+func (t *tier) path(start time.Time) string {
+	return filepath.Join(t.dir, start.UTC().Format(segmentLayout)+segmentExt)
+}
+
+// append writes r to the segment that covers its time, opening the segment
+// if necessary.
+func (t *tier) append(r record) error {
+	start := t.segmentStart(time.Unix(0, r.Time*int64(time.Millisecond)))
+	if t.file == nil || !t.start.Equal(start) {
+		if err := t.close(); err != nil {
+			return err
+		}
+		f, err := openSegment(t.path(start))
+		if err != nil {
+			return fmt.Errorf("unable to open history segment: %v", err)
+		}
+		t.file, t.w, t.start = f, bufio.NewWriter(f), start
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = t.w.Write(data)
+	return err
+}
+
+// openSegment opens the segment file at path for appending, creating it if
+// necessary. If the file ends with an incomplete line, such as one left by a
+// crash, the line is terminated so that it doesn't corrupt the next record.
+func openSegment(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if size := info.Size(); size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
+
+// flush writes buffered records to the open segment.
+func (t *tier) flush() error {
+	if t.w == nil {
+		return nil
+	}
+	return t.w.Flush()
+}
+
+// close flushes and closes the open segment.
+func (t *tier) close() error {
+	if t.file == nil {
+		return nil
+	}
+	err := t.w.Flush()
+	if cErr := t.file.Close(); cErr != nil && err == nil {
+		err = cErr
+	}
+	t.file, t.w = nil, nil
+	return err
+}
+
+// segments returns the start times of the tier's segments in order.
+func (t *tier) segments() ([]time.Time, error) {
+	infos, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+	var starts []time.Time
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		start, err := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentExt))
+		if err != nil {
+			continue
+		}
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
+
+// prune removes segments whose records have all expired.
+func (t *tier) prune(now time.Time) error {
+	starts, err := t.segments()
+	if err != nil {
+		return err
+	}
+	cutoff := now.Add(-t.retention)
+	for _, start := range starts {
+		if start.Add(t.span).After(cutoff) {
+			continue
+		}
+		if t.file != nil && t.start.Equal(start) {
+			t.close()
+		}
+		if err := os.Remove(t.path(start)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// segmentFile is a segment as it was when its tier was examined.
+type segmentFile struct {
+	path string
+	size int64
+}
+
+// overlapping returns the segments that overlap the given range along with
+// their current sizes. Reading no more than those sizes excludes records that
+// are appended afterwards.
+func (t *tier) overlapping(from, to time.Time) (files []segmentFile, err error) {
+	starts, err := t.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, start := range starts {
+		if !start.Before(to) || !start.Add(t.span).After(from) {
+			continue
+		}
+		info, err := os.Stat(t.path(start))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, segmentFile{path: t.path(start), size: info.Size()})
+	}
+	return files, nil
+}
+
+// readSegment calls fn for each record within the given segment. Lines that
+// can't be decoded, such as those left incomplete by a crash, are skipped.
+func readSegment(segment segmentFile, fn func(record)) error {
+	f, err := os.Open(segment.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, segment.size))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r record
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		fn(r)
+	}
+	return scanner.Err()
+}
+
+// unixMilli returns t as the number of milliseconds since the Unix epoch.
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
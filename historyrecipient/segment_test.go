@@ -0,0 +1,78 @@
+package historyrecipient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPartialSegment(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "raw"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash left a corrupt line and an incomplete final line
+	path := filepath.Join(dir, "raw", "20261019.jsonl")
+	data := `{"s":"rack","n":"OutputPercentLoad","t":1792368060000,"v":10}` + "\n" +
+		`not a record` + "\n" +
+		`{"s":"rack","n":"OutputPercentLoad","t":17923681`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := open(t, dir, Options{})
+	query := Query{Source: "rack", Statistic: "OutputPercentLoad", From: base, To: base.Add(time.Hour), Resolution: Raw}
+
+	points, _, err := h.Range(query)
+	if err != nil {
+		t.Fatalf("Range returned %v", err)
+	}
+	checkPoints(t, "before", points, []Point{
+		{Time: base.Add(time.Minute), Min: 10, Avg: 10, Max: 10, Count: 1},
+	})
+
+	// Records appended after the incomplete line are kept
+	h.Send(value(3*time.Minute, 30))
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	points, _, err = h.Range(query)
+	if err != nil {
+		t.Fatalf("Range returned %v", err)
+	}
+	checkPoints(t, "after", points, []Point{
+		{Time: base.Add(time.Minute), Min: 10, Avg: 10, Max: 10, Count: 1},
+		{Time: base.Add(3 * time.Minute), Min: 30, Avg: 30, Max: 30, Count: 1},
+	})
+}
+
+func TestReadSegmentSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "20261019.jsonl")
+	first := `{"s":"rack","n":"OutputPercentLoad","t":1792368060000,"v":10}` + "\n"
+	second := `{"s":"rack","n":"OutputPercentLoad","t":1792368120000,"v":20}` + "\n"
+	if err := ioutil.WriteFile(path, []byte(first+second), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the records within the size captured by the query are read
+	var values []float64
+	err := readSegment(segmentFile{path: path, size: int64(len(first))}, func(r record) {
+		values = append(values, *r.Value)
+	})
+	if err != nil {
+		t.Fatalf("readSegment returned %v", err)
+	}
+	if len(values) != 1 || values[0] != 10 {
+		t.Fatalf("readSegment read %v, want [10]", values)
+	}
+
+	// Missing segments, such as those pruned during a query, are empty
+	if err := readSegment(segmentFile{path: path + ".missing", size: 100}, func(record) {
+		t.Fatal("record read from a missing segment")
+	}); err != nil {
+		t.Fatalf("readSegment returned %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package power
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// SNMPv2-MIB and UPS-MIB identification object identifiers
+var (
+	sysDescr      = snmpgo.MustNewOid("1.3.6.1.2.1.1.1.0")
+	sysObjectID   = snmpgo.MustNewOid("1.3.6.1.2.1.1.2.0")
+	upsIdentModel = snmpgo.MustNewOid("1.3.6.1.2.1.33.1.1.2.0")
+)
+
+// Identity describes the device behind a power source.
+type Identity struct {
+	ObjectID    string  // sysObjectID
+	Description string  // sysDescr
+	Vendor      *Vendor // Detected vendor profile
+	Model       string  // Device model, if reported
+}
+
+// String returns a string representation of the identity.
+func (id Identity) String() string {
+	if id.Vendor == nil {
+		return "unidentified"
+	}
+	if id.Model == "" {
+		return id.Vendor.Name
+	}
+	return fmt.Sprintf("%s %s", id.Vendor.Name, id.Model)
+}
+
+var identities = struct {
+	sync.Mutex
+	m map[string]Identity
+}{m: make(map[string]Identity)}
+
+// Identify returns the identity of the device behind the given source. For
+// SNMP sources the identity is retrieved the first time a source is
+// identified and is cached thereafter.
+//
+// Sources are identified by the collector registered for their scheme. If the
+// collector is not an Identifier, an error is returned.
+func Identify(ctx context.Context, source Source) (id Identity, err error) {
+	if id, found := cachedIdentity(source); found {
+		return id, nil
+	}
+
+	c, err := collectorFor(source)
+	if err != nil {
+		return
+	}
+	identifier, ok := c.(Identifier)
+	if !ok {
+		return id, fmt.Errorf("identification is not supported for scheme \"%s\"", source.Scheme)
+	}
+	return identifier.Identify(ctx, source)
+}
+
+// cachedIdentity returns the cached identity of source, if present.
+func cachedIdentity(source Source) (id Identity, found bool) {
+	identities.Lock()
+	defer identities.Unlock()
+	id, found = identities.m[source.String()]
+	return
+}
+
+// identify returns the identity of the device behind the given source using
+// an existing SNMP connection. Successful results are cached.
+func identify(ctx context.Context, snmp *snmpgo.SNMP, source Source) (id Identity, err error) {
+	if id, found := cachedIdentity(source); found {
+		return id, nil
+	}
+
+	bindings, err := query(ctx, snmp, snmpgo.Oids{sysObjectID, sysDescr})
+	if err != nil {
+		return
+	}
+
+	var objectID *snmpgo.Oid
+	if binding := bindings.MatchOid(sysObjectID); binding != nil {
+		if oid, ok := binding.Variable.(*snmpgo.Oid); ok {
+			objectID = oid
+			id.ObjectID = oid.String()
+		}
+	}
+	id.Description = octetString(bindings.MatchOid(sysDescr))
+
+	if id.ObjectID == "" && id.Description == "" {
+		err = errors.New("no system identification returned")
+		return
+	}
+
+	id.Vendor = DetectVendor(objectID, id.Description)
+
+	// The model is optional; failure to retrieve it is not fatal
+	models := append(append(snmpgo.Oids{}, id.Vendor.Model...), upsIdentModel)
+	if bindings, modelErr := query(ctx, snmp, models); modelErr == nil {
+		for _, oid := range models {
+			if model := octetString(bindings.MatchOid(oid)); model != "" {
+				id.Model = model
+				break
+			}
+		}
+	}
+
+	identities.Lock()
+	identities.m[source.String()] = id
+	identities.Unlock()
+
+	return
+}
+
+// octetString returns the trimmed string value of the given binding if it
+// holds an octet string.
+func octetString(binding *snmpgo.VarBind) string {
+	if binding == nil {
+		return ""
+	}
+	if s, ok := binding.Variable.(*snmpgo.OctetString); ok {
+		return strings.TrimSpace(string(s.Value))
+	}
+	return ""
+}
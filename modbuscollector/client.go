@@ -0,0 +1,135 @@
+package modbuscollector
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/scjalliance/power/modbusvar"
+)
+
+// protocolID is the protocol identifier of Modbus in MBAP headers.
+const protocolID = 0
+
+// maxRegisters is the largest number of registers that may be read by a
+// single request.
+const maxRegisters = 125
+
+// ExceptionError is an exception reported by a Modbus device.
+type ExceptionError struct {
+	Function modbusvar.Function
+	Code     uint8
+}
+
+var exceptionNames = map[uint8]string{
+	1:  "illegal function",
+	2:  "illegal data address",
+	3:  "illegal data value",
+	4:  "server device failure",
+	6:  "server device busy",
+	10: "gateway path unavailable",
+	11: "gateway target device failed to respond",
+}
+
+// Error returns a string representation of the exception.
+func (e ExceptionError) Error() string {
+	if name, ok := exceptionNames[e.Code]; ok {
+		return fmt.Sprintf("modbus exception %d (%s) for function %d", e.Code, name, e.Function)
+	}
+	return fmt.Sprintf("modbus exception %d for function %d", e.Code, e.Function)
+}
+
+// client is a Modbus TCP connection.
+type client struct {
+	conn        net.Conn
+	timeout     time.Duration
+	transaction uint16
+}
+
+// dial connects to the Modbus TCP server at address. Each request must
+// complete within timeout.
+func dial(ctx context.Context, address string, timeout time.Duration) (*client, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &client{conn: conn, timeout: timeout}, nil
+}
+
+// Close closes the connection.
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+// ReadRegisters reads count registers starting at address from the given
+// unit using function.
+func (c *client) ReadRegisters(unit uint8, function modbusvar.Function, address uint16, count int) ([]uint16, error) {
+	if count < 1 || count > maxRegisters {
+		return nil, fmt.Errorf("invalid register count %d", count)
+	}
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	c.transaction++
+
+	// MBAP header followed by the request PDU
+	request := make([]byte, 12)
+	binary.BigEndian.PutUint16(request[0:], c.transaction)
+	binary.BigEndian.PutUint16(request[2:], protocolID)
+	binary.BigEndian.PutUint16(request[4:], 6)
+	request[6] = unit
+	request[7] = byte(function)
+	binary.BigEndian.PutUint16(request[8:], address)
+	binary.BigEndian.PutUint16(request[10:], uint16(count))
+	if _, err := c.conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	// Responses to earlier requests that timed out are skipped
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if length < 2 {
+			return nil, fmt.Errorf("invalid response length %d", length)
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(c.conn, pdu); err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint16(header[0:]) != c.transaction {
+			continue
+		}
+		if binary.BigEndian.Uint16(header[2:]) != protocolID {
+			return nil, fmt.Errorf("unexpected protocol identifier %d", binary.BigEndian.Uint16(header[2:]))
+		}
+		return parseResponse(function, count, pdu)
+	}
+}
+
+// parseResponse returns the registers in the response PDU to a read
+// request.
+func parseResponse(function modbusvar.Function, count int, pdu []byte) ([]uint16, error) {
+	switch {
+	case pdu[0] == byte(function)|0x80:
+		if len(pdu) < 2 {
+			return nil, fmt.Errorf("truncated exception response")
+		}
+		return nil, ExceptionError{Function: function, Code: pdu[1]}
+	case pdu[0] != byte(function):
+		return nil, fmt.Errorf("unexpected function %d in response", pdu[0])
+	case len(pdu) < 2 || int(pdu[1]) != 2*count || len(pdu) != 2+2*count:
+		return nil, fmt.Errorf("unexpected register data length in response")
+	}
+
+	words := make([]uint16, count)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint16(pdu[2+2*i:])
+	}
+	return words, nil
+}
@@ -0,0 +1,109 @@
+// Package modbuscollector collects power statistics from devices that speak
+// Modbus TCP, such as three-phase UPSes and generator controllers.
+//
+// Sources are described by URLs in this form:
+//
+//	modbus://host:port~name
+//
+// Modbus devices don't share a common register map, so only statistics with
+// a Register are collected. The register's unit identifier selects the
+// device when the source is a gateway.
+package modbuscollector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/modbusvar"
+)
+
+// Scheme is the URL scheme of Modbus TCP sources.
+const Scheme = "modbus"
+
+// DefaultPort is the default port of Modbus TCP servers.
+const DefaultPort = 502
+
+type collector struct{}
+
+// Collector is a collector that queries Modbus TCP servers. It must be
+// registered with power.RegisterCollector before Modbus sources can be
+// parsed.
+var Collector collector
+
+// read identifies a set of registers read by a single request.
+type read struct {
+	unit     uint8
+	function modbusvar.Function
+	address  uint16
+	count    int
+}
+
+// readResult holds the outcome of a read.
+type readResult struct {
+	words []uint16
+	err   error
+}
+
+// Collect reads the register of each statistic from the source. Statistics
+// without a register are reported as not supported.
+func (collector) Collect(ctx context.Context, source power.Source, stats []power.Statistic) (results []power.Value, err error) {
+	timeout := source.Timeout
+	if timeout <= 0 {
+		timeout = power.DefaultTimeout
+	}
+
+	c, err := dial(ctx, source.HostPort(), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	// Statistics that share the same registers are derived from a single
+	// request
+	fetched := make(map[read]readResult)
+
+	for _, stat := range stats {
+		value := power.Value{
+			Source: source,
+			Stat:   stat,
+			Time:   time.Now(),
+		}
+
+		r := stat.Register
+		if r == nil {
+			value.Err = power.ErrNoSuchObject
+			results = append(results, value)
+			continue
+		}
+
+		key := read{unit: r.Unit, function: r.Function, address: r.Address, count: r.Words()}
+		result, found := fetched[key]
+		if !found {
+			result.words, result.err = c.ReadRegisters(key.unit, key.function, key.address, key.count)
+			fetched[key] = result
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		}
+
+		if result.err != nil {
+			value.Err = fmt.Errorf("unable to read register %s: %v", r, result.err)
+		} else {
+			value.Value, value.Err = r.Value(result.words)
+		}
+		results = append(results, value)
+	}
+	return
+}
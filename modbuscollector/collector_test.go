@@ -0,0 +1,117 @@
+package modbuscollector_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/modbuscollector"
+	"github.com/scjalliance/power/modbusvar"
+	"github.com/scjalliance/power/powertest"
+)
+
+// mustParse returns the statistic described by s, which must be valid.
+func mustParse(s string) power.Statistic {
+	stat, err := power.ParseStatistic(s)
+	if err != nil {
+		panic(err)
+	}
+	return stat
+}
+
+func TestCollect(t *testing.T) {
+	registers := make(powertest.Registers)
+	registers.Set(modbusvar.ReadInputRegisters, 30, 2305)              // 230.5 V in tenths
+	registers.Set(modbusvar.ReadInputRegisters, 31, 0xfff6)            // -10 as int16
+	registers.Set(modbusvar.ReadHoldingRegisters, 100, 0x8000, 0x4366) // 230.5 as float32, low word first
+	registers.Set(modbusvar.ReadHoldingRegisters, 200, 0x0001, 0x86a0) // 100000 as uint32
+	registers.Set(modbusvar.ReadHoldingRegisters, 30, 0x0064)          // Same address, other function
+
+	server, err := powertest.StartModbus("", registers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	tests := []struct {
+		stat        power.Statistic
+		value       float64
+		unsupported bool   // The value should carry a not supported error
+		err         string // Expected error text, if any
+	}{
+		{stat: mustParse("name:InputVoltage,register:1/4/30/int16/0.1"), value: 230.5},
+		{stat: mustParse("name:Offset,register:1/4/31/int16"), value: -10},
+		{stat: mustParse("name:RawOffset,register:1/4/31/uint16"), value: 65526},
+		{stat: mustParse("name:Frequency,register:1/3/100/float32/lowfirst"), value: 230.5},
+		{stat: mustParse("name:Energy,register:2/3/200/uint32"), value: 100000},
+		{stat: mustParse("name:Holding,register:1/3/30/uint16"), value: 100},
+		{stat: mustParse("name:Missing,register:1/3/400/uint16"), err: "illegal data address"},
+		{stat: power.EstimatedChargeRemaining, unsupported: true},
+	}
+
+	stats := make([]power.Statistic, len(tests))
+	for i, test := range tests {
+		stats[i] = test.stat
+	}
+
+	values, err := modbuscollector.Collector.Collect(context.Background(), server.Source(), stats)
+	if err != nil {
+		t.Fatalf("Collect returned %v", err)
+	}
+	if len(values) != len(tests) {
+		t.Fatalf("Collect returned %d values, want %d", len(values), len(tests))
+	}
+	for i, v := range values {
+		test := tests[i]
+		switch {
+		case test.unsupported:
+			if !power.IsNotSupported(v.Err) {
+				t.Errorf("%s: got %v (%v), want not supported", v.Stat.Name, v.Value, v.Err)
+			}
+		case test.err != "":
+			if v.Err == nil || !strings.Contains(v.Err.Error(), test.err) {
+				t.Errorf("%s: got %v (%v), want error containing \"%s\"", v.Stat.Name, v.Value, v.Err, test.err)
+			}
+		case v.Err != nil:
+			t.Errorf("%s: %v", v.Stat.Name, v.Err)
+		case v.Value != test.value:
+			t.Errorf("%s: got %v, want %v", v.Stat.Name, v.Value, test.value)
+		}
+	}
+}
+
+func TestCollectUpdated(t *testing.T) {
+	registers := make(powertest.Registers)
+	registers.Set(modbusvar.ReadInputRegisters, 0, 1)
+	server, err := powertest.StartModbus("", registers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	stat := mustParse("name:Running,register:1/4/0/uint16")
+	for _, want := range []uint16{1, 0, 1} {
+		server.Set(modbusvar.ReadInputRegisters, 0, want)
+		values, err := modbuscollector.Collector.Collect(context.Background(), server.Source(), []power.Statistic{stat})
+		if err != nil {
+			t.Fatalf("Collect returned %v", err)
+		}
+		if v := values[0]; v.Err != nil || v.Value != float64(want) {
+			t.Fatalf("got %v (%v), want %d", v.Value, v.Err, want)
+		}
+	}
+}
+
+func TestCollectUnreachable(t *testing.T) {
+	server, err := powertest.StartModbus("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := server.Source()
+	server.Close()
+
+	if _, err := modbuscollector.Collector.Collect(context.Background(), source, []power.Statistic{mustParse("name:X,register:1/4/0/uint16")}); err == nil {
+		t.Fatal("Collect succeeded against a closed server")
+	}
+}
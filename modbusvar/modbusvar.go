@@ -0,0 +1,245 @@
+// Package modbusvar describes Modbus registers and decodes their contents as
+// float64 values.
+//
+// Decoded values may be scaled by a register's Scale. Other mappings are left
+// to the statistics that use the register.
+package modbusvar
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Function is a Modbus function code used to read registers.
+type Function uint8
+
+// Register read functions
+const (
+	ReadHoldingRegisters Function = 3
+	ReadInputRegisters   Function = 4
+)
+
+// Type is the data type of a register value.
+type Type int
+
+// Register data types
+const (
+	Int16 Type = iota
+	Uint16
+	Int32
+	Uint32
+	Float32
+)
+
+var typeNames = map[Type]string{
+	Int16:   "int16",
+	Uint16:  "uint16",
+	Int32:   "int32",
+	Uint32:  "uint32",
+	Float32: "float32",
+}
+
+// String returns the name of the type.
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type %d", int(t))
+}
+
+// Words returns the number of 16-bit registers occupied by a value of the
+// type.
+func (t Type) Words() int {
+	switch t {
+	case Int32, Uint32, Float32:
+		return 2
+	}
+	return 1
+}
+
+// WordOrder is the order in which the registers of multi-register values
+// are stored. The bytes within each register are always big-endian.
+type WordOrder int
+
+// Word orders
+const (
+	HighWordFirst WordOrder = iota
+	LowWordFirst
+)
+
+var wordOrderNames = map[WordOrder]string{
+	HighWordFirst: "highfirst",
+	LowWordFirst:  "lowfirst",
+}
+
+// String returns the name of the word order.
+func (o WordOrder) String() string {
+	if name, ok := wordOrderNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("word order %d", int(o))
+}
+
+// Register describes a value held in one or more Modbus registers.
+type Register struct {
+	Unit     uint8    // Unit identifier of the device behind a gateway
+	Function Function // Function code used to read the register
+	Address  uint16   // Zero-based address of the first register
+	Type     Type
+	Order    WordOrder // Order of the registers of multi-register values
+	Scale    float64   // Optional multiplier applied after decoding, zero to leave values unscaled
+}
+
+// Words returns the number of registers occupied by the value.
+func (r Register) Words() int {
+	return r.Type.Words()
+}
+
+// String returns a string representation of the register in the format
+// accepted by ParseRegister.
+func (r Register) String() string {
+	value := fmt.Sprintf("%d/%d/%d/%s", r.Unit, r.Function, r.Address, r.Type)
+	if r.Order != HighWordFirst {
+		value = fmt.Sprintf("%s/%s", value, r.Order)
+	}
+	if r.Scale != 0 {
+		value = fmt.Sprintf("%s/%s", value, strconv.FormatFloat(r.Scale, 'g', -1, 64))
+	}
+	return value
+}
+
+// Value decodes the given register contents and applies the register's
+// scale.
+func (r Register) Value(words []uint16) (float64, error) {
+	value, err := Decode(words, r.Type, r.Order)
+	if err != nil {
+		return 0, err
+	}
+	if r.Scale != 0 {
+		value *= r.Scale
+	}
+	return value, nil
+}
+
+// Decode returns the value of type t held in the given register contents.
+func Decode(words []uint16, t Type, order WordOrder) (float64, error) {
+	if len(words) != t.Words() {
+		return 0, fmt.Errorf("%s value requires %d registers but %d were provided", t, t.Words(), len(words))
+	}
+
+	var v uint32
+	if len(words) == 2 {
+		high, low := words[0], words[1]
+		if order == LowWordFirst {
+			high, low = low, high
+		}
+		v = uint32(high)<<16 | uint32(low)
+	} else {
+		v = uint32(words[0])
+	}
+
+	switch t {
+	case Int16:
+		return float64(int16(v)), nil
+	case Uint16, Uint32:
+		return float64(v), nil
+	case Int32:
+		return float64(int32(v)), nil
+	case Float32:
+		return float64(math.Float32frombits(v)), nil
+	}
+	return 0, fmt.Errorf("unsupported register type: %s", t)
+}
+
+// ParseRegister parses a register description in this form:
+//
+//	UNIT/FUNCTION/ADDRESS/TYPE/ORDER/SCALE
+//
+// The function is 3 for holding registers or 4 for input registers. The
+// type is one of int16, uint16, int32, uint32 or float32, and the word order
+// is either highfirst or lowfirst. The order and scale are optional, and
+// the scale multiplies the decoded value.
+//
+// Examples:
+//
+//	"1/4/30/uint16"
+//	"1/3/100/float32/lowfirst"
+//	"1/4/30/int16/0.1"
+func ParseRegister(s string) (r Register, err error) {
+	elements := strings.Split(s, "/")
+	if len(elements) < 4 || len(elements) > 6 {
+		err = fmt.Errorf("malformed register description: \"%s\"", s)
+		return
+	}
+
+	unit, err := strconv.ParseUint(elements[0], 10, 8)
+	if err != nil {
+		err = fmt.Errorf("invalid unit identifier in register description \"%s\": %v", s, err)
+		return
+	}
+	r.Unit = uint8(unit)
+
+	function, err := strconv.ParseUint(elements[1], 10, 8)
+	if err != nil {
+		err = fmt.Errorf("invalid function code in register description \"%s\": %v", s, err)
+		return
+	}
+	r.Function = Function(function)
+	if r.Function != ReadHoldingRegisters && r.Function != ReadInputRegisters {
+		err = fmt.Errorf("unsupported function code %d in register description \"%s\"", function, s)
+		return
+	}
+
+	address, err := strconv.ParseUint(elements[2], 10, 16)
+	if err != nil {
+		err = fmt.Errorf("invalid address in register description \"%s\": %v", s, err)
+		return
+	}
+	r.Address = uint16(address)
+
+	if r.Type, err = parseType(elements[3]); err != nil {
+		err = fmt.Errorf("%v in register description \"%s\"", err, s)
+		return
+	}
+
+	for _, element := range elements[4:] {
+		if order, ok := parseWordOrder(element); ok {
+			r.Order = order
+			continue
+		}
+		scale, pErr := strconv.ParseFloat(element, 64)
+		if pErr != nil {
+			err = fmt.Errorf("unknown word order or scale \"%s\" in register description \"%s\"", element, s)
+			return
+		}
+		if scale == 0 {
+			err = fmt.Errorf("zero scale in register description \"%s\"", s)
+			return
+		}
+		r.Scale = scale
+	}
+
+	return
+}
+
+// parseType returns the type with the given name.
+func parseType(s string) (Type, error) {
+	for t, name := range typeNames {
+		if strings.EqualFold(name, s) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown register type \"%s\"", s)
+}
+
+// parseWordOrder returns the word order with the given name.
+func parseWordOrder(s string) (WordOrder, bool) {
+	for order, name := range wordOrderNames {
+		if strings.EqualFold(name, s) {
+			return order, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package modbusvar
+
+import (
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []uint16
+		typ   Type
+		order WordOrder
+		want  float64
+		fail  bool
+	}{
+		{name: "int16 positive", words: []uint16{0x0901}, typ: Int16, want: 2305},
+		{name: "int16 negative", words: []uint16{0xffff}, typ: Int16, want: -1},
+		{name: "uint16", words: []uint16{0xffff}, typ: Uint16, want: 65535},
+		{name: "int32 high first", words: []uint16{0xffff, 0xfffe}, typ: Int32, want: -2},
+		{name: "int32 low first", words: []uint16{0xfffe, 0xffff}, typ: Int32, order: LowWordFirst, want: -2},
+		{name: "uint32 high first", words: []uint16{0x0001, 0x0000}, typ: Uint32, want: 65536},
+		{name: "uint32 low first", words: []uint16{0x0001, 0x0000}, typ: Uint32, order: LowWordFirst, want: 1},
+		{name: "uint32 unsigned", words: []uint16{0xffff, 0xffff}, typ: Uint32, want: 4294967295},
+		{name: "float32 high first", words: []uint16{0x4366, 0x8000}, typ: Float32, want: 230.5},
+		{name: "float32 low first", words: []uint16{0x8000, 0x4366}, typ: Float32, order: LowWordFirst, want: 230.5},
+		{name: "float32 negative", words: []uint16{0xbf80, 0x0000}, typ: Float32, want: -1},
+		{name: "too few words", words: []uint16{0x0001}, typ: Int32, fail: true},
+		{name: "too many words", words: []uint16{0x0001, 0x0002}, typ: Uint16, fail: true},
+		{name: "unknown type", words: []uint16{0x0001}, typ: Type(99), fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := Decode(test.words, test.typ, test.order)
+			switch {
+			case test.fail:
+				if err == nil {
+					t.Fatalf("Decode returned %v, want an error", value)
+				}
+			case err != nil:
+				t.Fatalf("Decode returned %v", err)
+			case value != test.want:
+				t.Fatalf("Decode returned %v, want %v", value, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRegister(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Register
+	}{
+		{spec: "1/4/30/uint16", want: Register{Unit: 1, Function: ReadInputRegisters, Address: 30, Type: Uint16}},
+		{spec: "1/3/100/float32/lowfirst", want: Register{Unit: 1, Function: ReadHoldingRegisters, Address: 100, Type: Float32, Order: LowWordFirst}},
+		{spec: "1/4/30/int16/0.1", want: Register{Unit: 1, Function: ReadInputRegisters, Address: 30, Type: Int16, Scale: 0.1}},
+		{spec: "247/3/65535/INT32/LowFirst/-10", want: Register{Unit: 247, Function: ReadHoldingRegisters, Address: 65535, Type: Int32, Order: LowWordFirst, Scale: -10}},
+		{spec: "0/3/0/uint32/highfirst", want: Register{Function: ReadHoldingRegisters, Type: Uint32}},
+	}
+
+	for _, test := range tests {
+		r, err := ParseRegister(test.spec)
+		if err != nil {
+			t.Errorf("%s: ParseRegister returned %v", test.spec, err)
+			continue
+		}
+		if r != test.want {
+			t.Errorf("%s: ParseRegister returned %+v, want %+v", test.spec, r, test.want)
+		}
+
+		// The string representation must describe the same register
+		if again, err := ParseRegister(r.String()); err != nil || again != r {
+			t.Errorf("%s: %s parsed as %+v (%v)", test.spec, r, again, err)
+		}
+	}
+}
+
+func TestParseRegisterInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"1/4/30",
+		"1/4/30/uint16/lowfirst/0.1/extra",
+		"x/4/30/uint16",
+		"256/4/30/uint16",
+		"1/6/30/uint16",
+		"1/4/-1/uint16",
+		"1/4/65536/uint16",
+		"1/4/30/int64",
+		"1/4/30/uint16/middlefirst",
+		"1/4/30/uint16/0",
+	} {
+		if r, err := ParseRegister(spec); err == nil {
+			t.Errorf("%s: ParseRegister returned %s, want an error", spec, r)
+		}
+	}
+}
+
+func TestRegisterValue(t *testing.T) {
+	r := Register{Type: Int16, Scale: 0.1}
+	value, err := r.Value([]uint16{0x0901})
+	if err != nil {
+		t.Fatalf("Value returned %v", err)
+	}
+	if value != 230.5 {
+		t.Fatalf("Value returned %v, want 230.5", value)
+	}
+}
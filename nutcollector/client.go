@@ -0,0 +1,213 @@
+package nutcollector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Error is an error reported by a NUT server, such as "UNKNOWN-UPS" or
+// "VAR-NOT-SUPPORTED".
+type Error struct {
+	Code string
+}
+
+// Error returns a string representation of the error.
+func (e Error) Error() string {
+	return fmt.Sprintf("nut server error: %s", strings.ToLower(e.Code))
+}
+
+// errVarNotSupported is the code of errors reported by the NUT server when
+// a variable is not supported by a UPS.
+const errVarNotSupported = "VAR-NOT-SUPPORTED"
+
+// client is a connection to a NUT server that speaks the network protocol
+// described in the NUT developer guide.
+type client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+// dial connects to the NUT server at address. Each command must complete
+// within timeout.
+func dial(ctx context.Context, address string, timeout time.Duration) (*client, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: timeout,
+	}, nil
+}
+
+// Close logs out of the server and closes the connection.
+func (c *client) Close() error {
+	c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+// Login authenticates with the server using the given username and
+// password. A blank password is not sent.
+func (c *client) Login(username, password string) error {
+	if err := c.expectOK("USERNAME", username); err != nil {
+		return err
+	}
+	if password == "" {
+		return nil
+	}
+	return c.expectOK("PASSWORD", password)
+}
+
+// ListVars returns all of the variables of the given UPS.
+func (c *client) ListVars(ups string) (map[string]string, error) {
+	fields, err := c.command("LIST", "VAR", ups)
+	if err != nil {
+		return nil, err
+	}
+	if !match(fields, "BEGIN", "LIST", "VAR", ups) {
+		return nil, fmt.Errorf("unexpected response to LIST VAR: %s", strings.Join(fields, " "))
+	}
+
+	vars := make(map[string]string)
+	for {
+		fields, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if match(fields, "END", "LIST", "VAR", ups) {
+			return vars, nil
+		}
+		if len(fields) != 4 || !match(fields, "VAR", ups) {
+			return nil, fmt.Errorf("unexpected variable in LIST VAR: %s", strings.Join(fields, " "))
+		}
+		vars[fields[2]] = fields[3]
+	}
+}
+
+// Get returns the value of a single variable of the given UPS.
+func (c *client) Get(ups, name string) (string, error) {
+	fields, err := c.command("GET", "VAR", ups, name)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) != 4 || !match(fields, "VAR", ups, name) {
+		return "", fmt.Errorf("unexpected response to GET VAR: %s", strings.Join(fields, " "))
+	}
+	return fields[3], nil
+}
+
+// expectOK sends a command and verifies that the server responds with "OK".
+func (c *client) expectOK(args ...string) error {
+	fields, err := c.command(args...)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 || fields[0] != "OK" {
+		return fmt.Errorf("unexpected response to %s: %s", args[0], strings.Join(fields, " "))
+	}
+	return nil
+}
+
+// command sends a command to the server and returns the fields of the first
+// line of its response.
+func (c *client) command(args ...string) ([]string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	if _, err := fmt.Fprintf(c.conn, "%s\n", strings.Join(quoted, " ")); err != nil {
+		return nil, err
+	}
+
+	return c.readLine()
+}
+
+// readLine reads a single line of a response and splits it into fields.
+// Error responses are returned as an Error.
+func (c *client) readLine() ([]string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := split(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) >= 2 && fields[0] == "ERR" {
+		return nil, Error{Code: fields[1]}
+	}
+	return fields, nil
+}
+
+// match returns true if fields begins with the given prefix.
+func match(fields []string, prefix ...string) bool {
+	if len(fields) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if fields[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// quote returns s as a protocol token, quoting and escaping it if
+// necessary.
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"\\") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// split splits a line of a response into fields. Fields are separated by
+// spaces and may be enclosed in double quotes, within which backslash
+// escapes are honored.
+func split(line string) (fields []string, err error) {
+	var (
+		field    strings.Builder
+		inField  bool
+		inQuotes bool
+		escaped  bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inField = true, true
+		case r == '"':
+			inQuotes, inField = !inQuotes, true
+		case r == ' ' && !inQuotes:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inQuotes || escaped {
+		return nil, errors.New("unterminated quoted field in response")
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package nutcollector
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "ups1", want: "ups1"},
+		{arg: "", want: `""`},
+		{arg: "two words", want: `"two words"`},
+		{arg: "tab\there", want: "\"tab\there\""},
+		{arg: `say "hi"`, want: `"say \"hi\""`},
+		{arg: `back\slash`, want: `"back\\slash"`},
+	}
+
+	for _, test := range tests {
+		if got := quote(test.arg); got != test.want {
+			t.Errorf("quote(%q) = %s, want %s", test.arg, got, test.want)
+		}
+		// Quoted arguments must be read back unchanged
+		if fields, err := split(quote(test.arg)); err != nil || len(fields) != 1 || fields[0] != test.arg {
+			t.Errorf("split(quote(%q)) = %q (%v)", test.arg, fields, err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		fail bool
+	}{
+		{line: "OK", want: []string{"OK"}},
+		{line: "OK Goodbye", want: []string{"OK", "Goodbye"}},
+		{line: `VAR ups1 ups.status "OL CHRG"`, want: []string{"VAR", "ups1", "ups.status", "OL CHRG"}},
+		{line: `VAR ups1 ups.model "Back-UPS \"ES\" 700"`, want: []string{"VAR", "ups1", "ups.model", `Back-UPS "ES" 700`}},
+		{line: `VAR ups1 ups.id ""`, want: []string{"VAR", "ups1", "ups.id", ""}},
+		{line: `VAR  ups1   x  "a\\b"`, want: []string{"VAR", "ups1", "x", `a\b`}},
+		{line: "", want: nil},
+		{line: `VAR ups1 x "unterminated`, fail: true},
+		{line: `VAR ups1 x trailing\`, fail: true},
+	}
+
+	for _, test := range tests {
+		fields, err := split(test.line)
+		switch {
+		case test.fail:
+			if err == nil {
+				t.Errorf("split(%q) = %q, want an error", test.line, fields)
+			}
+		case err != nil:
+			t.Errorf("split(%q) returned %v", test.line, err)
+		case !reflect.DeepEqual(fields, test.want):
+			t.Errorf("split(%q) = %q, want %q", test.line, fields, test.want)
+		}
+	}
+}
+
+// scripted returns a client connected to a server that answers the first
+// command it receives with response, then closes the connection. The
+// command is sent to the returned channel.
+func scripted(t *testing.T, response string) (*client, <-chan string) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	commands := make(chan string, 1)
+	go func() {
+		defer serverConn.Close()
+		line, err := bufio.NewReader(serverConn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		commands <- strings.TrimSuffix(line, "\n")
+		io.WriteString(serverConn, response)
+	}()
+	c := &client{conn: clientConn, r: bufio.NewReader(clientConn), timeout: time.Second}
+	t.Cleanup(func() { clientConn.Close() })
+	return c, commands
+}
+
+func TestListVars(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     map[string]string
+		code     string // Expected error code, if the server reports an error
+		fail     bool
+	}{
+		{
+			name: "list",
+			response: "BEGIN LIST VAR ups1\n" +
+				"VAR ups1 battery.charge \"100\"\n" +
+				"VAR ups1 ups.status \"OL CHRG\"\r\n" +
+				"VAR ups1 ups.model \"Smart-UPS \\\"X\\\"\"\n" +
+				"END LIST VAR ups1\n",
+			want: map[string]string{
+				"battery.charge": "100",
+				"ups.status":     "OL CHRG",
+				"ups.model":      `Smart-UPS "X"`,
+			},
+		},
+		{name: "empty", response: "BEGIN LIST VAR ups1\nEND LIST VAR ups1\n", want: map[string]string{}},
+		{name: "unknown UPS", response: "ERR UNKNOWN-UPS\n", code: "UNKNOWN-UPS"},
+		{name: "access denied", response: "ERR ACCESS-DENIED\n", code: "ACCESS-DENIED"},
+		{name: "other UPS", response: "BEGIN LIST VAR ups2\nEND LIST VAR ups2\n", fail: true},
+		{name: "malformed variable", response: "BEGIN LIST VAR ups1\nVAR ups1 battery.charge\nEND LIST VAR ups1\n", fail: true},
+		{name: "truncated", response: "BEGIN LIST VAR ups1\nVAR ups1 battery.charge \"100\"\n", fail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, commands := scripted(t, test.response)
+			vars, err := c.ListVars("ups1")
+			if command := <-commands; command != "LIST VAR ups1" {
+				t.Errorf("sent %q, want \"LIST VAR ups1\"", command)
+			}
+			switch {
+			case test.code != "":
+				if e, ok := err.(Error); !ok || e.Code != test.code {
+					t.Fatalf("ListVars returned %v, want %s", err, test.code)
+				}
+			case test.fail:
+				if err == nil {
+					t.Fatalf("ListVars returned %v, want an error", vars)
+				}
+			case err != nil:
+				t.Fatalf("ListVars returned %v", err)
+			case !reflect.DeepEqual(vars, test.want):
+				t.Fatalf("ListVars returned %v, want %v", vars, test.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	c, commands := scripted(t, "VAR \"my ups\" ups.mfr \"Eaton\"\n")
+	value, err := c.Get("my ups", "ups.mfr")
+	if err != nil {
+		t.Fatalf("Get returned %v", err)
+	}
+	if value != "Eaton" {
+		t.Fatalf("Get returned %q, want \"Eaton\"", value)
+	}
+	if command := <-commands; command != `GET VAR "my ups" ups.mfr` {
+		t.Fatalf("sent %q", command)
+	}
+
+	c, _ = scripted(t, "ERR VAR-NOT-SUPPORTED\n")
+	if _, err := c.Get("ups1", "device.mfr"); err != (Error{Code: errVarNotSupported}) {
+		t.Fatalf("Get returned %v, want %s", err, errVarNotSupported)
+	}
+}
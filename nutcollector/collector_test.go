@@ -0,0 +1,365 @@
+package nutcollector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// upsd is a fake NUT server that serves a fixed set of variables.
+type upsd struct {
+	listener net.Listener
+	password string                       // Required password, blank to accept any login
+	upses    map[string]map[string]string // Variables keyed by UPS name
+
+	mu       sync.Mutex
+	commands []string // Commands received, as split by the server
+}
+
+// startUPSD starts a fake NUT server that serves the given UPS variables.
+// It is shut down when the test completes.
+func startUPSD(t *testing.T, password string, upses map[string]map[string]string) *upsd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &upsd{listener: listener, password: password, upses: upses}
+	go d.serve()
+	t.Cleanup(func() { listener.Close() })
+	return d
+}
+
+// Source returns a source for the named UPS served by d.
+func (d *upsd) Source(t *testing.T, ups string) power.Source {
+	host, port, _ := net.SplitHostPort(d.listener.Addr().String())
+	return power.Source{
+		Scheme:  Scheme,
+		Name:    t.Name(), // Keep cached identities separate between tests
+		Host:    host,
+		Port:    port,
+		Path:    ups,
+		Timeout: time.Second,
+	}
+}
+
+// Commands returns the commands received so far.
+func (d *upsd) Commands() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.commands...)
+}
+
+func (d *upsd) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *upsd) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var (
+		r             = bufio.NewReader(conn)
+		username      string
+		authenticated = d.password == ""
+	)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields, err := split(strings.TrimSuffix(line, "\n"))
+		if err != nil || len(fields) == 0 {
+			fmt.Fprint(conn, "ERR INVALID-ARGUMENT\n")
+			continue
+		}
+		d.mu.Lock()
+		d.commands = append(d.commands, strings.Join(fields, " "))
+		d.mu.Unlock()
+
+		switch {
+		case match(fields, "USERNAME") && len(fields) == 2:
+			username = fields[1]
+			fmt.Fprint(conn, "OK\n")
+		case match(fields, "PASSWORD") && len(fields) == 2:
+			if username == "" || (d.password != "" && fields[1] != d.password) {
+				fmt.Fprint(conn, "ERR ACCESS-DENIED\n")
+				continue
+			}
+			authenticated = true
+			fmt.Fprint(conn, "OK\n")
+		case match(fields, "LOGOUT"):
+			fmt.Fprint(conn, "OK Goodbye\n")
+			return
+		case !authenticated:
+			fmt.Fprint(conn, "ERR ACCESS-DENIED\n")
+		case match(fields, "LIST", "VAR") && len(fields) == 3:
+			vars, ok := d.upses[fields[2]]
+			if !ok {
+				fmt.Fprint(conn, "ERR UNKNOWN-UPS\n")
+				continue
+			}
+			ups := quote(fields[2])
+			fmt.Fprintf(conn, "BEGIN LIST VAR %s\n", ups)
+			for name, value := range vars {
+				fmt.Fprintf(conn, "VAR %s %s %s\n", ups, name, escape(value))
+			}
+			fmt.Fprintf(conn, "END LIST VAR %s\n", ups)
+		case match(fields, "GET", "VAR") && len(fields) == 4:
+			vars, ok := d.upses[fields[2]]
+			if !ok {
+				fmt.Fprint(conn, "ERR UNKNOWN-UPS\n")
+				continue
+			}
+			value, ok := vars[fields[3]]
+			if !ok {
+				fmt.Fprint(conn, "ERR VAR-NOT-SUPPORTED\n")
+				continue
+			}
+			fmt.Fprintf(conn, "VAR %s %s %s\n", quote(fields[2]), fields[3], escape(value))
+		default:
+			fmt.Fprint(conn, "ERR UNKNOWN-COMMAND\n")
+		}
+	}
+}
+
+// escape returns value as a quoted string, the way upsd always sends
+// variable values.
+func escape(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// mustParse returns the statistic described by s, which must be valid.
+func mustParse(s string) power.Statistic {
+	stat, err := power.ParseStatistic(s)
+	if err != nil {
+		panic(err)
+	}
+	return stat
+}
+
+func TestCollect(t *testing.T) {
+	d := startUPSD(t, "", map[string]map[string]string{
+		"ups1": {
+			"battery.charge":        "87",
+			"battery.runtime":       "1800",
+			"battery.voltage":       " 27.1 ",
+			"ups.temperature":       "31.5", // Not a battery temperature
+			"input.voltage":         "121.0",
+			"input.voltage.nominal": "120",
+			"ups.load":              "23",
+			"ups.status":            "OB DISCHRG",
+			"ups.model":             "Smart-UPS 1500",
+		},
+	})
+
+	tests := []struct {
+		stat        power.Statistic
+		value       float64
+		unsupported bool // The value should carry a not supported error
+		fail        bool // The value should carry some other error
+	}{
+		{stat: power.EstimatedMinutesRemaining, value: 30},
+		{stat: power.EstimatedChargeRemaining, value: 87},
+		{stat: power.BatteryVoltage, value: 27.1},
+		{stat: power.BatteryTemperature, unsupported: true},
+		{stat: power.InputVoltage, value: 121},
+		{stat: power.OutputVoltage, unsupported: true},
+		{stat: power.OutputPercentLoad, value: 23},
+		{stat: power.OutputSource, value: power.OutputSourceBattery},
+		{stat: power.OnBattery, value: 1},
+		{stat: power.OnBypass, value: 0},
+		{stat: power.Regulating, value: 0},
+		{stat: power.APCBatteryCapacity, unsupported: true},
+		{stat: mustParse("name:NominalVoltage,path:input.voltage.nominal"), value: 120},
+		{stat: mustParse("name:Frequency,path:input.frequency"), unsupported: true},
+		{stat: mustParse("name:Model,path:ups.model"), fail: true},
+	}
+
+	stats := make([]power.Statistic, len(tests))
+	for i, test := range tests {
+		stats[i] = test.stat
+	}
+
+	values, err := Collector.Collect(context.Background(), d.Source(t, "ups1"), stats)
+	if err != nil {
+		t.Fatalf("Collect returned %v", err)
+	}
+	if len(values) != len(tests) {
+		t.Fatalf("Collect returned %d values, want %d", len(values), len(tests))
+	}
+
+	for i, test := range tests {
+		value := values[i]
+		if value.Stat.Name != test.stat.Name {
+			t.Errorf("value %d is for %s, want %s", i, value.Stat.Name, test.stat.Name)
+			continue
+		}
+		switch {
+		case test.unsupported:
+			if !power.IsNotSupported(value.Err) {
+				t.Errorf("%s: got %v (%v), want a not supported error", test.stat.Name, value.Value, value.Err)
+			}
+		case test.fail:
+			if value.Err == nil || power.IsNotSupported(value.Err) {
+				t.Errorf("%s: got %v (%v), want a parse error", test.stat.Name, value.Value, value.Err)
+			}
+		case value.Err != nil:
+			t.Errorf("%s: got error %v", test.stat.Name, value.Err)
+		case value.Value != test.value:
+			t.Errorf("%s: got %v, want %v", test.stat.Name, value.Value, test.value)
+		}
+	}
+
+	// The session should end with a logout
+	commands := d.Commands()
+	if len(commands) != 2 || commands[0] != "LIST VAR ups1" || commands[1] != "LOGOUT" {
+		t.Fatalf("server received %q", commands)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		status     string
+		source     float64
+		onBattery  float64
+		onBypass   float64
+		regulating float64
+	}{
+		{status: "OL", source: power.OutputSourceNormal},
+		{status: "OL CHRG", source: power.OutputSourceNormal},
+		{status: "OL BOOST", source: power.OutputSourceBooster, regulating: 1},
+		{status: "OL TRIM", source: power.OutputSourceReducer, regulating: 1},
+		{status: "OL BYPASS", source: power.OutputSourceBypass, onBypass: 1},
+		{status: "OB LB", source: power.OutputSourceBattery, onBattery: 1},
+		{status: "OFF", source: power.OutputSourceNone},
+		{status: "CAL", source: power.OutputSourceOther},
+	}
+
+	for _, test := range tests {
+		vars := map[string]string{"ups.status": test.status}
+		for _, want := range []struct {
+			stat  power.Statistic
+			value float64
+		}{
+			{power.OutputSource, test.source},
+			{power.OnBattery, test.onBattery},
+			{power.OnBypass, test.onBypass},
+			{power.Regulating, test.regulating},
+		} {
+			if value, err := lookup(want.stat, vars); err != nil || value != want.value {
+				t.Errorf("%s: %s = %v (%v), want %v", test.status, want.stat.Name, value, err, want.value)
+			}
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	d := startUPSD(t, "", map[string]map[string]string{
+		"rack ups": {
+			"ups.mfr":   "Eaton",
+			"ups.model": `5PX "G2" 1500`,
+		},
+		"nameless": {},
+	})
+
+	id, err := Collector.Identify(context.Background(), d.Source(t, "rack ups"))
+	if err != nil {
+		t.Fatalf("Identify returned %v", err)
+	}
+	if id.Vendor == nil || id.Vendor.Name != "Eaton" {
+		t.Errorf("Identify returned vendor %v, want Eaton", id.Vendor)
+	}
+	if id.Model != `5PX "G2" 1500` {
+		t.Errorf("Identify returned model %q", id.Model)
+	}
+	if id.Description != `Eaton 5PX "G2" 1500` {
+		t.Errorf("Identify returned description %q", id.Description)
+	}
+
+	// device.* variables are preferred over their ups.* equivalents
+	want := []string{
+		`GET VAR rack ups device.mfr`,
+		`GET VAR rack ups ups.mfr`,
+		`GET VAR rack ups device.model`,
+		`GET VAR rack ups ups.model`,
+		`LOGOUT`,
+	}
+	if commands := d.Commands(); strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("server received %q, want %q", commands, want)
+	}
+
+	// The identity is cached
+	if _, err := Collector.Identify(context.Background(), d.Source(t, "rack ups")); err != nil {
+		t.Fatalf("Identify returned %v", err)
+	}
+	if commands := d.Commands(); len(commands) != len(want) {
+		t.Errorf("cached identity was retrieved again: %q", commands[len(want):])
+	}
+
+	id, err = Collector.Identify(context.Background(), d.Source(t, "nameless"))
+	if err != nil {
+		t.Fatalf("Identify returned %v", err)
+	}
+	if id.Vendor != nil || id.Model != "" {
+		t.Errorf("Identify returned %+v for a UPS without identifying variables", id)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	d := startUPSD(t, "top secret", map[string]map[string]string{
+		"ups1": {"battery.charge": "100"},
+	})
+
+	source := d.Source(t, "ups1")
+	if _, err := Collector.Collect(context.Background(), source, []power.Statistic{power.EstimatedChargeRemaining}); err != (Error{Code: "ACCESS-DENIED"}) {
+		t.Errorf("Collect without credentials returned %v, want ACCESS-DENIED", err)
+	}
+
+	source.Credentials = power.Credentials{Username: "monitor", Password: "wrong"}
+	if _, err := Collector.Collect(context.Background(), source, []power.Statistic{power.EstimatedChargeRemaining}); err != (Error{Code: "ACCESS-DENIED"}) {
+		t.Errorf("Collect with the wrong password returned %v, want ACCESS-DENIED", err)
+	}
+
+	source.Credentials.Password = "top secret"
+	values, err := Collector.Collect(context.Background(), source, []power.Statistic{power.EstimatedChargeRemaining})
+	if err != nil {
+		t.Fatalf("Collect returned %v", err)
+	}
+	if len(values) != 1 || values[0].Err != nil || values[0].Value != 100 {
+		t.Fatalf("Collect returned %v", values)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	d := startUPSD(t, "", map[string]map[string]string{
+		"ups1": {"battery.charge": "100"},
+	})
+	stats := []power.Statistic{power.EstimatedChargeRemaining}
+
+	if _, err := Collector.Collect(context.Background(), d.Source(t, "ups2"), stats); err != (Error{Code: "UNKNOWN-UPS"}) {
+		t.Errorf("Collect for an unknown UPS returned %v, want UNKNOWN-UPS", err)
+	}
+
+	if _, err := Collector.Collect(context.Background(), d.Source(t, ""), stats); err == nil {
+		t.Errorf("Collect without a UPS name succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Collector.Collect(ctx, d.Source(t, "ups1"), stats); err == nil {
+		t.Errorf("Collect with a cancelled context succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+package nutcollector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/scjalliance/power"
+)
+
+// mapper converts the value of a NUT variable to a statistic value.
+type mapper func(value string) (float64, error)
+
+// mapping describes how a well-known statistic is derived from NUT
+// variables.
+type mapping struct {
+	vars   []string // NUT variable names in order of preference
+	mapper mapper
+}
+
+// mappings holds the NUT variables of each well-known statistic, keyed by
+// statistic name.
+var mappings = map[string]mapping{
+	power.EstimatedMinutesRemaining.Name: {[]string{"battery.runtime"}, scale(1.0 / 60)},
+	power.EstimatedChargeRemaining.Name:  {[]string{"battery.charge"}, scale(1)},
+	power.BatteryVoltage.Name:            {[]string{"battery.voltage"}, scale(1)},
+	power.BatteryTemperature.Name:        {[]string{"battery.temperature"}, scale(1)},
+	power.InputVoltage.Name:              {[]string{"input.voltage"}, scale(1)},
+	power.InputCurrent.Name:              {[]string{"input.current"}, scale(1)},
+	power.OutputVoltage.Name:             {[]string{"output.voltage"}, scale(1)},
+	power.OutputCurrent.Name:             {[]string{"output.current"}, scale(1)},
+	power.OutputPower.Name:               {[]string{"ups.realpower"}, scale(1)},
+	power.OutputPercentLoad.Name:         {[]string{"ups.load"}, scale(1)},
+	power.OutputSource.Name:              {[]string{"ups.status"}, outputSource},
+	power.OnBattery.Name:                 {[]string{"ups.status"}, status("OB")},
+	power.OnBypass.Name:                  {[]string{"ups.status"}, status("BYPASS")},
+	power.Regulating.Name:                {[]string{"ups.status"}, status("BOOST", "TRIM")},
+}
+
+// scale returns a mapper that parses numeric values and multiplies them by
+// factor.
+func scale(factor float64) mapper {
+	return func(value string) (float64, error) {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, err
+		}
+		return n * factor, nil
+	}
+}
+
+// status returns a mapper that produces 1 if a ups.status value includes
+// any of the given flags, and 0 otherwise.
+func status(flags ...string) mapper {
+	return func(value string) (float64, error) {
+		for _, flag := range flags {
+			if hasFlag(value, flag) {
+				return 1, nil
+			}
+		}
+		return 0, nil
+	}
+}
+
+// outputSource maps a ups.status value to an output source enumeration
+// value.
+func outputSource(value string) (float64, error) {
+	switch {
+	case hasFlag(value, "OFF"):
+		return power.OutputSourceNone, nil
+	case hasFlag(value, "OB"):
+		return power.OutputSourceBattery, nil
+	case hasFlag(value, "BYPASS"):
+		return power.OutputSourceBypass, nil
+	case hasFlag(value, "BOOST"):
+		return power.OutputSourceBooster, nil
+	case hasFlag(value, "TRIM"):
+		return power.OutputSourceReducer, nil
+	case hasFlag(value, "OL"):
+		return power.OutputSourceNormal, nil
+	}
+	return power.OutputSourceOther, nil
+}
+
+// hasFlag returns true if the space-separated ups.status value includes
+// flag.
+func hasFlag(value, flag string) bool {
+	for _, f := range strings.Fields(value) {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+// Package powertest provides an in-process SNMP agent that simulates power
+// devices, which allows queries and recipients to be exercised without
+// hardware.
+//
+// The agent answers SNMPv1 and SNMPv2c requests, and SNMPv3 requests that use
+// the noAuthNoPriv security level or, when an authentication password is
+// configured, the authNoPriv level with HMAC-MD5-96 or HMAC-SHA-96
+// authentication. Privacy is not supported, so requests at the authPriv
+// level are ignored.
+//
+//	ups := powertest.NewUPS()
+//	agent, err := powertest.Start(ups, powertest.Config{})
+//	if err != nil {
+//		...
+//	}
+//	defer agent.Close()
+//	values, err := power.Query(ctx, agent.Source(), power.OnBattery)
+//
+// A Modbus TCP server that serves a fixed set of registers is also provided
+// by StartModbus.
+package powertest
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+)
+
+// Agent defaults
+const (
+	DefaultAddress   = "127.0.0.1:0"
+	DefaultCommunity = "public"
+)
+
+// SNMP message versions
+const (
+	versionV1  = 0
+	versionV2c = 1
+	versionV3  = 3
+)
+
+// maxMessageSize is the largest SNMP message that will be accepted.
+const maxMessageSize = 65507
+
+// maxBulkBindings is the largest number of variable bindings returned in
+// response to a single GetBulkRequest.
+const maxBulkBindings = 256
+
+// Config holds the settings of an agent.
+type Config struct {
+	Address   string // UDP listening address, defaults to DefaultAddress
+	Community string // Community accepted for SNMPv1 and SNMPv2c, defaults to DefaultCommunity
+	User      string // User accepted for SNMPv3, empty to disable SNMPv3
+
+	// AuthPassword requires SNMPv3 requests to be authenticated with the
+	// given password, which must be at least 8 characters long. When it
+	// is empty requests must use the noAuthNoPriv security level.
+	AuthPassword string
+	AuthProtocol string // AuthMD5 or AuthSHA, defaults to AuthMD5
+}
+
+// Agent is an SNMP agent that serves the variables of a MIB.
+type Agent struct {
+	conn     net.PacketConn
+	mib      MIB
+	config   Config
+	engineID []byte
+	auth     *authenticator // Nil unless SNMPv3 requests are authenticated
+	started  time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// envelope is the outer structure of SNMPv1 and SNMPv2c messages.
+type envelope struct {
+	Version   int
+	Community []byte
+	Data      asn1.RawValue
+}
+
+// Start returns a running agent that serves mib with the given configuration.
+func Start(mib MIB, config Config) (*Agent, error) {
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+	if config.Community == "" {
+		config.Community = DefaultCommunity
+	}
+
+	conn, err := net.ListenPacket("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %v", config.Address, err)
+	}
+
+	a := &Agent{
+		conn:     conn,
+		mib:      mib,
+		config:   config,
+		engineID: engineID(conn.LocalAddr()),
+		started:  time.Now(),
+		done:     make(chan struct{}),
+	}
+	if config.AuthPassword != "" {
+		if a.auth, err = newAuthenticator(config.AuthProtocol, config.AuthPassword, a.engineID); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	go a.serve()
+
+	return a, nil
+}
+
+// Addr returns the local address of the agent.
+func (a *Agent) Addr() net.Addr {
+	return a.conn.LocalAddr()
+}
+
+// Source returns a power source that refers to the agent. If the agent
+// accepts SNMPv3 the source uses it, with authentication if the agent
+// requires it.
+func (a *Agent) Source() power.Source {
+	host, port, _ := net.SplitHostPort(a.Addr().String())
+	source := power.Source{
+		Name:      "powertest",
+		Host:      host,
+		Port:      port,
+		Community: a.config.Community,
+		Retries:   1,
+		Timeout:   time.Second,
+	}
+	source.Credentials.Username = a.config.User
+	if a.auth != nil {
+		source.Credentials.Password = a.config.AuthPassword
+		source.Credentials.AuthProtocol = a.config.AuthProtocol
+		if source.Credentials.AuthProtocol == "" {
+			source.Credentials.AuthProtocol = AuthMD5
+		}
+	}
+	return source
+}
+
+// Close stops the agent and waits for it to finish.
+func (a *Agent) Close() (err error) {
+	a.closeOnce.Do(func() {
+		err = a.conn.Close()
+		<-a.done
+	})
+	return
+}
+
+// serve answers requests until the agent is closed.
+func (a *Agent) serve() {
+	defer close(a.done)
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		response, err := a.handle(buf[:n])
+		if err != nil || response == nil {
+			continue
+		}
+		a.conn.WriteTo(response, addr)
+	}
+}
+
+// handle returns the response to the given message. It returns a nil response
+// for messages that should be ignored.
+func (a *Agent) handle(b []byte) ([]byte, error) {
+	// The version is the first element of every message
+	var header struct {
+		Version int
+	}
+	if _, err := asn1.Unmarshal(b, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Version {
+	case versionV1, versionV2c:
+		return a.handleCommunity(b)
+	case versionV3:
+		return a.handleV3(b)
+	}
+	return nil, fmt.Errorf("unsupported version %d", header.Version)
+}
+
+// handleCommunity answers an SNMPv1 or SNMPv2c request.
+func (a *Agent) handleCommunity(b []byte) ([]byte, error) {
+	var env envelope
+	if _, err := asn1.Unmarshal(b, &env); err != nil {
+		return nil, err
+	}
+	if string(env.Community) != a.config.Community {
+		return nil, nil
+	}
+
+	version := snmpgo.V2c
+	if env.Version == versionV1 {
+		version = snmpgo.V1
+	}
+
+	data, err := a.respond(version, env.Data)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(envelope{
+		Version:   env.Version,
+		Community: env.Community,
+		Data:      asn1.RawValue{FullBytes: data},
+	})
+}
+
+// respond returns the encoded response to the given request PDU.
+func (a *Agent) respond(version snmpgo.SNMPVersion, raw asn1.RawValue) ([]byte, error) {
+	if raw.Class != asn1.ClassContextSpecific || !raw.IsCompound {
+		return nil, errors.New("malformed protocol data unit")
+	}
+
+	pduType := snmpgo.PduType(raw.Tag)
+	switch pduType {
+	case snmpgo.GetRequest, snmpgo.GetNextRequest:
+	case snmpgo.GetBulkRequest:
+		if version == snmpgo.V1 {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	request := snmpgo.NewPdu(version, pduType)
+	if _, err := request.Unmarshal(raw.FullBytes); err != nil {
+		return nil, err
+	}
+
+	response := snmpgo.NewPdu(version, snmpgo.GetResponse)
+	response.SetRequestId(request.RequestId())
+
+	view := newView(a.mib.Variables())
+	requested := request.VarBinds()
+
+	var (
+		bindings snmpgo.VarBinds
+		failed   int // Index of the first failed binding for SNMPv1
+	)
+	switch pduType {
+	case snmpgo.GetRequest:
+		for i, binding := range requested {
+			v := view.get(binding.Oid)
+			if v == nil {
+				if failed == 0 {
+					failed = i + 1
+				}
+				v = snmpgo.NewNoSucheObject()
+			}
+			bindings = append(bindings, snmpgo.NewVarBind(binding.Oid, v))
+		}
+	case snmpgo.GetNextRequest:
+		for i, binding := range requested {
+			next := view.next(binding.Oid)
+			if next == nil {
+				if failed == 0 {
+					failed = i + 1
+				}
+				next = snmpgo.NewVarBind(binding.Oid, snmpgo.NewEndOfMibView())
+			}
+			bindings = append(bindings, next)
+		}
+	case snmpgo.GetBulkRequest:
+		// The non-repeaters and max-repetitions fields of a GetBulkRequest
+		// share the positions of the error status and error index fields
+		bindings = view.bulk(requested, int(request.ErrorStatus()), request.ErrorIndex())
+	}
+
+	if version == snmpgo.V1 && failed > 0 {
+		// SNMPv1 reports missing variables with an error rather than an
+		// exception value
+		response.SetErrorStatus(snmpgo.NoSuchName)
+		response.SetErrorIndex(failed)
+		bindings = requested
+	}
+
+	for _, binding := range bindings {
+		response.AppendVarBind(binding.Oid, binding.Variable)
+	}
+
+	return response.Marshal()
+}
+
+// engineID returns an SNMPv3 engine identifier for an agent listening on
+// addr, in the text format described in RFC 3411.
+func engineID(addr net.Addr) []byte {
+	// Enterprise 8072 (net-snmp) with the high bit set, followed by the
+	// text format indicator
+	id := []byte{0x80, 0x00, 0x1f, 0x88, 0x04}
+	id = append(id, "powertest"...)
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		id = append(id, strconv.Itoa(udp.Port)...)
+	}
+	return id
+}
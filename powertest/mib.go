@@ -0,0 +1,162 @@
+package powertest
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// MIB provides the variables served by an agent.
+type MIB interface {
+	// Variables returns the current variables of the MIB. It is called
+	// once for each request.
+	Variables() snmpgo.VarBinds
+}
+
+// Static is a MIB with a fixed set of variables. It is safe for concurrent
+// use.
+type Static struct {
+	mu       sync.RWMutex
+	bindings snmpgo.VarBinds
+}
+
+// NewStatic returns a MIB that serves the given variables.
+func NewStatic(bindings snmpgo.VarBinds) *Static {
+	sorted := newView(bindings).bindings
+
+	// When an OID appears more than once the last variable is kept
+	unique := sorted[:0]
+	for _, binding := range sorted {
+		if n := len(unique); n > 0 && unique[n-1].Oid.Equal(binding.Oid) {
+			unique[n-1] = binding
+			continue
+		}
+		unique = append(unique, binding)
+	}
+
+	return &Static{bindings: unique}
+}
+
+// Variables returns the variables of the MIB in ascending order.
+func (m *Static) Variables() snmpgo.VarBinds {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bindings
+}
+
+// Set adds or replaces the variable with the given OID.
+func (m *Static) Set(oid *snmpgo.Oid, v snmpgo.Variable) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := sort.Search(len(m.bindings), func(i int) bool {
+		return m.bindings[i].Oid.Compare(oid) >= 0
+	})
+	j := i
+	if j < len(m.bindings) && m.bindings[j].Oid.Equal(oid) {
+		j++
+	}
+
+	// Copy on write so that the variables returned previously are unchanged
+	bindings := make(snmpgo.VarBinds, 0, len(m.bindings)+1)
+	bindings = append(bindings, m.bindings[:i]...)
+	bindings = append(bindings, snmpgo.NewVarBind(oid, v))
+	bindings = append(bindings, m.bindings[j:]...)
+	m.bindings = bindings
+}
+
+// view is a sorted snapshot of the variables of a MIB.
+type view struct {
+	bindings snmpgo.VarBinds
+}
+
+// newView returns a view of the given variables.
+func newView(bindings snmpgo.VarBinds) view {
+	sorted := make(snmpgo.VarBinds, len(bindings))
+	copy(sorted, bindings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Oid.Compare(sorted[j].Oid) < 0
+	})
+	return view{bindings: sorted}
+}
+
+// get returns the variable with the given OID, or nil if it does not exist.
+func (v view) get(oid *snmpgo.Oid) snmpgo.Variable {
+	i := v.search(oid)
+	if i < len(v.bindings) && v.bindings[i].Oid.Equal(oid) {
+		return v.bindings[i].Variable
+	}
+	return nil
+}
+
+// next returns the first variable that follows oid, or nil if there are none.
+func (v view) next(oid *snmpgo.Oid) *snmpgo.VarBind {
+	i := v.search(oid)
+	if i < len(v.bindings) && v.bindings[i].Oid.Equal(oid) {
+		i++
+	}
+	if i < len(v.bindings) {
+		return v.bindings[i]
+	}
+	return nil
+}
+
+// bulk returns the response to a GetBulkRequest as described in RFC 3416.
+func (v view) bulk(requested snmpgo.VarBinds, nonRepeaters, maxRepetitions int) (bindings snmpgo.VarBinds) {
+	if nonRepeaters < 0 {
+		nonRepeaters = 0
+	}
+	if nonRepeaters > len(requested) {
+		nonRepeaters = len(requested)
+	}
+	if maxRepetitions < 0 {
+		maxRepetitions = 0
+	}
+
+	for _, binding := range requested[:nonRepeaters] {
+		bindings = append(bindings, v.nextOrEnd(binding.Oid))
+	}
+
+	repeaters := make([]*snmpgo.Oid, 0, len(requested)-nonRepeaters)
+	for _, binding := range requested[nonRepeaters:] {
+		repeaters = append(repeaters, binding.Oid)
+	}
+
+	for r := 0; r < maxRepetitions && len(repeaters) > 0; r++ {
+		exhausted := true
+		for i, oid := range repeaters {
+			if len(bindings) >= maxBulkBindings {
+				return
+			}
+			next := v.nextOrEnd(oid)
+			if _, end := next.Variable.(*snmpgo.EndOfMibView); !end {
+				exhausted = false
+			}
+			repeaters[i] = next.Oid
+			bindings = append(bindings, next)
+		}
+		if exhausted {
+			break
+		}
+	}
+
+	return
+}
+
+// nextOrEnd returns the first variable that follows oid, or an endOfMibView
+// exception if there are none.
+func (v view) nextOrEnd(oid *snmpgo.Oid) *snmpgo.VarBind {
+	if next := v.next(oid); next != nil {
+		return next
+	}
+	return snmpgo.NewVarBind(oid, snmpgo.NewEndOfMibView())
+}
+
+// search returns the index of the first variable with an OID that is not
+// less than oid.
+func (v view) search(oid *snmpgo.Oid) int {
+	return sort.Search(len(v.bindings), func(i int) bool {
+		return v.bindings[i].Oid.Compare(oid) >= 0
+	})
+}
@@ -0,0 +1,176 @@
+package powertest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/modbuscollector"
+	"github.com/scjalliance/power/modbusvar"
+)
+
+// Modbus exception codes
+const (
+	modbusIllegalFunction    = 1
+	modbusIllegalDataAddress = 2
+	modbusIllegalDataValue   = 3
+)
+
+// Registers holds the contents of the registers served by a Modbus server,
+// keyed by function code and then by address.
+type Registers map[modbusvar.Function]map[uint16]uint16
+
+// Set stores the given words at consecutive addresses starting at address.
+func (r Registers) Set(function modbusvar.Function, address uint16, words ...uint16) {
+	if r[function] == nil {
+		r[function] = make(map[uint16]uint16)
+	}
+	for i, word := range words {
+		r[function][address+uint16(i)] = word
+	}
+}
+
+// ModbusServer is a Modbus TCP server that serves a fixed set of registers
+// to every unit identifier.
+type ModbusServer struct {
+	listener net.Listener
+
+	mu        sync.RWMutex
+	registers Registers
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// StartModbus returns a running Modbus TCP server that serves the given
+// registers. If address is empty DefaultAddress is used.
+func StartModbus(address string, registers Registers) (*ModbusServer, error) {
+	if address == "" {
+		address = DefaultAddress
+	}
+	if registers == nil {
+		registers = make(Registers)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %v", address, err)
+	}
+
+	s := &ModbusServer{
+		listener:  listener,
+		registers: registers,
+		done:      make(chan struct{}),
+	}
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the local address of the server.
+func (s *ModbusServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Source returns a power source that refers to the server.
+func (s *ModbusServer) Source() power.Source {
+	host, port, _ := net.SplitHostPort(s.Addr().String())
+	return power.Source{
+		Scheme:  modbuscollector.Scheme,
+		Name:    "powertest",
+		Host:    host,
+		Port:    port,
+		Retries: 1,
+		Timeout: time.Second,
+	}
+}
+
+// Set changes the contents of registers while the server is running.
+func (s *ModbusServer) Set(function modbusvar.Function, address uint16, words ...uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registers.Set(function, address, words...)
+}
+
+// Close stops the server and waits for it to stop accepting connections.
+func (s *ModbusServer) Close() (err error) {
+	s.closeOnce.Do(func() {
+		err = s.listener.Close()
+		<-s.done
+	})
+	return
+}
+
+// serve accepts connections until the server is closed.
+func (s *ModbusServer) serve() {
+	defer close(s.done)
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle answers the requests on a single connection.
+func (s *ModbusServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if length < 2 {
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		response := s.respond(pdu)
+		binary.BigEndian.PutUint16(header[4:], uint16(len(response)+1))
+		if _, err := conn.Write(append(header, response...)); err != nil {
+			return
+		}
+	}
+}
+
+// respond returns the response PDU for the given request PDU.
+func (s *ModbusServer) respond(pdu []byte) []byte {
+	function := modbusvar.Function(pdu[0])
+	exception := func(code byte) []byte {
+		return []byte{pdu[0] | 0x80, code}
+	}
+
+	if function != modbusvar.ReadHoldingRegisters && function != modbusvar.ReadInputRegisters {
+		return exception(modbusIllegalFunction)
+	}
+	if len(pdu) != 5 {
+		return exception(modbusIllegalDataValue)
+	}
+	address := binary.BigEndian.Uint16(pdu[1:])
+	count := int(binary.BigEndian.Uint16(pdu[3:]))
+	if count < 1 || count > 125 {
+		return exception(modbusIllegalDataValue)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	response := []byte{pdu[0], byte(2 * count)}
+	for i := 0; i < count; i++ {
+		word, ok := s.registers[function][address+uint16(i)]
+		if !ok {
+			return exception(modbusIllegalDataAddress)
+		}
+		response = append(response, byte(word>>8), byte(word))
+	}
+	return response
+}
@@ -0,0 +1,15 @@
+package powertest
+
+import (
+	"github.com/scjalliance/power/snmprec"
+)
+
+// Replay returns a MIB that serves the variables recorded in the snmprec file
+// at path, such as one written by the record subcommand.
+func Replay(path string) (*Static, error) {
+	bindings, err := snmprec.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewStatic(bindings), nil
+}
@@ -0,0 +1,158 @@
+package powertest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/scjalliance/power"
+)
+
+func TestReplay(t *testing.T) {
+	type expected struct {
+		stat        power.Statistic
+		value       float64
+		unsupported bool // The value should carry a not supported error
+	}
+
+	tests := []struct {
+		file   string
+		vendor *power.Vendor
+		model  string
+		want   []expected
+	}{
+		{
+			file:   "apc-smart-ups.snmprec",
+			vendor: &power.VendorAPC,
+			model:  "Smart-UPS 1500",
+			want: []expected{
+				{stat: power.EstimatedMinutesRemaining, value: 53},
+				{stat: power.EstimatedChargeRemaining, value: 100},
+				{stat: power.BatteryVoltage, value: 27.4},
+				{stat: power.BatteryTemperature, value: 25.1},
+				{stat: power.InputVoltage, value: 120.8},
+				{stat: power.InputCurrent, unsupported: true},
+				{stat: power.OutputSource, value: power.OutputSourceNormal},
+				{stat: power.OnBattery, value: 0},
+				{stat: power.OnBypass, value: 0},
+				{stat: power.Regulating, value: 0},
+				{stat: power.OutputVoltage, value: 120},
+				{stat: power.OutputCurrent, value: 2.1},
+				{stat: power.OutputPower, unsupported: true},
+				{stat: power.OutputPercentLoad, value: 24.3},
+				{stat: power.APCBatteryCapacity, value: 100},
+				{stat: power.APCRuntimeRemaining, value: 53},
+				{stat: power.APCInternalTemperature, value: 25.1},
+				{stat: power.APCReplaceBattery, value: 0},
+				{stat: power.APCInputFrequency, value: 60},
+				{stat: power.APCLineFailCause, value: 9},
+				{stat: power.EatonAmbientTemperature, unsupported: true},
+			},
+		},
+		{
+			file:   "eaton-9px.snmprec",
+			vendor: &power.VendorEaton,
+			model:  "Eaton 9PX 3000",
+			want: []expected{
+				{stat: power.EstimatedMinutesRemaining, value: 45},
+				{stat: power.EstimatedChargeRemaining, value: 100},
+				{stat: power.BatteryVoltage, value: 217.6},
+				{stat: power.BatteryTemperature, unsupported: true},
+				{stat: power.InputVoltage, value: 231},
+				{stat: power.InputCurrent, value: 7},
+				{stat: power.OutputSource, value: power.OutputSourceNormal},
+				{stat: power.OnBattery, value: 0},
+				{stat: power.OnBypass, value: 0},
+				{stat: power.Regulating, value: 0},
+				{stat: power.OutputVoltage, value: 230},
+				{stat: power.OutputCurrent, value: 7.8},
+				{stat: power.OutputPower, value: 1620},
+				{stat: power.OutputPercentLoad, value: 54},
+				{stat: power.EatonBatteryCurrent, value: 0},
+				{stat: power.EatonBatteryStatus, value: 4},
+				{stat: power.EatonInputFrequency, value: 50},
+				{stat: power.EatonOutputFrequency, value: 49.9},
+				{stat: power.EatonAmbientTemperature, value: 24},
+				{stat: power.EatonAmbientHumidity, value: 41},
+				{stat: power.EatonRemoteTemperature, unsupported: true},
+				{stat: power.APCReplaceBattery, unsupported: true},
+			},
+		},
+		{
+			file:   "tripplite-smartonline.snmprec",
+			vendor: &power.VendorTrippLite,
+			model:  "SU1500RTXLCD2U",
+			want: []expected{
+				{stat: power.EstimatedMinutesRemaining, value: 31},
+				{stat: power.EstimatedChargeRemaining, value: 82},
+				{stat: power.BatteryVoltage, value: 51.6},
+				{stat: power.BatteryTemperature, value: 28},
+				{stat: power.InputVoltage, value: 0},
+				{stat: power.InputCurrent, unsupported: true},
+				{stat: power.OutputSource, value: power.OutputSourceBattery},
+				{stat: power.OnBattery, value: 1},
+				{stat: power.OnBypass, value: 0},
+				{stat: power.OutputVoltage, value: 120},
+				{stat: power.OutputCurrent, unsupported: true},
+				{stat: power.OutputPower, value: 610},
+				{stat: power.OutputPercentLoad, value: 41},
+				{stat: power.TrippLiteBatteryStatus, value: 2},
+				{stat: power.TrippLiteSecondsOnBattery, value: 312},
+				{stat: power.TrippLiteBatteryAge, value: 26},
+				{stat: power.TrippLiteAmbientTemperature, value: 23},
+				{stat: power.TrippLiteAmbientHumidity, value: 38},
+				{stat: power.EatonBatteryStatus, unsupported: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			mib, err := Replay(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			agent, err := Start(mib, Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer agent.Close()
+
+			// Each test gets its own identity cache entry
+			source := agent.Source()
+			source.Name = t.Name()
+
+			stats := make([]power.Statistic, len(test.want))
+			for i, w := range test.want {
+				stats[i] = w.stat
+			}
+
+			id, values, err := power.QueryIdentity(context.Background(), source, stats...)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if id.Vendor != test.vendor {
+				t.Errorf("vendor is %v, want %v", id.Vendor, test.vendor)
+			}
+			if id.Model != test.model {
+				t.Errorf("model is \"%s\", want \"%s\"", id.Model, test.model)
+			}
+			if len(values) != len(test.want) {
+				t.Fatalf("query returned %d values, want %d", len(values), len(test.want))
+			}
+			for i, v := range values {
+				want := test.want[i]
+				switch {
+				case want.unsupported:
+					if !power.IsNotSupported(v.Err) {
+						t.Errorf("%s: got %v (%v), want not supported", v.Stat.Name, v.Value, v.Err)
+					}
+				case v.Err != nil:
+					t.Errorf("%s: %v", v.Stat.Name, v.Err)
+				case v.Value != want.value:
+					t.Errorf("%s: got %v, want %v", v.Stat.Name, v.Value, want.value)
+				}
+			}
+		})
+	}
+}
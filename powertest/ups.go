@@ -0,0 +1,232 @@
+package powertest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// UPS defaults
+const (
+	DefaultMainsFailure = 30 * time.Second
+	DefaultRuntime      = 10 * time.Minute
+)
+
+// UPS-MIB battery status values
+const (
+	batteryNormal   = 2
+	batteryLow      = 3
+	batteryDepleted = 4
+)
+
+// UPS-MIB output source values
+const (
+	outputNone    = 2
+	outputNormal  = 3
+	outputBattery = 5
+)
+
+// UPS is a MIB that simulates an uninterruptible power supply implementing
+// the UPS-MIB described in RFC 1628.
+//
+// The UPS runs on mains power until MainsFailure has elapsed since Start. It
+// then runs on battery, with its charge draining linearly until the battery
+// is exhausted after Runtime. If MainsRestore is greater than MainsFailure,
+// mains power returns at that time and the battery recharges at the same
+// rate.
+//
+// The fields of a UPS may be changed while it is being served by an agent.
+type UPS struct {
+	mu sync.Mutex
+
+	Start        time.Time     // Time at which the simulation started
+	MainsFailure time.Duration // Time after Start at which mains power fails, negative to never fail
+	MainsRestore time.Duration // Time after Start at which mains power returns, zero to never return
+	Runtime      time.Duration // Runtime of a fully charged battery
+
+	Manufacturer  string
+	Model         string
+	InputVoltage  int // Volts
+	OutputVoltage int // Volts
+	OutputCurrent int // Tenths of an amp
+	OutputPower   int // Watts
+	Load          int // Percent of capacity
+	Temperature   int // Degrees Celsius
+
+	// Missing holds the OIDs of variables that the UPS does not implement.
+	// Requests for them return noSuchObject.
+	Missing []*snmpgo.Oid
+
+	// Extra holds variables that are served in addition to the UPS-MIB,
+	// such as vendor-specific objects. They replace UPS-MIB variables with
+	// the same OID.
+	Extra snmpgo.VarBinds
+
+	// Clock returns the current time. It defaults to time.Now.
+	Clock func() time.Time
+}
+
+// NewUPS returns a simulated UPS that starts now and loses mains power
+// after DefaultMainsFailure.
+func NewUPS() *UPS {
+	return &UPS{
+		Start:         time.Now(),
+		MainsFailure:  DefaultMainsFailure,
+		Runtime:       DefaultRuntime,
+		Manufacturer:  "powertest",
+		Model:         "Simulated UPS",
+		InputVoltage:  120,
+		OutputVoltage: 120,
+		OutputCurrent: 42,
+		OutputPower:   500,
+		Load:          35,
+		Temperature:   25,
+	}
+}
+
+// State describes the condition of a simulated UPS at a point in time.
+type State struct {
+	Elapsed          time.Duration // Time since the start of the simulation
+	OnBattery        bool
+	SecondsOnBattery int
+	Charge           float64 // Percent of full charge
+}
+
+// State returns the condition of the UPS at the current time.
+func (u *UPS) State() State {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.state()
+}
+
+// Variables returns the UPS-MIB variables for the current state of the UPS.
+func (u *UPS) Variables() snmpgo.VarBinds {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	s := u.state()
+
+	var (
+		batteryStatus = batteryNormal
+		outputSource  = outputNormal
+		inputVoltage  = u.InputVoltage
+		inputFreq     = 600
+		outputVoltage = u.OutputVoltage
+		outputCurrent = u.OutputCurrent
+		outputPower   = u.OutputPower
+		load          = u.Load
+	)
+	switch {
+	case s.Charge <= 0:
+		batteryStatus = batteryDepleted
+	case s.Charge < 25:
+		batteryStatus = batteryLow
+	}
+	if s.OnBattery {
+		inputVoltage, inputFreq = 0, 0
+		outputSource = outputBattery
+		if s.Charge <= 0 {
+			outputSource = outputNone
+			outputVoltage, outputCurrent, outputPower, load = 0, 0, 0, 0
+		}
+	}
+
+	minutes := int(u.Runtime.Minutes() * s.Charge / 100)
+	voltage := 480 + int(60*s.Charge/100) // Tenths of a volt
+
+	bindings := snmpgo.VarBinds{
+		bind("1.3.6.1.2.1.1.1.0", snmpgo.NewOctetString([]byte(u.Manufacturer+" "+u.Model+" (RFC 1628)"))),
+		bind("1.3.6.1.2.1.1.2.0", snmpgo.MustNewOid("1.3.6.1.2.1.33")),
+		bind("1.3.6.1.2.1.1.3.0", snmpgo.NewTimeTicks(uint32(s.Elapsed/(10*time.Millisecond)))),
+		bind("1.3.6.1.2.1.1.5.0", snmpgo.NewOctetString([]byte("powertest"))),
+		bind("1.3.6.1.2.1.33.1.1.1.0", snmpgo.NewOctetString([]byte(u.Manufacturer))),
+		bind("1.3.6.1.2.1.33.1.1.2.0", snmpgo.NewOctetString([]byte(u.Model))),
+		bind("1.3.6.1.2.1.33.1.1.5.0", snmpgo.NewOctetString([]byte("powertest"))),
+		bind("1.3.6.1.2.1.33.1.2.1.0", snmpgo.NewInteger(int32(batteryStatus))),
+		bind("1.3.6.1.2.1.33.1.2.2.0", snmpgo.NewInteger(int32(s.SecondsOnBattery))),
+		bind("1.3.6.1.2.1.33.1.2.3.0", snmpgo.NewInteger(int32(minutes))),
+		bind("1.3.6.1.2.1.33.1.2.4.0", snmpgo.NewInteger(int32(s.Charge))),
+		bind("1.3.6.1.2.1.33.1.2.5.0", snmpgo.NewInteger(int32(voltage))),
+		bind("1.3.6.1.2.1.33.1.2.7.0", snmpgo.NewInteger(int32(u.Temperature))),
+		bind("1.3.6.1.2.1.33.1.3.2.0", snmpgo.NewInteger(1)),
+		bind("1.3.6.1.2.1.33.1.3.3.1.2.1", snmpgo.NewInteger(int32(inputFreq))),
+		bind("1.3.6.1.2.1.33.1.3.3.1.3.1", snmpgo.NewInteger(int32(inputVoltage))),
+		bind("1.3.6.1.2.1.33.1.4.1.0", snmpgo.NewInteger(int32(outputSource))),
+		bind("1.3.6.1.2.1.33.1.4.2.0", snmpgo.NewInteger(600)),
+		bind("1.3.6.1.2.1.33.1.4.3.0", snmpgo.NewInteger(1)),
+		bind("1.3.6.1.2.1.33.1.4.4.1.2.1", snmpgo.NewInteger(int32(outputVoltage))),
+		bind("1.3.6.1.2.1.33.1.4.4.1.3.1", snmpgo.NewInteger(int32(outputCurrent))),
+		bind("1.3.6.1.2.1.33.1.4.4.1.4.1", snmpgo.NewInteger(int32(outputPower))),
+		bind("1.3.6.1.2.1.33.1.4.4.1.5.1", snmpgo.NewInteger(int32(load))),
+	}
+
+	extra := newView(u.Extra)
+	result := make(snmpgo.VarBinds, 0, len(bindings)+len(u.Extra))
+	for _, binding := range bindings {
+		if extra.get(binding.Oid) == nil && !u.missing(binding.Oid) {
+			result = append(result, binding)
+		}
+	}
+	for _, binding := range u.Extra {
+		if !u.missing(binding.Oid) {
+			result = append(result, binding)
+		}
+	}
+
+	return result
+}
+
+// state returns the condition of the UPS at the current time. The caller
+// must hold u.mu.
+func (u *UPS) state() (s State) {
+	now := time.Now
+	if u.Clock != nil {
+		now = u.Clock
+	}
+	s.Elapsed = now().Sub(u.Start)
+	s.Charge = 100
+
+	if u.MainsFailure < 0 || s.Elapsed < u.MainsFailure || u.Runtime <= 0 {
+		return
+	}
+
+	restored := u.MainsRestore > u.MainsFailure && s.Elapsed >= u.MainsRestore
+	onBattery := s.Elapsed - u.MainsFailure
+	if restored {
+		onBattery = u.MainsRestore - u.MainsFailure
+	}
+
+	s.Charge = 100 - 100*float64(onBattery)/float64(u.Runtime)
+	if s.Charge < 0 {
+		s.Charge = 0
+	}
+
+	if restored {
+		// The battery recharges at the rate it drained
+		s.Charge += 100 * float64(s.Elapsed-u.MainsRestore) / float64(u.Runtime)
+		if s.Charge > 100 {
+			s.Charge = 100
+		}
+		return
+	}
+
+	s.OnBattery = true
+	s.SecondsOnBattery = int(onBattery / time.Second)
+	return
+}
+
+// missing returns true if oid is one of the missing variables.
+func (u *UPS) missing(oid *snmpgo.Oid) bool {
+	for _, m := range u.Missing {
+		if m.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// bind returns a variable binding for the given OID, which must be valid.
+func bind(oid string, v snmpgo.Variable) *snmpgo.VarBind {
+	return snmpgo.NewVarBind(snmpgo.MustNewOid(oid), v)
+}
@@ -0,0 +1,84 @@
+package powertest
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// SNMPv3 authentication protocols
+const (
+	AuthMD5 = "MD5"
+	AuthSHA = "SHA"
+)
+
+// authParametersSize is the length of the truncated HMAC carried by
+// authenticated SNMPv3 messages.
+const authParametersSize = 12
+
+// passwordLength is the number of bytes of the repeated password that are
+// hashed to derive a user key.
+const passwordLength = 1048576
+
+// authenticator signs and verifies SNMPv3 messages with the HMAC-MD5-96 or
+// HMAC-SHA-96 authentication protocol described in RFC 3414.
+type authenticator struct {
+	hash func() hash.Hash
+	key  []byte // Key localized to the agent's engine
+}
+
+// newAuthenticator returns an authenticator for the given protocol,
+// password and engine identifier.
+func newAuthenticator(protocol, password string, engineID []byte) (*authenticator, error) {
+	var h func() hash.Hash
+	switch strings.ToUpper(protocol) {
+	case "", AuthMD5:
+		h = md5.New
+	case AuthSHA:
+		h = sha1.New
+	default:
+		return nil, fmt.Errorf("unsupported authentication protocol \"%s\"", protocol)
+	}
+	if len(password) < 8 {
+		return nil, fmt.Errorf("authentication password must be at least 8 characters")
+	}
+	return &authenticator{
+		hash: h,
+		key:  localizeKey(h, passwordToKey(h, password), engineID),
+	}, nil
+}
+
+// digest returns the authentication parameters of msg, which must hold
+// zeroes in place of the parameters.
+func (a *authenticator) digest(msg []byte) []byte {
+	mac := hmac.New(a.hash, a.key)
+	mac.Write(msg)
+	return mac.Sum(nil)[:authParametersSize]
+}
+
+// passwordToKey derives a user key from a password as described in
+// RFC 3414 section A.2.
+func passwordToKey(h func() hash.Hash, password string) []byte {
+	d := h()
+	buf := make([]byte, 64)
+	for i := 0; i < passwordLength; i += len(buf) {
+		for j := range buf {
+			buf[j] = password[(i+j)%len(password)]
+		}
+		d.Write(buf)
+	}
+	return d.Sum(nil)
+}
+
+// localizeKey localizes a user key to an engine as described in RFC 3414
+// section 2.6.
+func localizeKey(h func() hash.Hash, key, engineID []byte) []byte {
+	d := h()
+	d.Write(key)
+	d.Write(engineID)
+	d.Write(key)
+	return d.Sum(nil)
+}
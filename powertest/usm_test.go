@@ -0,0 +1,58 @@
+package powertest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+// TestLocalizeKey checks key derivation against the sample results in
+// RFC 3414 section A.3.
+func TestLocalizeKey(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+
+	tests := []struct {
+		name      string
+		hash      func() hash.Hash
+		key       string
+		localized string
+	}{
+		{"MD5", md5.New, "9faf3283884e92834ebc9847d8edd963", "526f5eed9fcce26f8964c2930787d82b"},
+		{"SHA", sha1.New, "9fb5cc0381497b3793528939ff788d5d79145211", "6695febc9288e36282235fc7151f128497b38f3f"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := passwordToKey(test.hash, "maplesyrup")
+			if got := hex.EncodeToString(key); got != test.key {
+				t.Errorf("passwordToKey returned %s, want %s", got, test.key)
+			}
+			if got := hex.EncodeToString(localizeKey(test.hash, key, engineID)); got != test.localized {
+				t.Errorf("localizeKey returned %s, want %s", got, test.localized)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	tests := []struct {
+		protocol string
+		password string
+		ok       bool
+	}{
+		{"", "maplesyrup", true},
+		{"md5", "maplesyrup", true},
+		{"SHA", "maplesyrup", true},
+		{"SHA256", "maplesyrup", false},
+		{"MD5", "short", false},
+	}
+
+	for _, test := range tests {
+		_, err := newAuthenticator(test.protocol, test.password, []byte("engine"))
+		if (err == nil) != test.ok {
+			t.Errorf("newAuthenticator(%q, %q) returned %v", test.protocol, test.password, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package powertest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/asn1"
+	"errors"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// SNMPv3 message flags and security model
+const (
+	flagAuth       = 0x01
+	flagPriv       = 0x02
+	flagReportable = 0x04
+	securityUSM    = 3
+)
+
+// USM statistics reported when a request can't be processed
+var (
+	usmStatsUnsupportedSecLevels = snmpgo.MustNewOid("1.3.6.1.6.3.15.1.1.1.0")
+	usmStatsUnknownUserNames     = snmpgo.MustNewOid("1.3.6.1.6.3.15.1.1.3.0")
+	usmStatsUnknownEngineIDs     = snmpgo.MustNewOid("1.3.6.1.6.3.15.1.1.4.0")
+	usmStatsWrongDigests         = snmpgo.MustNewOid("1.3.6.1.6.3.15.1.1.5.0")
+)
+
+// v3Message is the outer structure of SNMPv3 messages.
+type v3Message struct {
+	Version            int
+	GlobalData         v3GlobalData
+	SecurityParameters []byte
+	Data               asn1.RawValue
+}
+
+// v3GlobalData is the header of SNMPv3 messages.
+type v3GlobalData struct {
+	MessageID     int
+	MaxSize       int
+	Flags         []byte
+	SecurityModel int
+}
+
+// usmParameters are the security parameters of the user-based security
+// model.
+type usmParameters struct {
+	EngineID       []byte
+	EngineBoots    int
+	EngineTime     int
+	UserName       []byte
+	Authentication []byte
+	Privacy        []byte
+}
+
+// scopedPDU is the plaintext payload of SNMPv3 messages.
+type scopedPDU struct {
+	ContextEngineID []byte
+	ContextName     []byte
+	Data            asn1.RawValue
+}
+
+// handleV3 answers an SNMPv3 request that uses the noAuthNoPriv security
+// level, or the authNoPriv level when the agent has an authentication
+// password. Requests that require privacy are ignored.
+//
+// Requests that don't carry the agent's engine identifier are answered with a
+// report, which allows managers to discover it. Requests with the wrong
+// security level or an incorrect digest are also answered with a report. The
+// timeliness of authenticated requests is not checked.
+func (a *Agent) handleV3(b []byte) ([]byte, error) {
+	if a.config.User == "" {
+		return nil, nil
+	}
+
+	var msg v3Message
+	if _, err := asn1.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+	if msg.GlobalData.SecurityModel != securityUSM || len(msg.GlobalData.Flags) != 1 {
+		return nil, errors.New("unsupported security model")
+	}
+	flags := msg.GlobalData.Flags[0]
+	if flags&flagPriv != 0 {
+		return nil, errors.New("privacy is not supported")
+	}
+	authenticated := flags&flagAuth != 0
+
+	var usm usmParameters
+	if _, err := asn1.Unmarshal(msg.SecurityParameters, &usm); err != nil {
+		return nil, err
+	}
+
+	var scoped scopedPDU
+	if _, err := asn1.Unmarshal(msg.Data.FullBytes, &scoped); err != nil {
+		return nil, err
+	}
+
+	var unknown *snmpgo.Oid
+	switch {
+	case string(usm.EngineID) != string(a.engineID):
+		unknown = usmStatsUnknownEngineIDs
+	case string(usm.UserName) != a.config.User:
+		unknown = usmStatsUnknownUserNames
+	case authenticated != (a.auth != nil):
+		unknown = usmStatsUnsupportedSecLevels
+	case authenticated && !a.verify(b, usm.Authentication):
+		unknown = usmStatsWrongDigests
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if unknown != nil {
+		if flags&flagReportable == 0 {
+			return nil, nil
+		}
+		// Reports are never authenticated
+		authenticated = false
+		data, err = a.report(scoped.Data, unknown)
+	} else {
+		data, err = a.respond(snmpgo.V2c, scoped.Data)
+	}
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return a.v3Response(msg, usm.UserName, scoped.ContextName, data, authenticated)
+}
+
+// verify returns true if params are the correct authentication parameters
+// of the message b.
+func (a *Agent) verify(b, params []byte) bool {
+	if len(params) != authParametersSize {
+		return false
+	}
+	i := bytes.Index(b, append([]byte{0x04, authParametersSize}, params...))
+	if i < 0 {
+		return false
+	}
+	zeroed := append([]byte(nil), b...)
+	copy(zeroed[i+2:i+2+authParametersSize], make([]byte, authParametersSize))
+	return hmac.Equal(a.auth.digest(zeroed), params)
+}
+
+// report returns an encoded report PDU for the given request that carries the
+// USM statistic identified by oid.
+func (a *Agent) report(raw asn1.RawValue, oid *snmpgo.Oid) ([]byte, error) {
+	request := snmpgo.NewPdu(snmpgo.V2c, snmpgo.PduType(raw.Tag))
+	if _, err := request.Unmarshal(raw.FullBytes); err != nil {
+		return nil, err
+	}
+
+	report := snmpgo.NewPdu(snmpgo.V2c, snmpgo.Report)
+	report.SetRequestId(request.RequestId())
+	report.AppendVarBind(oid, snmpgo.NewCounter32(1))
+	return report.Marshal()
+}
+
+// v3Response wraps the encoded PDU in an SNMPv3 response to msg. If
+// authenticated is true the response is signed with the agent's key.
+func (a *Agent) v3Response(msg v3Message, user, context []byte, data []byte, authenticated bool) ([]byte, error) {
+	flags, auth := byte(0), []byte{}
+	if authenticated {
+		flags, auth = flagAuth, make([]byte, authParametersSize)
+	}
+
+	params, err := asn1.Marshal(usmParameters{
+		EngineID:       a.engineID,
+		EngineBoots:    1,
+		EngineTime:     int(time.Since(a.started) / time.Second),
+		UserName:       user,
+		Authentication: auth,
+		Privacy:        []byte{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scoped, err := asn1.Marshal(scopedPDU{
+		ContextEngineID: a.engineID,
+		ContextName:     context,
+		Data:            asn1.RawValue{FullBytes: data},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := asn1.Marshal(v3Message{
+		Version: versionV3,
+		GlobalData: v3GlobalData{
+			MessageID:     msg.GlobalData.MessageID,
+			MaxSize:       maxMessageSize,
+			Flags:         []byte{flags},
+			SecurityModel: securityUSM,
+		},
+		SecurityParameters: params,
+		Data:               asn1.RawValue{FullBytes: scoped},
+	})
+	if err != nil || !authenticated {
+		return response, err
+	}
+
+	// The digest is calculated over the whole message with zeroes in place
+	// of the authentication parameters, which are then replaced
+	i := bytes.Index(response, append([]byte{0x04, authParametersSize}, auth...))
+	if i < 0 {
+		return nil, errors.New("authentication parameters not found")
+	}
+	copy(response[i+2:], a.auth.digest(response))
+	return response, nil
+}
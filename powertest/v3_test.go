@@ -0,0 +1,88 @@
+package powertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scjalliance/power"
+)
+
+func TestQueryV3(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		modify func(*power.Source) // Changes the source that refers to the agent
+		ok     bool
+	}{
+		{name: "noAuthNoPriv", config: Config{User: "monitor"}, ok: true},
+		{name: "authNoPriv MD5", config: Config{User: "monitor", AuthPassword: "maplesyrup"}, ok: true},
+		{name: "authNoPriv SHA", config: Config{User: "monitor", AuthPassword: "maplesyrup", AuthProtocol: AuthSHA}, ok: true},
+		{
+			name:   "unknown user",
+			config: Config{User: "monitor"},
+			modify: func(s *power.Source) { s.Credentials.Username = "intruder" },
+		},
+		{
+			name:   "wrong password",
+			config: Config{User: "monitor", AuthPassword: "maplesyrup"},
+			modify: func(s *power.Source) { s.Credentials.Password = "pancakes!" },
+		},
+		{
+			name:   "wrong protocol",
+			config: Config{User: "monitor", AuthPassword: "maplesyrup", AuthProtocol: AuthSHA},
+			modify: func(s *power.Source) { s.Credentials.AuthProtocol = AuthMD5 },
+		},
+		{
+			name:   "missing authentication",
+			config: Config{User: "monitor", AuthPassword: "maplesyrup"},
+			modify: func(s *power.Source) { s.Credentials.Password = "" },
+		},
+		{
+			name:   "unexpected authentication",
+			config: Config{User: "monitor"},
+			modify: func(s *power.Source) {
+				s.Credentials.Password, s.Credentials.AuthProtocol = "maplesyrup", AuthMD5
+			},
+		},
+		{
+			name:   "privacy",
+			config: Config{User: "monitor", AuthPassword: "maplesyrup"},
+			modify: func(s *power.Source) {
+				s.Credentials.PrivPassword, s.Credentials.PrivProtocol = "maplesyrup", "AES"
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			agent, err := Start(NewUPS(), test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer agent.Close()
+
+			source := agent.Source()
+			source.Retries = 0
+			if test.modify != nil {
+				test.modify(&source)
+			}
+
+			values, err := power.Query(context.Background(), source, power.EstimatedChargeRemaining)
+			if !test.ok {
+				if err == nil && len(values) == 1 && values[0].Err == nil {
+					t.Fatalf("query succeeded with value %v", values[0].Value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if len(values) != 1 || values[0].Err != nil {
+				t.Fatalf("query returned %v", values)
+			}
+			if values[0].Value != 100 {
+				t.Errorf("EstimatedChargeRemaining is %v, want 100", values[0].Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package power
+
+import (
+	"context"
+	"errors"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// Object identifiers retrieved while probing an agent
+var (
+	sysName         = snmpgo.MustNewOid("1.3.6.1.2.1.1.5.0")
+	upsIdentName    = snmpgo.MustNewOid("1.3.6.1.2.1.33.1.1.5.0")
+	apcPDUIdentName = snmpgo.MustNewOid("1.3.6.1.4.1.318.1.1.26.2.1.3.1")
+)
+
+// pduVendors are vendors whose agents are always PDUs.
+var pduVendors = []*Vendor{&VendorRaritan, &VendorServerTech}
+
+// Probe describes an SNMP agent that has been probed for power devices.
+type Probe struct {
+	Identity
+	Name  string // Name assigned to the device by its administrator
+	Class Class  // Class of power device
+	Power bool   // True if the agent is a UPS or PDU
+}
+
+// ProbeSource determines whether the agent behind source is a power device.
+//
+// An agent is considered a UPS if it answers upsIdentModel, and a PDU if it
+// answers a vendor-specific PDU identification object or belongs to a vendor
+// that only makes PDUs. The probe's name is taken from upsIdentName or
+// sysName.
+//
+// Unlike Identify, the results of a probe are not cached.
+func ProbeSource(ctx context.Context, source Source) (probe Probe, err error) {
+	snmp, err := dial(source)
+	if err != nil {
+		return
+	}
+	defer snmp.Close()
+
+	oids := snmpgo.Oids{sysObjectID, sysDescr, sysName, upsIdentModel, upsIdentName, apcPDUIdentName}
+	bindings, err := query(ctx, snmp, oids)
+	if err != nil {
+		return
+	}
+
+	var objectID *snmpgo.Oid
+	if binding := bindings.MatchOid(sysObjectID); binding != nil {
+		if oid, ok := binding.Variable.(*snmpgo.Oid); ok {
+			objectID = oid
+			probe.ObjectID = oid.String()
+		}
+	}
+	probe.Description = octetString(bindings.MatchOid(sysDescr))
+
+	if probe.ObjectID == "" && probe.Description == "" {
+		err = errors.New("no system identification returned")
+		return
+	}
+
+	probe.Vendor = DetectVendor(objectID, probe.Description)
+	probe.Model = octetString(bindings.MatchOid(upsIdentModel))
+
+	switch {
+	case probe.Model != "":
+		probe.Class = ClassUPS
+		probe.Power = true
+	case octetString(bindings.MatchOid(apcPDUIdentName)) != "":
+		probe.Class = ClassPDU
+		probe.Power = true
+	default:
+		for _, vendor := range pduVendors {
+			if probe.Vendor == vendor {
+				probe.Class = ClassPDU
+				probe.Power = true
+			}
+		}
+	}
+
+	for _, oid := range []*snmpgo.Oid{upsIdentName, apcPDUIdentName, sysName} {
+		if name := octetString(bindings.MatchOid(oid)); name != "" {
+			probe.Name = name
+			break
+		}
+	}
+
+	return
+}
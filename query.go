@@ -4,13 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/k-sone/snmpgo"
-	"github.com/scjalliance/power/snmpvar"
 )
 
-// Query will attempt to retrieve the source's statistics via SNMP.
+// Query will attempt to retrieve the source's statistics using the collector
+// registered for the source's scheme. Sources without a scheme are queried
+// via SNMP.
 func Query(ctx context.Context, source Source, stats ...Statistic) (results []Value, err error) {
 	select {
 	case <-ctx.Done():
@@ -18,38 +20,188 @@ func Query(ctx context.Context, source Source, stats ...Statistic) (results []Va
 	default:
 	}
 
-	snmp, err := snmpgo.NewSNMP(snmpgo.SNMPArguments{
-		Version:   snmpgo.V2c,
-		Address:   source.HostPort(),
-		Retries:   source.Retries,
-		Community: source.Community,
-	})
+	c, err := collectorFor(source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create snmpgo.SNMP object: %s", err)
+		return nil, err
 	}
+	return c.Collect(ctx, source, stats)
+}
 
-	if err = snmp.Open(); err != nil {
-		return nil, fmt.Errorf("failed to open connection: %s", err)
+// QueryIdentity behaves like Query, but also returns the identity of the
+// device behind the source. When the source's collector is an
+// IdentifyingCollector the device is identified and queried over the same
+// connection. A zero identity is returned if the device could not be
+// identified.
+func QueryIdentity(ctx context.Context, source Source, stats ...Statistic) (id Identity, results []Value, err error) {
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	c, err := collectorFor(source)
+	if err != nil {
+		return id, nil, err
+	}
+	switch c := c.(type) {
+	case IdentifyingCollector:
+		return c.CollectIdentity(ctx, source, stats)
+	case Identifier:
+		// Identification failures are reported by the query that follows
+		id, _ = c.Identify(ctx, source)
+	}
+	results, err = c.Collect(ctx, source, stats)
+	return
+}
+
+// snmpCollector is the collector for SNMP sources.
+type snmpCollector struct{}
+
+// Identify returns the identity of the device behind the given source.
+func (snmpCollector) Identify(ctx context.Context, source Source) (Identity, error) {
+	snmp, err := dial(source)
+	if err != nil {
+		return Identity{}, err
+	}
+	defer snmp.Close()
+
+	return identify(ctx, snmp, source)
+}
+
+// Collect retrieves the statistics from the given source via SNMP.
+//
+// The source's device is identified before its statistics are retrieved. When
+// identification succeeds, only the OIDs applicable to the detected vendor
+// are queried for each statistic.
+func (c snmpCollector) Collect(ctx context.Context, source Source, stats []Statistic) (results []Value, err error) {
+	_, results, err = c.CollectIdentity(ctx, source, stats)
+	return
+}
+
+// CollectIdentity retrieves the identity of the given source's device and
+// its statistics via a single SNMP connection.
+func (snmpCollector) CollectIdentity(ctx context.Context, source Source, stats []Statistic) (id Identity, results []Value, err error) {
+	snmp, err := dial(source)
+	if err != nil {
+		return id, nil, err
 	}
 	defer snmp.Close()
 
+	// Identification failures are not fatal; all of the OIDs for each
+	// statistic will be tried instead
+	var vendor *Vendor
+	if identity, idErr := identify(ctx, snmp, source); idErr == nil {
+		id, vendor = identity, identity.Vendor
+	}
+
+	// Statistics that share the same set of object identifiers are derived
+	// from a single response
+	fetched := make(map[string]fetchResult)
+
 	for _, stat := range stats {
+		if vendor != nil {
+			stat = vendor.Resolve(stat)
+		}
+
 		value := Value{
 			Source: source,
 			Stat:   stat,
 			Time:   time.Now(),
 		}
-		value.Value, value.Err = query(ctx, snmp, stat.OID, stat.Mapper)
+
+		if len(stat.OID) == 0 {
+			// None of the statistic's OIDs apply to the vendor
+			value.Err = ErrNoSuchObject
+			results = append(results, value)
+			continue
+		}
+
+		if stat.Table {
+			results = append(results, queryTable(ctx, snmp, value)...)
+			continue
+		}
+
+		key := oidKey(stat.OID)
+		result, found := fetched[key]
+		if !found {
+			result.bindings, result.err = query(ctx, snmp, stat.OID)
+			fetched[key] = result
+		}
+
+		if result.err != nil {
+			value.Err = result.err
+		} else {
+			value.Value, value.Err = varToValue(stat, result.bindings)
+		}
 		results = append(results, value)
 	}
 	return
 }
 
-// query sends an SNMP v2c request and parses the value contained in the
+// dial opens an SNMP connection to the given source.
+func dial(source Source) (*snmpgo.SNMP, error) {
+	if !source.IsSNMP() {
+		return nil, fmt.Errorf("source \"%s\" is not an SNMP source", source)
+	}
+
+	args := snmpgo.SNMPArguments{
+		Version:   snmpgo.V2c,
+		Address:   source.HostPort(),
+		Timeout:   source.Timeout,
+		Retries:   source.Retries,
+		Community: source.Community,
+	}
+
+	if creds := source.Credentials; creds.Username != "" {
+		args.Version = snmpgo.V3
+		args.UserName = creds.Username
+		args.SecurityLevel = snmpgo.NoAuthNoPriv
+		if creds.Password != "" {
+			args.SecurityLevel = snmpgo.AuthNoPriv
+			args.AuthPassword = creds.Password
+			args.AuthProtocol = snmpgo.AuthProtocol(strings.ToUpper(creds.AuthProtocol))
+			if creds.PrivPassword != "" {
+				args.SecurityLevel = snmpgo.AuthPriv
+				args.PrivPassword = creds.PrivPassword
+				args.PrivProtocol = snmpgo.PrivProtocol(strings.ToUpper(creds.PrivProtocol))
+			}
+		}
+	}
+
+	snmp, err := snmpgo.NewSNMP(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snmpgo.SNMP object: %s", err)
+	}
+
+	if err = snmp.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open connection: %s", err)
+	}
+
+	return snmp, nil
+}
+
+// fetchResult holds the response to a single SNMP request.
+type fetchResult struct {
+	bindings snmpgo.VarBinds
+	err      error
+}
+
+// oidKey returns a string that uniquely identifies the given set of object
+// identifiers.
+func oidKey(oids snmpgo.Oids) string {
+	parts := make([]string, len(oids))
+	for i, oid := range oids {
+		parts[i] = oid.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// query sends an SNMP v2c request and returns the variables contained in the
 // response.
-func query(ctx context.Context, snmp *snmpgo.SNMP, oids snmpgo.Oids, mapper snmpvar.Float64) (value float64, err error) {
+func query(ctx context.Context, snmp *snmpgo.SNMP, oids snmpgo.Oids) (bindings snmpgo.VarBinds, err error) {
 	select {
 	case <-ctx.Done():
+		err = ctx.Err()
 		return
 	default:
 	}
@@ -66,18 +218,15 @@ func query(ctx context.Context, snmp *snmpgo.SNMP, oids snmpgo.Oids, mapper snmp
 	}
 
 	// Retrieve the variables (one varbind per requested object identifier)
-	bindings := pdu.VarBinds()
-
-	// Map the variables to a single value
-	return varToValue(oids, bindings, mapper)
+	return pdu.VarBinds(), nil
 }
 
 // varToValue scans the returned set of variables in priority order and returns
 // the first one that's valid.
 //
 // If none of the variables are valid it returns the last error.
-func varToValue(oids snmpgo.Oids, bindings snmpgo.VarBinds, mapper snmpvar.Float64) (value float64, err error) {
-	for _, oid := range oids {
+func varToValue(stat Statistic, bindings snmpgo.VarBinds) (value float64, err error) {
+	for i, oid := range stat.OID {
 		binding := bindings.MatchOid(oid)
 		if binding != nil {
 			v := binding.Variable
@@ -87,7 +236,7 @@ func varToValue(oids snmpgo.Oids, bindings snmpgo.VarBinds, mapper snmpvar.Float
 			case "NoSucheObject":
 				err = ErrNoSuchObject
 			default:
-				value, err = mapper(v)
+				value, err = stat.mapper(i)(v)
 				if err == nil {
 					// We found a valid value, return it
 					return
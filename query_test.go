@@ -0,0 +1,407 @@
+package power_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/powertest"
+)
+
+// Vendor object identifiers served by the tests
+const (
+	sysObjectID = "1.3.6.1.2.1.1.2.0"
+
+	apcObjectID          = "1.3.6.1.4.1.318.1.1.1"
+	apcHighPrecCapacity  = "1.3.6.1.4.1.318.1.1.1.2.3.1.0"
+	apcBasicOutputStatus = "1.3.6.1.4.1.318.1.1.1.4.1.1.0"
+	apcOutletCurrent     = "1.3.6.1.4.1.318.1.1.26.9.4.3.1.5"
+	apcOutletName        = "1.3.6.1.4.1.318.1.1.26.9.4.3.1.3"
+
+	eatonObjectID         = "1.3.6.1.4.1.534.1"
+	eatonBatTimeRemaining = "1.3.6.1.4.1.534.1.2.1.0"
+	eatonInputCurrent     = "1.3.6.1.4.1.534.1.3.4.1.3.1"
+	eatonOutputSource     = "1.3.6.1.4.1.534.1.4.5.0"
+
+	trippLiteObjectID         = "1.3.6.1.4.1.850.1"
+	trippLiteRunTimeRemaining = "1.3.6.1.4.1.850.1.1.3.1.3.1.1.1.3.1"
+
+	widgetLoad = "1.3.6.1.4.1.99999.1.1.0"
+
+	upsInputCurrent = "1.3.6.1.2.1.33.1.3.3.1.4.1"
+	upsOutputSource = "1.3.6.1.2.1.33.1.4.1.0"
+	upsRunTime      = "1.3.6.1.2.1.33.1.2.3.0"
+)
+
+// expected describes the value a query should return for a statistic.
+type expected struct {
+	stat        power.Statistic
+	value       float64
+	unsupported bool // The value should carry a not supported error
+}
+
+func bind(oid string, v snmpgo.Variable) *snmpgo.VarBind {
+	return snmpgo.NewVarBind(snmpgo.MustNewOid(oid), v)
+}
+
+func integer(oid string, value int32) *snmpgo.VarBind {
+	return bind(oid, snmpgo.NewInteger(value))
+}
+
+func octets(oid string, value string) *snmpgo.VarBind {
+	return bind(oid, snmpgo.NewOctetString([]byte(value)))
+}
+
+// mustParse returns the statistic described by s, which must be valid.
+func mustParse(s string) power.Statistic {
+	stat, err := power.ParseStatistic(s)
+	if err != nil {
+		panic(err)
+	}
+	return stat
+}
+
+func oids(s ...string) (result []*snmpgo.Oid) {
+	for _, oid := range s {
+		result = append(result, snmpgo.MustNewOid(oid))
+	}
+	return
+}
+
+// serve starts an agent for mib and returns a source that refers to it. The
+// source is named after the test so that cached identities aren't shared
+// between tests.
+func serve(t *testing.T, mib powertest.MIB, config powertest.Config) power.Source {
+	t.Helper()
+	agent, err := powertest.Start(mib, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { agent.Close() })
+
+	source := agent.Source()
+	source.Name = t.Name()
+	return source
+}
+
+// at returns a UPS whose clock is fixed at the given time after its start.
+func at(elapsed time.Duration) *powertest.UPS {
+	ups := powertest.NewUPS()
+	ups.Clock = func() time.Time { return ups.Start.Add(elapsed) }
+	return ups
+}
+
+// check queries the source for the expected statistics and compares the
+// results.
+func check(t *testing.T, source power.Source, want []expected) {
+	t.Helper()
+
+	stats := make([]power.Statistic, len(want))
+	for i, w := range want {
+		stats[i] = w.stat
+	}
+
+	values, err := power.Query(context.Background(), source, stats...)
+	if err != nil {
+		t.Fatalf("Query returned %v", err)
+	}
+	if len(values) != len(want) {
+		t.Fatalf("Query returned %d values, want %d", len(values), len(want))
+	}
+	for i, v := range values {
+		switch {
+		case want[i].unsupported:
+			if !power.IsNotSupported(v.Err) {
+				t.Errorf("%s: got %v (%v), want not supported", v.Stat.Name, v.Value, v.Err)
+			}
+		case v.Err != nil:
+			t.Errorf("%s: %v", v.Stat.Name, v.Err)
+		case v.Value != want[i].value:
+			t.Errorf("%s: got %v, want %v", v.Stat.Name, v.Value, want[i].value)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	want := []expected{
+		{stat: power.EstimatedMinutesRemaining, value: 10},
+		{stat: power.EstimatedChargeRemaining, value: 100},
+		{stat: power.BatteryVoltage, value: 54},
+		{stat: power.BatteryTemperature, value: 25},
+		{stat: power.InputVoltage, value: 120},
+		{stat: power.InputCurrent, unsupported: true},
+		{stat: power.OutputSource, value: power.OutputSourceNormal},
+		{stat: power.OnBattery, value: 0},
+		{stat: power.OnBypass, value: 0},
+		{stat: power.OutputVoltage, value: 120},
+		{stat: power.OutputCurrent, value: 4.2},
+		{stat: power.OutputPower, value: 500},
+		{stat: power.OutputPercentLoad, value: 35},
+	}
+
+	configs := []struct {
+		name   string
+		config powertest.Config
+	}{
+		{name: "v2c"},
+		{name: "v3 noAuthNoPriv", config: powertest.Config{User: "monitor"}},
+		{name: "v3 authNoPriv", config: powertest.Config{User: "monitor", AuthPassword: "maplesyrup"}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			check(t, serve(t, at(0), c.config), want)
+		})
+	}
+}
+
+func TestQueryMainsFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    []expected
+	}{
+		{
+			name:    "mains",
+			elapsed: powertest.DefaultMainsFailure - time.Second,
+			want: []expected{
+				{stat: power.OutputSource, value: power.OutputSourceNormal},
+				{stat: power.OnBattery, value: 0},
+				{stat: power.InputVoltage, value: 120},
+				{stat: power.EstimatedChargeRemaining, value: 100},
+			},
+		},
+		{
+			name:    "battery",
+			elapsed: powertest.DefaultMainsFailure + powertest.DefaultRuntime/2,
+			want: []expected{
+				{stat: power.OutputSource, value: power.OutputSourceBattery},
+				{stat: power.OnBattery, value: 1},
+				{stat: power.InputVoltage, value: 0},
+				{stat: power.EstimatedChargeRemaining, value: 50},
+				{stat: power.EstimatedMinutesRemaining, value: 5},
+			},
+		},
+		{
+			name:    "exhausted",
+			elapsed: powertest.DefaultMainsFailure + powertest.DefaultRuntime,
+			want: []expected{
+				{stat: power.OutputSource, value: power.OutputSourceNone},
+				{stat: power.OnBattery, value: 0},
+				{stat: power.EstimatedChargeRemaining, value: 0},
+				{stat: power.OutputPower, value: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check(t, serve(t, at(test.elapsed), powertest.Config{}), test.want)
+		})
+	}
+}
+
+func TestQueryVendor(t *testing.T) {
+	tests := []struct {
+		name     string
+		objectID string          // sysObjectID reported by the UPS
+		missing  []string        // UPS-MIB variables the UPS doesn't implement
+		extra    snmpgo.VarBinds // Vendor variables
+		want     []expected
+	}{
+		{
+			name:     "standard before proprietary",
+			objectID: eatonObjectID,
+			extra: snmpgo.VarBinds{
+				integer(upsInputCurrent, 80),
+				integer(eatonInputCurrent, 75),
+			},
+			want: []expected{{stat: power.InputCurrent, value: 80}},
+		},
+		{
+			name:     "proprietary mapper",
+			objectID: eatonObjectID,
+			missing:  []string{upsRunTime},
+			extra: snmpgo.VarBinds{
+				integer(eatonInputCurrent, 75),
+				integer(eatonBatTimeRemaining, 900),
+			},
+			want: []expected{
+				{stat: power.InputCurrent, value: 75},
+				{stat: power.EstimatedMinutesRemaining, value: 15},
+			},
+		},
+		{
+			name:    "built-in OIDs of other vendors excluded",
+			missing: []string{upsRunTime},
+			extra: snmpgo.VarBinds{
+				integer(eatonInputCurrent, 75),
+				integer(eatonBatTimeRemaining, 900),
+			},
+			want: []expected{
+				{stat: power.InputCurrent, unsupported: true},
+				{stat: power.EstimatedMinutesRemaining, unsupported: true},
+			},
+		},
+		{
+			name:    "custom OIDs retained",
+			missing: []string{upsRunTime},
+			extra: snmpgo.VarBinds{
+				integer(eatonInputCurrent, 75),
+				integer(eatonBatTimeRemaining, 900),
+			},
+			want: []expected{
+				{stat: mustParse("name:XupsInputCurrent,oid:" + eatonInputCurrent), value: 75},
+				{stat: mustParse("EstimatedMinutesRemaining,oid:" + eatonBatTimeRemaining), value: 900},
+			},
+		},
+		{
+			name:     "custom OIDs of unknown enterprises retained",
+			objectID: apcObjectID,
+			extra:    snmpgo.VarBinds{integer(widgetLoad, 7)},
+			want:     []expected{{stat: mustParse("name:WidgetLoad,oid:" + widgetLoad), value: 7}},
+		},
+		{
+			name:     "unparseable value skipped",
+			objectID: apcObjectID,
+			extra:    snmpgo.VarBinds{octets(apcHighPrecCapacity, "unknown")},
+			want:     []expected{{stat: power.EstimatedChargeRemaining, value: 100}},
+		},
+		{
+			name:     "APC high precision",
+			objectID: apcObjectID,
+			extra:    snmpgo.VarBinds{integer(apcHighPrecCapacity, 874)},
+			want:     []expected{{stat: power.EstimatedChargeRemaining, value: 87.4}},
+		},
+		{
+			name:     "APC on battery",
+			objectID: apcObjectID,
+			missing:  []string{upsOutputSource},
+			extra:    snmpgo.VarBinds{integer(apcBasicOutputStatus, 3)},
+			want: []expected{
+				{stat: power.OutputSource, value: power.OutputSourceBattery},
+				{stat: power.OnBattery, value: 1},
+				{stat: power.Regulating, value: 0},
+			},
+		},
+		{
+			name:     "APC smart trim",
+			objectID: apcObjectID,
+			missing:  []string{upsOutputSource},
+			extra:    snmpgo.VarBinds{integer(apcBasicOutputStatus, 12)},
+			want: []expected{
+				{stat: power.OutputSource, value: power.OutputSourceReducer},
+				{stat: power.OnBattery, value: 0},
+				{stat: power.Regulating, value: 1},
+			},
+		},
+		{
+			name:     "Eaton maintenance bypass",
+			objectID: eatonObjectID,
+			missing:  []string{upsOutputSource},
+			extra:    snmpgo.VarBinds{integer(eatonOutputSource, 11)},
+			want: []expected{
+				{stat: power.OutputSource, value: power.OutputSourceBypass},
+				{stat: power.OnBypass, value: 1},
+				{stat: power.OnBattery, value: 0},
+			},
+		},
+		{
+			name:     "Tripp Lite",
+			objectID: trippLiteObjectID,
+			missing:  []string{upsRunTime},
+			extra:    snmpgo.VarBinds{integer(trippLiteRunTimeRemaining, 42)},
+			want:     []expected{{stat: power.EstimatedMinutesRemaining, value: 42}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ups := at(0)
+			ups.Missing = oids(test.missing...)
+			ups.Extra = test.extra
+			if test.objectID != "" {
+				ups.Extra = append(ups.Extra, bind(sysObjectID, snmpgo.MustNewOid(test.objectID)))
+			}
+			check(t, serve(t, ups, powertest.Config{}), test.want)
+		})
+	}
+}
+
+func TestQueryTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		objectID string
+		extra    snmpgo.VarBinds
+		want     []power.Value // Index, Label, Value and whether Err is set
+	}{
+		{
+			name:     "labeled rows",
+			objectID: apcObjectID,
+			extra: snmpgo.VarBinds{
+				integer(apcOutletCurrent+".1", 12),
+				integer(apcOutletCurrent+".2", 0),
+				octets(apcOutletName+".1", "web"),
+				octets(apcOutletName+".2", "db"),
+			},
+			want: []power.Value{
+				{Index: "1", Label: "web", Value: 1.2},
+				{Index: "2", Label: "db", Value: 0},
+			},
+		},
+		{
+			name:     "unlabeled rows",
+			objectID: apcObjectID,
+			extra: snmpgo.VarBinds{
+				integer(apcOutletCurrent+".3", 7),
+			},
+			want: []power.Value{
+				{Index: "3", Value: 0.7},
+			},
+		},
+		{
+			name:     "empty table",
+			objectID: apcObjectID,
+			want:     []power.Value{{Err: power.ErrNoSuchObject}},
+		},
+		{
+			name:  "other vendors excluded",
+			extra: snmpgo.VarBinds{integer(apcOutletCurrent+".1", 12)},
+			want:  []power.Value{{Err: power.ErrNoSuchObject}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ups := at(0)
+			ups.Extra = test.extra
+			if test.objectID != "" {
+				ups.Extra = append(ups.Extra, bind(sysObjectID, snmpgo.MustNewOid(test.objectID)))
+			}
+			source := serve(t, ups, powertest.Config{})
+
+			values, err := power.Query(context.Background(), source, power.OutletCurrent)
+			if err != nil {
+				t.Fatalf("Query returned %v", err)
+			}
+			if len(values) != len(test.want) {
+				t.Fatalf("Query returned %d values, want %d", len(values), len(test.want))
+			}
+			for i, v := range values {
+				w := test.want[i]
+				switch {
+				case w.Err != nil:
+					if !power.IsNotSupported(v.Err) {
+						t.Errorf("row %d: got %v (%v), want not supported", i, v.Value, v.Err)
+					}
+				case v.Err != nil:
+					t.Errorf("row %d: %v", i, v.Err)
+				case v.Index != w.Index || v.Label != w.Label || v.Value != w.Value:
+					t.Errorf("row %d: got %s %q %v, want %s %q %v", i, v.Index, v.Label, v.Value, w.Index, w.Label, w.Value)
+				}
+			}
+		})
+	}
+}
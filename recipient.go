@@ -17,11 +17,38 @@ type SourceHandler interface {
 	SendSource(i int, s Source)
 }
 
+// IdentityHandler is a recipient that handles the identity of each source's
+// device. It is called after SendSource when the device has been identified.
+type IdentityHandler interface {
+	SendIdentity(i int, s Source, id Identity)
+}
+
 // ErrorHandler is a recipient that handles query errors.
 type ErrorHandler interface {
 	SendQueryError(i int, s Source, err error)
 }
 
+// EventHandler is a recipient that handles source events.
+type EventHandler interface {
+	SendEvent(e Event)
+}
+
+// CycleHandler is a recipient that performs processing after all of the
+// values from a poll of a source have been sent.
+type CycleHandler interface {
+	EndCycle(i int, s Source)
+}
+
+// Flusher is a recipient that buffers values. Flush delivers any values that
+// are pending.
+//
+// Recipients that hold resources which must be released when they are no
+// longer needed implement io.Closer. Close delivers any values that are
+// pending before releasing the resources.
+type Flusher interface {
+	Flush() error
+}
+
 // RecipientParser is capable of parsing a given recipient address.
 type RecipientParser func(address string) (Recipient, error)
 
@@ -0,0 +1,239 @@
+package redfishcollector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+const (
+	testUser     = "monitor"
+	testPassword = "secret"
+	powerPath    = "/redfish/v1/Chassis/1/Power"
+	sensorPath   = "/redfish/v1/Chassis/1/Sensors/Temp"
+)
+
+// testDocuments are the resources served by testService.
+var testDocuments = map[string]string{
+	powerPath: `{
+		"PowerControl": [{"PowerConsumedWatts": 250}],
+		"PowerSupplies": [{"LineInputVoltage": "120.5", "LastPowerOutputWatts": 240}]
+	}`,
+	sensorPath: `{"Reading": 21.5, "Enabled": true}`,
+}
+
+// testService is a Redfish service that accepts basic and session
+// authentication.
+type testService struct {
+	mu       sync.Mutex
+	tokens   map[string]bool
+	sessions int // Number of sessions created
+}
+
+func (s *testService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == sessionPath {
+		s.login(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	doc, ok := testDocuments[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, doc)
+}
+
+// login creates a session.
+func (s *testService) login(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		UserName string
+		Password string
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&credentials) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if credentials.UserName != testUser || credentials.Password != testPassword {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions++
+	token := fmt.Sprintf("token-%d", s.sessions)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	w.Header().Set("X-Auth-Token", token)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// authorized returns true if r carries valid credentials or a valid session
+// token.
+func (s *testService) authorized(r *http.Request) bool {
+	if user, password, ok := r.BasicAuth(); ok {
+		return user == testUser && password == testPassword
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[r.Header.Get("X-Auth-Token")]
+}
+
+// expire invalidates all sessions.
+func (s *testService) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]bool)
+}
+
+// start returns a running TLS test server and a collector that trusts it.
+func start(t *testing.T) (*testService, *httptest.Server, *Collector) {
+	t.Helper()
+	service := &testService{tokens: make(map[string]bool)}
+	server := httptest.NewTLSServer(service)
+	t.Cleanup(server.Close)
+	return service, server, New(Options{Client: server.Client()})
+}
+
+// testSource returns a source that refers to the resource at path on server.
+func testSource(t *testing.T, server *httptest.Server, scheme, path, password string) power.Source {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := power.Source{
+		Scheme:  scheme,
+		Host:    host,
+		Port:    port,
+		Path:    path,
+		Timeout: 5 * time.Second,
+	}
+	source.Credentials.Username = testUser
+	source.Credentials.Password = password
+	return source
+}
+
+func TestCollect(t *testing.T) {
+	_, server, collector := start(t)
+
+	stats := []power.Statistic{
+		power.InputVoltage,
+		power.OutputPower,
+		{Name: "SupplyOutput", Path: "/PowerSupplies/0/LastPowerOutputWatts"},
+		{Name: "Temperature", Path: sensorPath + "#/Reading"},
+		{Name: "SensorEnabled", Path: sensorPath + "#/Enabled"},
+		{Name: "Missing", Path: "/PowerControl/3/PowerConsumedWatts"},
+		power.OnBattery,
+	}
+
+	want := []struct {
+		value       float64
+		unsupported bool
+	}{
+		{value: 120.5},
+		{value: 250},
+		{value: 240},
+		{value: 21.5},
+		{value: 1},
+		{unsupported: true},
+		{unsupported: true},
+	}
+
+	for _, auth := range []string{AuthBasic, AuthSession} {
+		t.Run(auth, func(t *testing.T) {
+			source := testSource(t, server, Scheme, "redfish/v1/Chassis/1/Power?auth="+auth, testPassword)
+			values, err := collector.Collect(context.Background(), source, stats)
+			if err != nil {
+				t.Fatalf("Collect returned %v", err)
+			}
+			if len(values) != len(want) {
+				t.Fatalf("Collect returned %d values, want %d", len(values), len(want))
+			}
+			for i, v := range values {
+				switch {
+				case want[i].unsupported:
+					if !power.IsNotSupported(v.Err) {
+						t.Errorf("%s: got %v (%v), want not supported", v.Stat.Name, v.Value, v.Err)
+					}
+				case v.Err != nil:
+					t.Errorf("%s: %v", v.Stat.Name, v.Err)
+				case v.Value != want[i].value:
+					t.Errorf("%s: got %v, want %v", v.Stat.Name, v.Value, want[i].value)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectUnauthorized(t *testing.T) {
+	_, server, collector := start(t)
+
+	for _, auth := range []string{AuthBasic, AuthSession} {
+		t.Run(auth, func(t *testing.T) {
+			source := testSource(t, server, Scheme, "redfish/v1/Chassis/1/Power?auth="+auth, "wrong")
+			if _, err := collector.Collect(context.Background(), source, []power.Statistic{power.OutputPower}); err == nil {
+				t.Fatal("Collect succeeded with the wrong password")
+			}
+		})
+	}
+}
+
+func TestSessionRelogin(t *testing.T) {
+	service, server, collector := start(t)
+	source := testSource(t, server, Scheme, "redfish/v1/Chassis/1/Power?auth=session", testPassword)
+	stats := []power.Statistic{power.OutputPower}
+
+	for i, expire := range []bool{false, false, true, false} {
+		if expire {
+			service.expire()
+		}
+		values, err := collector.Collect(context.Background(), source, stats)
+		if err != nil {
+			t.Fatalf("poll %d: Collect returned %v", i, err)
+		}
+		if v := values[0]; v.Err != nil || v.Value != 250 {
+			t.Fatalf("poll %d: got %v (%v), want 250", i, v.Value, v.Err)
+		}
+	}
+
+	// A session is created by the first poll and again after it expires
+	if service.sessions != 2 {
+		t.Fatalf("%d sessions created, want 2", service.sessions)
+	}
+}
+
+func TestCollectHTTP(t *testing.T) {
+	server := httptest.NewServer(&testService{tokens: make(map[string]bool)})
+	defer server.Close()
+
+	collector := New(Options{})
+	source := testSource(t, server, SchemeHTTP, "redfish/v1/Chassis/1/Power", testPassword)
+	values, err := collector.Collect(context.Background(), source, []power.Statistic{power.InputVoltage})
+	if err != nil {
+		t.Fatalf("Collect returned %v", err)
+	}
+	if v := values[0]; v.Err != nil || v.Value != 120.5 {
+		t.Fatalf("got %v (%v), want 120.5", v.Value, v.Err)
+	}
+}
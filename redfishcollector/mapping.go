@@ -0,0 +1,13 @@
+package redfishcollector
+
+import "github.com/scjalliance/power"
+
+// pointers holds the JSON pointers from which well-known statistics are read
+// when they don't have a path, keyed by statistic name. The pointers are
+// relative to the source's resource, which is expected to be a Redfish Power
+// resource such as "/redfish/v1/Chassis/1/Power". They are listed in order
+// of preference.
+var pointers = map[string][]string{
+	power.InputVoltage.Name: {"/PowerSupplies/0/LineInputVoltage"},
+	power.OutputPower.Name:  {"/PowerControl/0/PowerConsumedWatts", "/PowerSupplies/0/LastPowerOutputWatts"},
+}
@@ -0,0 +1,73 @@
+package redfishcollector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/scjalliance/power"
+)
+
+// lookup returns the numeric value at the JSON pointer within doc. Booleans
+// are returned as 1 or 0, and strings are parsed as numbers. Pointers that
+// don't refer to a value are reported as not supported.
+func lookup(doc interface{}, pointer string) (float64, error) {
+	v, err := resolve(doc, pointer)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("non-numeric value \"%s\" at %s", v, pointer)
+		}
+		return n, nil
+	case nil:
+		return 0, power.ErrNoSuchInstance
+	}
+	return 0, fmt.Errorf("non-numeric value at %s", pointer)
+}
+
+// resolve returns the value referenced by the JSON pointer within doc, as
+// described by RFC 6901.
+func resolve(doc interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer \"%s\"", pointer)
+	}
+
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	v := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = unescape.Replace(token)
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[token]
+			if !ok {
+				return nil, power.ErrNoSuchObject
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, power.ErrNoSuchObject
+			}
+			v = node[i]
+		default:
+			return nil, power.ErrNoSuchObject
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,253 @@
+// Package schedule runs periodic jobs, each with its own interval.
+//
+// Each job starts at a random phase within its interval so that jobs sharing
+// an interval don't run at the same instant, and the time between runs is
+// shifted by a random jitter. A run that takes longer than its interval is
+// reported as an overrun and the runs that were missed are skipped, so the
+// next run begins at the following interval boundary.
+//
+// Jobs may request a faster interval after each run, which is used to poll a
+// source more frequently while it is running on battery.
+package schedule
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultJitter is the default maximum jitter, as a fraction of the interval.
+const DefaultJitter = 0.1
+
+// Job is a task that runs periodically.
+type Job struct {
+	Key      string        // Uniquely identifies the job across updates
+	Interval time.Duration // Time between runs
+	Fast     time.Duration // Time between runs when requested by the job, zero to disable
+
+	// Run is called for each run of the job. It returns true if the fast
+	// interval should be used until the next run.
+	Run func(ctx context.Context) (fast bool)
+}
+
+// Overrun describes a run that took longer than its interval.
+type Overrun struct {
+	Key      string
+	Interval time.Duration // Interval that was exceeded
+	Duration time.Duration // Time taken by the run
+}
+
+// OverrunHandler is called when a run takes longer than its interval.
+type OverrunHandler func(Overrun)
+
+// Scheduler runs a set of jobs. It is safe for concurrent use.
+type Scheduler struct {
+	jitter  float64
+	overrun OverrunHandler
+
+	// Sources of time and randomness, which are replaced in tests
+	clock       clock
+	randInt63n  func(n int64) int64
+	randFloat64 func() float64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	stopped bool
+}
+
+// entry is a running job.
+type entry struct {
+	mu   sync.Mutex
+	job  Job
+	stop chan struct{}
+	done chan struct{} // Closed when the entry has stopped running
+}
+
+// New returns a scheduler with no jobs.
+//
+// The time between runs of each job is randomly adjusted by up to the given
+// fraction of its interval. If overrun is non-nil it is called whenever a run
+// takes longer than its interval.
+func New(jitter float64, overrun OverrunHandler) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jitter:      jitter,
+		overrun:     overrun,
+		clock:       realClock{},
+		randInt63n:  rand.Int63n,
+		randFloat64: rand.Float64,
+		ctx:         ctx,
+		cancel:      cancel,
+		entries:     make(map[string]*entry),
+	}
+}
+
+// Update replaces the jobs of the scheduler.
+//
+// Jobs with a key that is already scheduled keep their phase unless their
+// intervals have changed. Jobs that are no longer present are stopped after
+// any run in progress completes. When the intervals of a job change, its run
+// in progress completes before the job is rescheduled. Jobs without a
+// positive interval are ignored.
+func (s *Scheduler) Update(jobs []Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	keep := make(map[string]bool)
+	for _, job := range jobs {
+		if job.Interval <= 0 || job.Run == nil {
+			continue
+		}
+		keep[job.Key] = true
+
+		var previous <-chan struct{}
+		if e, ok := s.entries[job.Key]; ok {
+			current := e.get()
+			if current.Interval == job.Interval && current.Fast == job.Fast {
+				e.set(job)
+				continue
+			}
+			close(e.stop)
+			previous = e.done
+		}
+
+		e := &entry{job: job, stop: make(chan struct{}), done: make(chan struct{})}
+		s.entries[job.Key] = e
+		s.wg.Add(1)
+		go s.run(e, previous)
+	}
+
+	for key, e := range s.entries {
+		if !keep[key] {
+			close(e.stop)
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Stop stops all jobs and cancels the context of runs in progress. It blocks
+// until the runs have returned.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		for key, e := range s.entries {
+			close(e.stop)
+			delete(s.entries, key)
+		}
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+}
+
+// run executes e until it is stopped. If previous is non-nil the first run
+// waits for it to be closed, which prevents a rescheduled job from overlapping
+// with its own run in progress.
+func (s *Scheduler) run(e *entry, previous <-chan struct{}) {
+	defer s.wg.Done()
+	defer close(e.done)
+
+	if previous != nil {
+		select {
+		case <-previous:
+		case <-e.stop:
+			return
+		}
+	}
+
+	wait := time.Duration(s.randInt63n(int64(e.get().Interval)))
+	for {
+		timer, stopTimer := s.clock.NewTimer(wait)
+		select {
+		case <-timer:
+		case <-e.stop:
+			stopTimer()
+			return
+		}
+
+		// Both channels may have been ready
+		select {
+		case <-e.stop:
+			return
+		default:
+		}
+
+		job := e.get()
+		start := s.clock.Now()
+		fast := job.Run(s.ctx)
+		elapsed := s.clock.Now().Sub(start)
+
+		interval := job.Interval
+		if fast && job.Fast > 0 {
+			interval = job.Fast
+		}
+
+		if elapsed >= interval {
+			if s.overrun != nil {
+				s.overrun(Overrun{Key: job.Key, Interval: interval, Duration: elapsed})
+			}
+			// Missed runs are skipped rather than made in quick succession
+			wait = interval - elapsed%interval
+			continue
+		}
+
+		wait = s.adjust(interval) - elapsed
+		if wait < 0 {
+			wait = 0
+		}
+	}
+}
+
+// adjust returns interval shifted by a random jitter.
+func (s *Scheduler) adjust(interval time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration((s.randFloat64()*2-1)*s.jitter*float64(interval))
+}
+
+// get returns the job of e.
+func (e *entry) get() Job {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.job
+}
+
+// set replaces the job of e.
+func (e *entry) set(job Job) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.job = job
+}
+
+// clock provides the current time and timers.
+type clock interface {
+	Now() time.Time
+
+	// NewTimer returns a channel that receives the time after d has elapsed,
+	// and a function that stops the timer.
+	NewTimer(d time.Duration) (c <-chan time.Time, stop func() bool)
+}
+
+// realClock is a clock that uses the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
@@ -0,0 +1,419 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only advances when told to.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a timer of a fakeClock.
+type fakeTimer struct {
+	when time.Time
+	c    chan time.Time
+	done bool // Set when the timer has fired or been stopped
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{when: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t.c, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		stopped := !t.done
+		t.done = true
+		return stopped
+	}
+}
+
+// Advance moves the clock forward by d and fires the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if !t.done && !t.when.After(c.now) {
+			t.done = true
+			t.c <- c.now
+		}
+	}
+}
+
+// pending returns the time remaining on each pending timer.
+func (c *fakeClock) pending() (waits []time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.timers {
+		if !t.done {
+			waits = append(waits, t.when.Sub(c.now))
+		}
+	}
+	return
+}
+
+// wait returns the time remaining on the only pending timer once a job has
+// started waiting on it.
+func (c *fakeClock) wait(t *testing.T) time.Duration {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		waits := c.pending()
+		if len(waits) == 1 {
+			return waits[0]
+		}
+		if len(waits) > 1 || time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want 1", len(waits))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// idle waits until no timers are pending.
+func (c *fakeClock) idle(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(c.pending()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want none", len(c.pending()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newTestScheduler returns a scheduler that uses clock, starts each job at a
+// quarter of its interval and uses the given random value for jitter.
+func newTestScheduler(clock *fakeClock, jitter, random float64, overrun OverrunHandler) *Scheduler {
+	s := New(jitter, overrun)
+	s.clock = clock
+	s.randInt63n = func(n int64) int64 { return n / 4 }
+	s.randFloat64 = func() float64 { return random }
+	return s
+}
+
+// receive returns the next value from c.
+func receive(t *testing.T, c <-chan bool) bool {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run")
+		return false
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	const interval = time.Minute
+
+	tests := []struct {
+		jitter float64
+		random float64
+		want   time.Duration
+	}{
+		{jitter: 0.1, random: 0, want: 54 * time.Second},
+		{jitter: 0.1, random: 0.5, want: interval},
+		{jitter: 0.1, random: 1, want: 66 * time.Second},
+		{jitter: 0.5, random: 0.25, want: 45 * time.Second},
+		{jitter: 0, random: 0, want: interval},
+	}
+
+	for _, test := range tests {
+		s := newTestScheduler(newFakeClock(), test.jitter, test.random, nil)
+		if got := s.adjust(interval); got != test.want {
+			t.Errorf("adjust with jitter %v and random value %v returned %s, want %s", test.jitter, test.random, got, test.want)
+		}
+	}
+
+	// The default random source stays within the bounds
+	s := New(DefaultJitter, nil)
+	for i := 0; i < 1000; i++ {
+		if got := s.adjust(interval); got < 54*time.Second || got > 66*time.Second {
+			t.Fatalf("adjust returned %s, outside of the jitter bounds", got)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestScheduler(clock, 0.1, 0, nil)
+	defer s.Stop()
+
+	runs := make(chan bool, 1)
+	s.Update([]Job{{
+		Key:      "ups",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) bool {
+			clock.Advance(4 * time.Second) // Time taken by the run
+			runs <- true
+			return false
+		},
+	}})
+
+	// The first run starts at the job's phase
+	if wait := clock.wait(t); wait != 15*time.Second {
+		t.Fatalf("first run in %s, want 15s", wait)
+	}
+	clock.Advance(15 * time.Second)
+	receive(t, runs)
+
+	// The next run is shifted by the jitter, less the time taken by the run
+	if wait := clock.wait(t); wait != 50*time.Second {
+		t.Fatalf("next run in %s, want 50s", wait)
+	}
+}
+
+func TestFast(t *testing.T) {
+	tests := []struct {
+		name  string
+		fast  time.Duration
+		runs  []bool // Results of successive runs
+		waits []time.Duration
+	}{
+		{
+			name:  "on battery",
+			fast:  10 * time.Second,
+			runs:  []bool{true, true, false},
+			waits: []time.Duration{10 * time.Second, 10 * time.Second, time.Minute},
+		},
+		{
+			name:  "disabled",
+			runs:  []bool{true, false},
+			waits: []time.Duration{time.Minute, time.Minute},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := newFakeClock()
+			s := newTestScheduler(clock, 0, 0, nil)
+			defer s.Stop()
+
+			results := make(chan bool, 1)
+			runs := make(chan bool, 1)
+			s.Update([]Job{{
+				Key:      "ups",
+				Interval: time.Minute,
+				Fast:     test.fast,
+				Run: func(ctx context.Context) bool {
+					runs <- true
+					return <-results
+				},
+			}})
+
+			clock.Advance(clock.wait(t))
+			for i, fast := range test.runs {
+				receive(t, runs)
+				results <- fast
+				wait := clock.wait(t)
+				if wait != test.waits[i] {
+					t.Fatalf("run %d: next run in %s, want %s", i, wait, test.waits[i])
+				}
+				clock.Advance(wait)
+			}
+		})
+	}
+}
+
+func TestOverrun(t *testing.T) {
+	clock := newFakeClock()
+	overruns := make(chan Overrun, 1)
+	s := newTestScheduler(clock, 0, 0, func(o Overrun) { overruns <- o })
+	defer s.Stop()
+
+	durations := make(chan time.Duration, 1)
+	runs := make(chan bool, 1)
+	s.Update([]Job{{
+		Key:      "ups",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) bool {
+			clock.Advance(<-durations)
+			runs <- true
+			return false
+		},
+	}})
+
+	clock.Advance(clock.wait(t))
+	durations <- 150 * time.Second
+	receive(t, runs)
+
+	select {
+	case o := <-overruns:
+		want := Overrun{Key: "ups", Interval: time.Minute, Duration: 150 * time.Second}
+		if o != want {
+			t.Fatalf("overrun %+v, want %+v", o, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("overrun not reported")
+	}
+
+	// The missed runs are skipped, and the next run starts at the following
+	// interval boundary
+	wait := clock.wait(t)
+	if wait != 30*time.Second {
+		t.Fatalf("next run in %s, want 30s", wait)
+	}
+	clock.Advance(wait)
+	durations <- time.Second
+	receive(t, runs)
+
+	if wait := clock.wait(t); wait != 59*time.Second {
+		t.Fatalf("next run in %s, want 59s", wait)
+	}
+	select {
+	case o := <-overruns:
+		t.Fatalf("unexpected overrun %+v", o)
+	default:
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestScheduler(clock, 0, 0, nil)
+	defer s.Stop()
+
+	var (
+		mu      sync.Mutex
+		running int
+		overlap bool
+	)
+	job := func(name string, runs chan<- string, release <-chan struct{}) func(context.Context) bool {
+		return func(ctx context.Context) bool {
+			mu.Lock()
+			running++
+			overlap = overlap || running > 1
+			mu.Unlock()
+
+			runs <- name
+			<-release
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return false
+		}
+	}
+
+	runs := make(chan string, 1)
+	release := make(chan struct{})
+	close(release)
+
+	s.Update([]Job{
+		{Key: "ups", Interval: time.Minute, Run: job("first", runs, release)},
+		{Key: "ignored", Run: job("ignored", runs, release)},
+	})
+	if wait := clock.wait(t); wait != 15*time.Second {
+		t.Fatalf("first run in %s, want 15s", wait)
+	}
+
+	// A job with unchanged intervals keeps its phase and uses the new Run
+	clock.Advance(10 * time.Second)
+	s.Update([]Job{{Key: "ups", Interval: time.Minute, Run: job("second", runs, release)}})
+	if wait := clock.wait(t); wait != 5*time.Second {
+		t.Fatalf("run rescheduled to %s, want 5s", wait)
+	}
+	clock.Advance(5 * time.Second)
+	select {
+	case name := <-runs:
+		if name != "second" {
+			t.Fatalf("%s job ran, want second", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run")
+	}
+
+	// A job with a new interval is rescheduled once its run in progress
+	// completes
+	clock.wait(t)
+	blocked := make(chan struct{})
+	s.Update([]Job{{Key: "ups", Interval: time.Minute, Run: job("blocked", runs, blocked)}})
+	clock.Advance(clock.wait(t))
+	if name := <-runs; name != "blocked" {
+		t.Fatalf("%s job ran, want blocked", name)
+	}
+
+	s.Update([]Job{{Key: "ups", Interval: 2 * time.Minute, Run: job("slower", runs, release)}})
+	if waits := clock.pending(); len(waits) != 0 {
+		t.Fatalf("job rescheduled while its run was in progress: %v", waits)
+	}
+	close(blocked)
+
+	// The previous run may briefly start a timer before it sees that it
+	// has been stopped
+	deadline := time.Now().Add(5 * time.Second)
+	for waits := clock.pending(); len(waits) != 1 || waits[0] != 30*time.Second; waits = clock.pending() {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending runs in %v, want 30s", waits)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(30 * time.Second)
+	if name := <-runs; name != "slower" {
+		t.Fatalf("%s job ran, want slower", name)
+	}
+	if wait := clock.wait(t); wait != 2*time.Minute {
+		t.Fatalf("next run in %s, want 2m", wait)
+	}
+
+	// Removed jobs stop running
+	s.Update(nil)
+	clock.idle(t)
+	clock.Advance(time.Hour)
+	select {
+	case name := <-runs:
+		t.Fatalf("%s job ran after it was removed", name)
+	default:
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if overlap {
+		t.Fatal("runs of a job overlapped")
+	}
+}
+
+func TestStop(t *testing.T) {
+	clock := newFakeClock()
+	s := newTestScheduler(clock, 0, 0, nil)
+
+	runs := make(chan bool, 1)
+	s.Update([]Job{{
+		Key:      "ups",
+		Interval: time.Minute,
+		Run: func(ctx context.Context) bool {
+			runs <- true
+			<-ctx.Done()
+			return false
+		},
+	}})
+	clock.Advance(clock.wait(t))
+	receive(t, runs)
+
+	// Stop cancels the run in progress and waits for it to return
+	s.Stop()
+	clock.idle(t)
+
+	// Jobs can't be added once the scheduler has stopped
+	s.Update([]Job{{Key: "ups", Interval: time.Minute, Run: func(ctx context.Context) bool { return false }}})
+	if waits := clock.pending(); len(waits) != 0 {
+		t.Fatalf("job scheduled after Stop: %v", waits)
+	}
+}
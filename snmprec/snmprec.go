@@ -0,0 +1,230 @@
+// Package snmprec reads and writes SNMP variables in the snmprec format used
+// by snmpsim.
+//
+// Each line of an snmprec file holds a single variable in this form:
+//
+//	oid|tag|value
+//
+// The tag is the BER type of the variable. Octet strings that are not
+// printable are written with the "4x" tag and a hexadecimal value. Lines that
+// are blank or begin with "#" are ignored.
+package snmprec
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// BER type tags
+const (
+	TagInteger     = "2"
+	TagOctetString = "4"
+	TagNull        = "5"
+	TagOid         = "6"
+	TagIpaddress   = "64"
+	TagCounter32   = "65"
+	TagGauge32     = "66"
+	TagTimeTicks   = "67"
+	TagOpaque      = "68"
+	TagCounter64   = "70"
+)
+
+// hexSuffix is appended to a tag to indicate that its value is hexadecimal.
+const hexSuffix = "x"
+
+// Load reads the snmprec file at path.
+func Load(path string) (snmpgo.VarBinds, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads variables in snmprec format from r.
+func Read(r io.Reader) (bindings snmpgo.VarBinds, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		binding, pErr := Parse(text)
+		if pErr != nil {
+			return nil, fmt.Errorf("line %d: %v", line, pErr)
+		}
+		bindings = append(bindings, binding)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Parse parses a single line in snmprec format.
+func Parse(s string) (*snmpgo.VarBind, error) {
+	elements := strings.SplitN(s, "|", 3)
+	if len(elements) != 3 {
+		return nil, fmt.Errorf("malformed variable: \"%s\"", s)
+	}
+
+	oid, err := snmpgo.NewOid(elements[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse oid \"%s\": %v", elements[0], err)
+	}
+
+	v, err := parseValue(elements[1], elements[2])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse value of %s: %v", oid, err)
+	}
+
+	return snmpgo.NewVarBind(oid, v), nil
+}
+
+// Write writes the given variables to w in snmprec format. Variables with
+// types that can't be represented, such as exceptions, are skipped.
+func Write(w io.Writer, bindings snmpgo.VarBinds) error {
+	bw := bufio.NewWriter(w)
+	for _, binding := range bindings {
+		line, ok := Format(binding)
+		if !ok {
+			continue
+		}
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Format returns the snmprec representation of binding. It returns false if
+// the variable's type can't be represented.
+func Format(binding *snmpgo.VarBind) (string, bool) {
+	tag, value, ok := formatValue(binding.Variable)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s|%s", binding.Oid, tag, value), true
+}
+
+// parseValue returns the variable described by the given tag and value.
+func parseValue(tag, value string) (snmpgo.Variable, error) {
+	raw := []byte(value)
+	if strings.HasSuffix(tag, hexSuffix) {
+		tag = strings.TrimSuffix(tag, hexSuffix)
+		var err error
+		if raw, err = hex.DecodeString(value); err != nil {
+			return nil, err
+		}
+		value = string(raw)
+	}
+
+	switch tag {
+	case TagInteger:
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return snmpgo.NewInteger(int32(n)), nil
+	case TagOctetString:
+		return snmpgo.NewOctetString(raw), nil
+	case TagNull:
+		return snmpgo.NewNull(), nil
+	case TagOid:
+		return snmpgo.NewOid(value)
+	case TagIpaddress:
+		ip := net.ParseIP(value).To4()
+		if ip == nil && len(raw) == net.IPv4len {
+			ip = net.IP(raw)
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: \"%s\"", value)
+		}
+		return snmpgo.NewIpaddress(ip[0], ip[1], ip[2], ip[3]), nil
+	case TagCounter32, TagGauge32, TagTimeTicks:
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case TagCounter32:
+			return snmpgo.NewCounter32(uint32(n)), nil
+		case TagGauge32:
+			return snmpgo.NewGauge32(uint32(n)), nil
+		default:
+			return snmpgo.NewTimeTicks(uint32(n)), nil
+		}
+	case TagOpaque:
+		return snmpgo.NewOpaque(raw), nil
+	case TagCounter64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return snmpgo.NewCounter64(n), nil
+	}
+
+	return nil, fmt.Errorf("unsupported tag: \"%s\"", tag)
+}
+
+// formatValue returns the snmprec tag and value of v.
+func formatValue(v snmpgo.Variable) (tag, value string, ok bool) {
+	switch v := v.(type) {
+	case *snmpgo.Integer:
+		return TagInteger, strconv.FormatInt(int64(v.Value), 10), true
+	case *snmpgo.Ipaddress:
+		if len(v.Value) != net.IPv4len {
+			return "", "", false
+		}
+		return TagIpaddress, net.IP(v.Value).String(), true
+	case *snmpgo.Opaque:
+		return TagOpaque + hexSuffix, hex.EncodeToString(v.Value), true
+	case *snmpgo.OctetString:
+		if printable(v.Value) {
+			return TagOctetString, string(v.Value), true
+		}
+		return TagOctetString + hexSuffix, hex.EncodeToString(v.Value), true
+	case *snmpgo.Null:
+		return TagNull, "", true
+	case *snmpgo.Oid:
+		return TagOid, v.String(), true
+	case *snmpgo.Counter32:
+		return TagCounter32, strconv.FormatUint(uint64(v.Value), 10), true
+	case *snmpgo.Gauge32:
+		return TagGauge32, strconv.FormatUint(uint64(v.Value), 10), true
+	case *snmpgo.TimeTicks:
+		return TagTimeTicks, strconv.FormatUint(uint64(v.Value), 10), true
+	case *snmpgo.Counter64:
+		return TagCounter64, strconv.FormatUint(v.Value, 10), true
+	}
+	return "", "", false
+}
+
+// printable returns true if b is text that can be written on a single line
+// without escaping. Text with surrounding whitespace is not considered
+// printable because the whitespace would be lost when read.
+func printable(b []byte) bool {
+	if !utf8.Valid(b) || strings.TrimSpace(string(b)) != string(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package snmprec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/k-sone/snmpgo"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		tag  string
+		line string
+		typ  string // Type of the parsed variable
+	}{
+		{tag: TagInteger, line: "1.3.6.1.2.1.33.1.2.4.0|2|100", typ: "Integer"},
+		{tag: TagInteger, line: "1.3.6.1.2.1.33.1.2.4.0|2|-2147483648", typ: "Integer"},
+		{tag: TagOctetString, line: "1.3.6.1.2.1.1.5.0|4|ups-rack1", typ: "OctetString"},
+		{tag: TagOctetString, line: "1.3.6.1.2.1.1.4.0|4|", typ: "OctetString"},
+		{tag: TagOctetString, line: "1.3.6.1.2.1.1.1.0|4|APC Web/SNMP Management Card|MN:AP9631", typ: "OctetString"},
+		{tag: TagOctetString + hexSuffix, line: "1.3.6.1.2.1.2.2.1.6.2|4x|0010c8a1b2c3", typ: "OctetString"},
+		{tag: TagOctetString + hexSuffix, line: "1.3.6.1.2.1.1.1.0|4x|20457461746f6e20", typ: "OctetString"},
+		{tag: TagNull, line: "1.3.6.1.2.1.1.9.0|5|", typ: "Null"},
+		{tag: TagOid, line: "1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.318.1.3.27", typ: "Oid"},
+		{tag: TagIpaddress, line: "1.3.6.1.2.1.4.20.1.1.10.0.0.21|64|10.0.0.21", typ: "Ipaddress"},
+		{tag: TagCounter32, line: "1.3.6.1.2.1.2.2.1.10.2|65|4294967295", typ: "Counter32"},
+		{tag: TagGauge32, line: "1.3.6.1.4.1.318.1.1.1.2.3.1.0|66|1000", typ: "Gauge32"},
+		{tag: TagTimeTicks, line: "1.3.6.1.2.1.1.3.0|67|2147483647", typ: "TimeTicks"},
+		{tag: TagOpaque + hexSuffix, line: "1.3.6.1.4.1.850.1.1.1.0|68x|9f780441c80000", typ: "Opaque"},
+		{tag: TagCounter64, line: "1.3.6.1.2.1.31.1.1.1.6.1|70|18446744073709551615", typ: "Counter64"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			binding, err := Parse(test.line)
+			if err != nil {
+				t.Fatalf("Parse returned %v", err)
+			}
+			if binding.Variable.Type() != test.typ {
+				t.Errorf("Parse returned a %s, want a %s", binding.Variable.Type(), test.typ)
+			}
+
+			line, ok := Format(binding)
+			if !ok {
+				t.Fatalf("Format failed for %s", binding)
+			}
+			if line != test.line {
+				t.Fatalf("Format returned \"%s\", want \"%s\"", line, test.line)
+			}
+		})
+	}
+}
+
+func TestParseNormalized(t *testing.T) {
+	// Lines that are valid but have a different canonical representation
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "1.3.6.1.2.1.1.5.0|4x|7570732d7261636b31", want: "1.3.6.1.2.1.1.5.0|4|ups-rack1"},
+		{line: "1.3.6.1.2.1.4.20.1.1.10.0.0.21|64x|0a000015", want: "1.3.6.1.2.1.4.20.1.1.10.0.0.21|64|10.0.0.21"},
+		{line: "1.3.6.1.2.1.33.1.2.4.0|2x|313030", want: "1.3.6.1.2.1.33.1.2.4.0|2|100"},
+	}
+
+	for _, test := range tests {
+		binding, err := Parse(test.line)
+		if err != nil {
+			t.Errorf("%s: Parse returned %v", test.line, err)
+			continue
+		}
+		if line, _ := Format(binding); line != test.want {
+			t.Errorf("%s: Format returned \"%s\", want \"%s\"", test.line, line, test.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, line := range []string{
+		"1.3.6.1.2.1.1.5.0",
+		"1.3.6.1.2.1.1.5.0|4",
+		"not.an.oid|2|1",
+		"1.3.6.1.2.1.33.1.2.4.0|2|full",
+		"1.3.6.1.2.1.33.1.2.4.0|2|2147483648",
+		"1.3.6.1.2.1.1.5.0|4x|zz",
+		"1.3.6.1.2.1.4.20.1.1.10.0.0.21|64|fe80::1",
+		"1.3.6.1.2.1.2.2.1.10.2|65|-1",
+		"1.3.6.1.2.1.1.2.0|6|enterprises",
+		"1.3.6.1.2.1.1.5.0|99|unknown",
+	} {
+		if binding, err := Parse(line); err == nil {
+			t.Errorf("%s: Parse returned %s, want an error", line, binding)
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	const input = `# Recorded walk
+
+1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.534.1
+1.3.6.1.2.1.1.5.0|4|ups-core
+`
+	bindings, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read returned %v", err)
+	}
+	if len(bindings) != 2 {
+		t.Fatalf("Read returned %d variables, want 2", len(bindings))
+	}
+
+	// Exceptions can't be represented and are skipped
+	bindings = append(bindings, snmpgo.NewVarBind(snmpgo.MustNewOid("1.3.6.1.2.1.1.6.0"), snmpgo.NewNoSucheObject()))
+
+	var buf bytes.Buffer
+	if err := Write(&buf, bindings); err != nil {
+		t.Fatalf("Write returned %v", err)
+	}
+	want := "1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.534.1\n1.3.6.1.2.1.1.5.0|4|ups-core\n"
+	if buf.String() != want {
+		t.Fatalf("Write returned %q, want %q", buf.String(), want)
+	}
+
+	if _, err := Read(strings.NewReader("1.3.6.1.2.1.1.5.0|4|ok\nbroken\n")); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("Read returned %v, want an error for line 2", err)
+	}
+}
@@ -12,29 +12,28 @@ type Float64 func(snmpgo.Variable) (float64, error)
 
 // Ident is an identify function that returns SNMP values as a float64.
 var Ident = func(v snmpgo.Variable) (float64, error) {
-	if i, ok := v.(*snmpgo.Integer); ok {
-		return float64(i.Value), nil
-	}
-	return 0, fmt.Errorf("unexpected non-integer SNMP variable type %s", v.Type())
+	return Number(v)
 }
 
 // Mul multiplies SNMP values by a multiplier.
 var Mul = func(multiplier float64) Float64 {
 	return func(v snmpgo.Variable) (float64, error) {
-		if i, ok := v.(*snmpgo.Integer); ok {
-			return float64(i.Value) * multiplier, nil
+		value, err := Number(v)
+		if err != nil {
+			return 0, err
 		}
-		return 0, fmt.Errorf("unexpected non-integer SNMP variable type %s", v.Type())
+		return value * multiplier, nil
 	}
 }
 
 // Div divides SNMP values by a divisor.
 var Div = func(divisor float64) Float64 {
 	return func(v snmpgo.Variable) (float64, error) {
-		if i, ok := v.(*snmpgo.Integer); ok {
-			return float64(i.Value) / divisor, nil
+		value, err := Number(v)
+		if err != nil {
+			return 0, err
 		}
-		return 0, fmt.Errorf("unexpected non-integer SNMP variable type %s", v.Type())
+		return value / divisor, nil
 	}
 }
 
@@ -45,13 +44,49 @@ var Match = func(set ...int) Float64 {
 		m[element] = struct{}{}
 	}
 	return func(v snmpgo.Variable) (float64, error) {
-		if i, ok := v.(*snmpgo.Integer); ok {
-			var value float64
-			if _, found := m[int(i.Value)]; found {
-				value = 1
-			}
+		n, err := Number(v)
+		if err != nil {
+			return 0, err
+		}
+		var value float64
+		if _, found := m[int(n)]; found {
+			value = 1
+		}
+		return value, nil
+	}
+}
+
+// Lookup translates SNMP values through the given table. It can be used to
+// map vendor-specific enumerations onto standard ones.
+//
+// Values that are not present in the table are mapped to fallback.
+var Lookup = func(table map[int]float64, fallback float64) Float64 {
+	return func(v snmpgo.Variable) (float64, error) {
+		n, err := Number(v)
+		if err != nil {
+			return 0, err
+		}
+		if value, found := table[int(n)]; found {
 			return value, nil
 		}
-		return 0, fmt.Errorf("unexpected non-integer SNMP variable type %s", v.Type())
+		return fallback, nil
+	}
+}
+
+// Number returns the numeric value of an SNMP variable as a float64. It
+// supports integers, gauges, counters and time ticks.
+func Number(v snmpgo.Variable) (float64, error) {
+	switch n := v.(type) {
+	case *snmpgo.Integer:
+		return float64(n.Value), nil
+	case *snmpgo.Gauge32:
+		return float64(n.Value), nil
+	case *snmpgo.Counter32:
+		return float64(n.Value), nil
+	case *snmpgo.Counter64:
+		return float64(n.Value), nil
+	case *snmpgo.TimeTicks:
+		return float64(n.Value), nil
 	}
+	return 0, fmt.Errorf("unexpected non-numeric SNMP variable type %s", v.Type())
 }
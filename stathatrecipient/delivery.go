@@ -0,0 +1,163 @@
+package stathatrecipient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// stat is a single value in a StatHat JSON batch.
+type stat struct {
+	Name  string  `json:"stat"`
+	Value float64 `json:"value"`
+	Time  int64   `json:"t"`
+}
+
+// batch is the body of a StatHat JSON request.
+type batch struct {
+	EZKey string `json:"ezkey"`
+	Data  []stat `json:"data"`
+}
+
+// response is the body of a StatHat JSON response.
+type response struct {
+	Status int    `json:"status"`
+	Msg    string `json:"msg"`
+}
+
+// deliveryError is an error returned by a StatHat request.
+type deliveryError struct {
+	err       error
+	retryable bool
+}
+
+func (e *deliveryError) Error() string {
+	return e.err.Error()
+}
+
+// deliver posts queued values to StatHat until the queue is closed.
+func (r *Recipient) deliver() {
+	defer close(r.done)
+
+	var reported uint64
+	for {
+		s, ok := <-r.queue
+		if !ok {
+			return
+		}
+
+		if r.ctx.Err() != nil {
+			// Delivery has been abandoned by Close
+			discarded := uint64(1 + len(r.queue))
+			for range r.queue {
+			}
+			atomic.AddUint64(&r.failed, discarded)
+			r.opts.Logger.Printf("discarding %d values: delivery abandoned", discarded)
+			return
+		}
+
+		// Gather whatever else is waiting into the same batch
+		data := []stat{s}
+	gather:
+		for len(data) < r.opts.BatchSize {
+			select {
+			case s, ok := <-r.queue:
+				if !ok {
+					break gather
+				}
+				data = append(data, s)
+			default:
+				break gather
+			}
+		}
+
+		if err := r.postWithRetry(data); err != nil {
+			atomic.AddUint64(&r.failed, uint64(len(data)))
+			r.opts.Logger.Printf("discarding %d values: %v", len(data), err)
+		} else {
+			atomic.AddUint64(&r.sent, uint64(len(data)))
+		}
+
+		if dropped := atomic.LoadUint64(&r.dropped); dropped != reported {
+			r.opts.Logger.Printf("%d values dropped because the queue was full", dropped-reported)
+			reported = dropped
+		}
+	}
+}
+
+// postWithRetry posts data to StatHat, retrying with exponential backoff when
+// a request fails for a reason that may be temporary. It stops retrying when
+// delivery is abandoned.
+func (r *Recipient) postWithRetry(data []stat) (err error) {
+	backoff := r.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err = r.post(data)
+		if err == nil {
+			return nil
+		}
+		if de, ok := err.(*deliveryError); ok && !de.retryable {
+			return err
+		}
+		if attempt >= r.opts.MaxRetries {
+			return fmt.Errorf("giving up after %d attempts: %v", attempt+1, err)
+		}
+
+		r.opts.Logger.Printf("request failed, retrying in %s: %v", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("giving up after %d attempts: %v", attempt+1, r.ctx.Err())
+		}
+
+		backoff *= 2
+		if backoff > r.opts.MaxBackoff {
+			backoff = r.opts.MaxBackoff
+		}
+	}
+}
+
+// post sends data to StatHat in a single request.
+func (r *Recipient) post(data []stat) error {
+	body, err := json.Marshal(batch{EZKey: r.ezkey, Data: data})
+	if err != nil {
+		return &deliveryError{err: err}
+	}
+
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodPost, r.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return &deliveryError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return &deliveryError{err: err, retryable: true}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return &deliveryError{
+			err:       fmt.Errorf("unexpected response status: %s", resp.Status),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		}
+	}
+
+	var result response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		// StatHat accepted the request even if its response is unreadable
+		return nil
+	}
+	if result.Status != 0 && result.Status != http.StatusOK {
+		return &deliveryError{err: fmt.Errorf("request rejected: %s", result.Msg)}
+	}
+
+	return nil
+}
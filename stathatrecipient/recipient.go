@@ -2,64 +2,216 @@ package stathatrecipient
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"strconv"
+	"log"
+	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 
-	"github.com/gentlemanautomaton/stathat"
 	"github.com/scjalliance/power"
 )
 
 // DefaultFormat is the default StatHat statistic naming format.
-const DefaultFormat = "{{.Source.Host}} {{.Stat.Name}}"
+//
+// Values produced by table statistics, such as per-outlet PDU readings, are
+// distinguished by their row label or index.
+const DefaultFormat = "{{.Source.Host}} {{.Stat.Name}}{{with .Instance}} {{.}}{{end}}"
+
+// DefaultEndpoint is the StatHat EZ API endpoint that accepts JSON batches.
+const DefaultEndpoint = "https://api.stathat.com/ez"
+
+// DefaultOptions are the delivery options used by New and
+// NewWithNameTemplate.
+var DefaultOptions = Options{
+	QueueSize:    1000,
+	BatchSize:    100,
+	MaxRetries:   5,
+	MinBackoff:   time.Second,
+	MaxBackoff:   time.Minute,
+	CloseTimeout: 30 * time.Second,
+}
+
+// Options hold the delivery settings of a recipient. Zero values other than
+// MaxRetries are replaced by the corresponding values in DefaultOptions.
+type Options struct {
+	QueueSize    int           // Maximum number of values waiting to be sent
+	BatchSize    int           // Maximum number of values sent in a single request
+	MaxRetries   int           // Number of times a failed request is retried
+	MinBackoff   time.Duration // Delay before the first retry
+	MaxBackoff   time.Duration // Maximum delay between retries
+	CloseTimeout time.Duration // Time Close waits for queued values to be delivered
+	Endpoint     string        // Defaults to DefaultEndpoint
+	Client       *http.Client  // Defaults to http.DefaultClient
+	Logger       *log.Logger   // Defaults to a logger writing to stderr
+}
+
+// Stats hold delivery counters for a recipient.
+type Stats struct {
+	Queued  int    // Values waiting to be sent
+	Sent    uint64 // Values accepted by StatHat
+	Dropped uint64 // Values discarded because the queue was full or closed
+	Failed  uint64 // Values discarded after exhausting their retries
+}
 
 // Recipient is a StatHat recipient of power management values. It contains the
 // ezkey and naming template.
+//
+// Values are delivered asynchronously. Send adds each value to a bounded
+// queue from which values are posted to StatHat in batches. Failed requests
+// are retried with exponential backoff. When the queue is full new values are
+// dropped.
 type Recipient struct {
 	ezkey string
 	t     *template.Template // StatHat stat naming template parsed by text/template
+	opts  Options
+
+	mu     sync.RWMutex
+	queue  chan stat
+	closed bool
+	done   chan struct{}
+
+	ctx    context.Context // Canceled when delivery is abandoned
+	cancel context.CancelFunc
+
+	sent    uint64
+	dropped uint64
+	failed  uint64
 }
 
 // New returns a new StatHat recipient for the given ezkey. The default naming
 // template is used.
 func New(ezkey string) *Recipient {
-	return &Recipient{
-		ezkey: ezkey,
-		t:     template.Must(template.New("stathat").Parse(DefaultFormat)),
-	}
+	return newRecipient(ezkey, template.Must(template.New("stathat").Parse(DefaultFormat)), DefaultOptions)
 }
 
 // NewWithNameTemplate returns a new StatHat recipient for the given ezkey and
 // stat name template.
 func NewWithNameTemplate(ezkey, nameTemplate string) (*Recipient, error) {
+	return NewWithOptions(ezkey, nameTemplate, DefaultOptions)
+}
+
+// NewWithOptions returns a new StatHat recipient for the given ezkey, stat
+// name template and delivery options.
+func NewWithOptions(ezkey, nameTemplate string, opts Options) (*Recipient, error) {
 	t, err := template.New("stathat").Parse(nameTemplate)
 	if err != nil {
 		return nil, err
 	}
-	return &Recipient{
+	return newRecipient(ezkey, t, opts), nil
+}
+
+func newRecipient(ezkey string, t *template.Template, opts Options) *Recipient {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultOptions.QueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultOptions.BatchSize
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultOptions.MinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultOptions.MaxBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.CloseTimeout <= 0 {
+		opts.CloseTimeout = DefaultOptions.CloseTimeout
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "stathat: ", log.LstdFlags)
+	}
+
+	r := &Recipient{
 		ezkey: ezkey,
 		t:     t,
-	}, nil
+		opts:  opts,
+		queue: make(chan stat, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	go r.deliver()
+	return r
 }
 
-// Send will send the report to the configured destination.
+// Send queues the value for delivery to StatHat. It does not block. If the
+// queue is full the value is dropped.
 func (r *Recipient) Send(v power.Value) {
 	if v.Err != nil {
 		// Don't report stats that weren't collected successfully
 		return
 	}
 
-	name := r.StatName(v)
+	s := stat{
+		Name:  r.StatName(v),
+		Value: v.Value,
+		Time:  v.Time.Unix(),
+	}
 
-	fmt.Printf("Sending \"%s\" value %s to StatHat...", name, strconv.FormatFloat(v.Value, 'f', -1, 64))
-	reporter := stathat.New().EZKey(r.ezkey)
-	err := reporter.PostEZ(name, stathat.KindValue, v.Value, &v.Time)
-	if err != nil {
-		// TODO: Try again later?
-		fmt.Printf("failed: %v\n", err)
-	} else {
-		fmt.Printf("done\n")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		atomic.AddUint64(&r.dropped, 1)
+		return
+	}
+
+	select {
+	case r.queue <- s:
+	default:
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+// Close stops accepting values and waits for the queued values to be
+// delivered. If they have not been delivered within the close timeout the
+// request in progress is canceled, no further retries are made and the
+// remaining values are discarded.
+func (r *Recipient) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	timer := time.NewTimer(r.opts.CloseTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.done:
+		r.cancel()
+		return nil
+	case <-timer.C:
+	}
+
+	r.cancel()
+	<-r.done
+	return fmt.Errorf("values were not delivered within %s", r.opts.CloseTimeout)
+}
+
+// Stats returns the delivery counters of the recipient.
+func (r *Recipient) Stats() Stats {
+	return Stats{
+		Queued:  len(r.queue),
+		Sent:    atomic.LoadUint64(&r.sent),
+		Dropped: atomic.LoadUint64(&r.dropped),
+		Failed:  atomic.LoadUint64(&r.failed),
 	}
 }
 
@@ -0,0 +1,353 @@
+package stathatrecipient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// stathat is a fake StatHat endpoint. Each request is answered by the next
+// response in its script, and the last response is repeated once the script
+// runs out.
+type stathat struct {
+	*httptest.Server
+	requests chan batch // Receives each request as it arrives
+
+	mu     sync.Mutex
+	script []func(w http.ResponseWriter)
+}
+
+func startStatHat(t *testing.T, script ...func(w http.ResponseWriter)) *stathat {
+	t.Helper()
+	s := &stathat{
+		requests: make(chan batch, 100),
+		script:   script,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var b batch
+		if err := json.NewDecoder(req.Body).Decode(&b); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		s.requests <- b
+
+		s.mu.Lock()
+		respond := s.script[0]
+		if len(s.script) > 1 {
+			s.script = s.script[1:]
+		}
+		s.mu.Unlock()
+		respond(w)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// ok accepts a request.
+func ok(w http.ResponseWriter) {
+	w.Write([]byte(`{"status":200,"msg":"ok"}`))
+}
+
+// status returns a response with the given HTTP status code.
+func status(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+	}
+}
+
+// blocked returns a response that waits for gate to be closed before
+// accepting the request.
+func blocked(gate <-chan struct{}) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		<-gate
+		ok(w)
+	}
+}
+
+// request returns the next request received by s.
+func (s *stathat) request(t *testing.T) batch {
+	t.Helper()
+	select {
+	case b := <-s.requests:
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received")
+		return batch{}
+	}
+}
+
+// options returns delivery options for s that log to buf.
+func (s *stathat) options(buf *bytes.Buffer) Options {
+	return Options{
+		QueueSize:    10,
+		BatchSize:    3,
+		MaxRetries:   3,
+		MinBackoff:   5 * time.Millisecond,
+		MaxBackoff:   8 * time.Millisecond,
+		CloseTimeout: 5 * time.Second,
+		Endpoint:     s.URL,
+		Client:       s.Client(),
+		Logger:       log.New(buf, "", 0),
+	}
+}
+
+// value returns a value for the named statistic of a source.
+func value(name string, v float64) power.Value {
+	return power.Value{
+		Source: power.Source{Host: "ups1"},
+		Stat:   power.Statistic{Name: name},
+		Value:  v,
+		Time:   time.Unix(1792411200, 0),
+	}
+}
+
+// names returns the statistic names in b.
+func names(b batch) []string {
+	var names []string
+	for _, s := range b.Data {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func newTestRecipient(t *testing.T, opts Options) *Recipient {
+	t.Helper()
+	r, err := NewWithOptions("key", DefaultFormat, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestBatching(t *testing.T) {
+	gate := make(chan struct{})
+	server := startStatHat(t, blocked(gate), ok)
+	var buf bytes.Buffer
+	r := newTestRecipient(t, server.options(&buf))
+
+	// The first request holds up delivery while the other values queue
+	r.Send(value("a", 1))
+	first := server.request(t)
+	for _, name := range []string{"b", "c", "d", "e", "f"} {
+		r.Send(value(name, 2))
+	}
+	failed := value("g", 3)
+	failed.Err = errors.New("timeout")
+	r.Send(failed)
+	if stats := r.Stats(); stats.Queued != 5 {
+		t.Fatalf("Stats returned %+v, want 5 queued", stats)
+	}
+	close(gate)
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if first.EZKey != "key" || len(first.Data) != 1 {
+		t.Fatalf("first request is %+v", first)
+	}
+	want := stat{Name: "ups1 a", Value: 1, Time: 1792411200}
+	if first.Data[0] != want {
+		t.Fatalf("first request holds %+v, want %+v", first.Data[0], want)
+	}
+
+	// The queued values are sent in batches of up to three
+	for _, want := range [][]string{{"ups1 b", "ups1 c", "ups1 d"}, {"ups1 e", "ups1 f"}} {
+		if got := names(server.request(t)); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("request holds %q, want %q", got, want)
+		}
+	}
+	select {
+	case b := <-server.requests:
+		t.Fatalf("unexpected request holding %q", names(b))
+	default:
+	}
+
+	if stats := r.Stats(); stats.Sent != 6 || stats.Failed != 0 || stats.Dropped != 0 {
+		t.Fatalf("Stats returned %+v, want 6 sent", stats)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	server := startStatHat(t, status(http.StatusServiceUnavailable), status(http.StatusTooManyRequests), status(http.StatusBadGateway), ok)
+	var buf bytes.Buffer
+	r := newTestRecipient(t, server.options(&buf))
+
+	r.Send(value("a", 1))
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if got := names(server.request(t)); len(got) != 1 || got[0] != "ups1 a" {
+			t.Fatalf("attempt %d holds %q", i+1, got)
+		}
+	}
+	if stats := r.Stats(); stats.Sent != 1 || stats.Failed != 0 {
+		t.Fatalf("Stats returned %+v, want 1 sent", stats)
+	}
+
+	// The backoff doubles up to the maximum
+	var backoffs []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if i := strings.Index(line, "retrying in "); i >= 0 {
+			backoffs = append(backoffs, strings.SplitN(line[i+len("retrying in "):], ":", 2)[0])
+		}
+	}
+	if want := []string{"5ms", "8ms", "8ms"}; strings.Join(backoffs, ",") != strings.Join(want, ",") {
+		t.Fatalf("retried after %q, want %q", backoffs, want)
+	}
+}
+
+func TestGiveUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(w http.ResponseWriter)
+		attempts int
+		log      string
+	}{
+		{
+			name:     "server error",
+			response: status(http.StatusInternalServerError),
+			attempts: 4,
+			log:      "giving up after 4 attempts",
+		},
+		{
+			name:     "client error",
+			response: status(http.StatusBadRequest),
+			attempts: 1,
+			log:      "unexpected response status: 400",
+		},
+		{
+			name: "rejected",
+			response: func(w http.ResponseWriter) {
+				w.Write([]byte(`{"status":500,"msg":"invalid ezkey"}`))
+			},
+			attempts: 1,
+			log:      "request rejected: invalid ezkey",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := startStatHat(t, test.response)
+			var buf bytes.Buffer
+			r := newTestRecipient(t, server.options(&buf))
+
+			r.Send(value("a", 1))
+			r.Send(value("b", 2))
+			if err := r.Close(); err != nil {
+				t.Fatalf("Close returned %v", err)
+			}
+
+			if attempts := len(server.requests); attempts < test.attempts {
+				t.Fatalf("%d attempts made, want %d", attempts, test.attempts)
+			}
+			if stats := r.Stats(); stats.Sent != 0 || stats.Failed != 2 {
+				t.Fatalf("Stats returned %+v, want 2 failed", stats)
+			}
+			if !strings.Contains(buf.String(), test.log) {
+				t.Fatalf("log does not include %q: %q", test.log, buf.String())
+			}
+		})
+	}
+}
+
+func TestCloseInterruptsRetry(t *testing.T) {
+	server := startStatHat(t, status(http.StatusServiceUnavailable))
+	var buf bytes.Buffer
+	opts := server.options(&buf)
+	opts.MinBackoff = time.Hour
+	opts.MaxBackoff = time.Hour
+	opts.CloseTimeout = 20 * time.Millisecond
+	r := newTestRecipient(t, opts)
+
+	r.Send(value("a", 1))
+	server.request(t)
+	r.Send(value("b", 2))
+
+	// Close gives up on the retry rather than waiting for the backoff
+	start := time.Now()
+	if err := r.Close(); err == nil {
+		t.Fatal("Close succeeded without delivering the values")
+	}
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Fatalf("Close took %s", elapsed)
+	}
+
+	if stats := r.Stats(); stats.Sent != 0 || stats.Failed != 2 || stats.Queued != 0 {
+		t.Fatalf("Stats returned %+v, want 2 failed", stats)
+	}
+	if len(server.requests) != 0 {
+		t.Fatal("request retried after Close")
+	}
+	for _, msg := range []string{"giving up after 1 attempts: context canceled", "discarding 1 values: delivery abandoned"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("log does not include %q: %q", msg, buf.String())
+		}
+	}
+}
+
+func TestDropped(t *testing.T) {
+	gate := make(chan struct{})
+	server := startStatHat(t, blocked(gate), ok)
+	var buf bytes.Buffer
+	opts := server.options(&buf)
+	opts.QueueSize = 1
+	r := newTestRecipient(t, opts)
+
+	r.Send(value("a", 1))
+	server.request(t)
+	r.Send(value("b", 2))
+	r.Send(value("c", 3))
+	if stats := r.Stats(); stats.Queued != 1 || stats.Dropped != 1 {
+		t.Fatalf("Stats returned %+v, want 1 queued and 1 dropped", stats)
+	}
+
+	close(gate)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	r.Send(value("d", 4))
+
+	if stats := r.Stats(); stats.Sent != 2 || stats.Dropped != 2 {
+		t.Fatalf("Stats returned %+v, want 2 sent and 2 dropped", stats)
+	}
+	if !strings.Contains(buf.String(), "1 values dropped because the queue was full") {
+		t.Fatalf("drops were not logged: %q", buf.String())
+	}
+}
+
+func TestParse(t *testing.T) {
+	v := value("OutputPercentLoad", 20)
+
+	r, err := Parse("key")
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+	defer r.(*Recipient).Close()
+	if name := r.(*Recipient).StatName(v); name != "ups1 OutputPercentLoad" {
+		t.Fatalf("StatName returned %q", name)
+	}
+
+	r, err = Parse("key~power.{{.Source.Host}}.{{.Stat.Name}}")
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+	defer r.(*Recipient).Close()
+	if name := r.(*Recipient).StatName(v); name != "power.ups1.OutputPercentLoad" {
+		t.Fatalf("StatName returned %q", name)
+	}
+
+	if _, err := Parse("key~{{.Source"); err == nil {
+		t.Fatal("Parse accepted an invalid template")
+	}
+}
@@ -5,15 +5,89 @@ import (
 	"strings"
 
 	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power/modbusvar"
 	"github.com/scjalliance/power/snmpvar"
 )
 
 // Statistic describes a single power management statistic.
+//
+// When more than one OID is provided they are tried in order of preference.
+// Vendor-specific OIDs often report values with a different scale than their
+// standard counterparts, so each OID may be given its own mapper in Mappers.
+// OIDs without a corresponding mapper use Mapper.
+//
+// Table statistics treat each OID as a table column. The first column that
+// contains rows is walked and a value is produced for each row. Each value
+// carries the row index and, if a label column is provided in LabelOID, the
+// row label.
+//
+// Collectors for protocols other than SNMP retrieve well-known statistics by
+// name. Custom statistics for those collectors are identified by Path, the
+// format of which depends on the collector. Statistics that are read from
+// Modbus devices are described by Register.
 type Statistic struct {
-	Name   string          // Name of statistic
-	Unit   string          // Unit of measurement
-	OID    snmpgo.Oids     // One or more possible OID values for this statistic
-	Mapper snmpvar.Float64 // SNMP value mapper
+	Name     string              // Name of statistic
+	Unit     string              // Unit of measurement
+	Class    Class               // Class of device the statistic applies to
+	OID      snmpgo.Oids         // One or more possible OID values for this statistic
+	Mapper   snmpvar.Float64     // SNMP value mapper
+	Mappers  []snmpvar.Float64   // Optional per-OID value mappers that override Mapper
+	Enum     map[int]string      // Optional labels for enumerated values
+	Table    bool                // Treat OID values as table columns
+	LabelOID snmpgo.Oids         // Optional row label columns for table statistics, parallel to OID
+	Path     string              // Optional collector-specific variable path, such as a NUT variable name
+	Register *modbusvar.Register // Optional Modbus register
+}
+
+// Class identifies the class of device that a statistic applies to.
+type Class int
+
+// Device classes
+const (
+	ClassUPS Class = iota
+	ClassPDU
+	ClassSensor
+)
+
+var classNames = map[Class]string{
+	ClassUPS:    "ups",
+	ClassPDU:    "pdu",
+	ClassSensor: "sensor",
+}
+
+// String returns the name of the class.
+func (c Class) String() string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("class %d", int(c))
+}
+
+// labelOID returns the row label column for the OID at index i, or nil if
+// it has none.
+func (stat Statistic) labelOID(i int) *snmpgo.Oid {
+	if i < len(stat.LabelOID) {
+		return stat.LabelOID[i]
+	}
+	return nil
+}
+
+// mapper returns the value mapper for the OID at index i.
+func (stat Statistic) mapper(i int) snmpvar.Float64 {
+	if i < len(stat.Mappers) && stat.Mappers[i] != nil {
+		return stat.Mappers[i]
+	}
+	return stat.Mapper
+}
+
+// Label returns the label for the given value if the statistic is
+// enumerated. It returns false if no label is defined for the value.
+func (stat Statistic) Label(value float64) (label string, ok bool) {
+	if stat.Enum == nil || value != float64(int(value)) {
+		return "", false
+	}
+	label, ok = stat.Enum[int(value)]
+	return
 }
 
 // ParseStatistic parses a statistic in string format and returns the parsed
@@ -23,6 +97,8 @@ type Statistic struct {
 // with a well-known statistic key followed by a series of property/value pairs:
 //
 //   KEY,name:NAME,oid:OID,unit:UNIT
+//   name:NAME,path:PATH,unit:UNIT
+//   name:NAME,register:UNIT/FUNCTION/ADDRESS/TYPE/ORDER/SCALE,unit:UNIT
 //
 // The format is intended to meet three goals:
 //
@@ -35,6 +111,8 @@ type Statistic struct {
 //   "EstimatedMinutesRemaining"
 //   "name:WidgetDuration,oid:OID"
 //   "EstimatedMinutesRemaining,name:ZomboMinutes,oid:OID,unit:Unit"
+//   "name:BatteryVoltageNominal,path:battery.voltage.nominal,unit:volts"
+//   "name:GeneratorLoad,register:1/4/30/int16/0.1,unit:%"
 func ParseStatistic(s string) (stat Statistic, err error) {
 	if s == "" {
 		err = fmt.Errorf("empty statistic description")
@@ -63,6 +141,17 @@ func ParseStatistic(s string) (stat Statistic, err error) {
 					return
 				}
 				stat.OID = snmpgo.Oids{oid}
+				stat.Mappers = nil
+				stat.LabelOID = nil
+			case "path":
+				stat.Path = value
+			case "register":
+				register, rErr := modbusvar.ParseRegister(value)
+				if rErr != nil {
+					err = fmt.Errorf("unable to parse register \"%s\" in statistic description: %s", s, rErr)
+					return
+				}
+				stat.Register = &register
 			}
 		} else {
 			if lookup, found := statMap[strings.ToLower(element)]; found {
@@ -74,8 +163,8 @@ func ParseStatistic(s string) (stat Statistic, err error) {
 		}
 	}
 
-	if len(stat.OID) == 0 {
-		err = fmt.Errorf("no object ID specified within \"%s\"", s)
+	if len(stat.OID) == 0 && stat.Path == "" && stat.Register == nil {
+		err = fmt.Errorf("no object ID, path or register specified within \"%s\"", s)
 		return
 	}
 
@@ -88,11 +177,21 @@ func ParseStatistic(s string) (stat Statistic, err error) {
 
 // ParseStatistics takes the given set of strings and attempts to parse each one
 // as a power statistic.
+//
+// The name of a device class, such as "ups", "pdu" or "sensor", includes all
+// registered statistics of that class. The special value "all" includes all
+// registered UPS statistics; the table statistics of PDUs and sensor probes
+// are only included when their class is named, so that UPSes aren't asked to
+// walk tables they don't have.
 func ParseStatistics(s []string) (stats []Statistic, err error) {
 	for i, item := range s {
-		switch strings.ToLower(item) {
-		case "all":
-			stats = append(stats, statList...)
+		switch key := strings.ToLower(item); key {
+		case "all", ClassUPS.String():
+			stats = append(stats, classStatistics(ClassUPS)...)
+		case ClassPDU.String():
+			stats = append(stats, classStatistics(ClassPDU)...)
+		case ClassSensor.String():
+			stats = append(stats, classStatistics(ClassSensor)...)
 		default:
 			stat, pErr := ParseStatistic(item)
 			if pErr != nil {
@@ -103,3 +202,14 @@ func ParseStatistics(s []string) (stats []Statistic, err error) {
 	}
 	return
 }
+
+// classStatistics returns the registered statistics of the given class in
+// order of registration.
+func classStatistics(class Class) (stats []Statistic) {
+	for _, stat := range statList {
+		if stat.Class == class {
+			stats = append(stats, stat)
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package power_test
+
+import (
+	"testing"
+
+	"github.com/scjalliance/power"
+)
+
+func TestParseStatistics(t *testing.T) {
+	tests := []struct {
+		set   string
+		class power.Class
+		table bool // The set holds table statistics
+	}{
+		{set: "all", class: power.ClassUPS},
+		{set: "ups", class: power.ClassUPS},
+		{set: "PDU", class: power.ClassPDU, table: true},
+		{set: "sensor", class: power.ClassSensor, table: true},
+	}
+
+	for _, test := range tests {
+		stats, err := power.ParseStatistics([]string{test.set})
+		if err != nil {
+			t.Fatalf("%s: %v", test.set, err)
+		}
+		if len(stats) == 0 {
+			t.Fatalf("%s: no statistics", test.set)
+		}
+		for _, stat := range stats {
+			if stat.Class != test.class || stat.Table != test.table {
+				t.Errorf("%s: includes %s (class %s, table %t)", test.set, stat.Name, stat.Class, stat.Table)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package statusapi
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// dashboardRefresh is the interval at which the dashboard reloads itself.
+const dashboardRefresh = 10 * time.Second
+
+// tile is the summary of a source shown on the dashboard.
+type tile struct {
+	Name      string
+	Identity  string
+	Status    string // "ok", "battery" or "error"
+	OnBattery string
+	Runtime   string
+	Charge    string
+	Load      string
+	Polled    string
+	Error     string
+	Event     string
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>Power Status</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; background: #f4f4f4; color: #222; }
+h1 { font-size: 1.4em; }
+.tiles { display: flex; flex-wrap: wrap; gap: 1em; }
+.tile { background: #fff; border-left: 0.5em solid #4a4; border-radius: 0.3em; padding: 0.8em 1em; min-width: 14em; box-shadow: 0 1px 3px rgba(0,0,0,0.2); }
+.tile.battery { border-color: #e90; }
+.tile.error { border-color: #c33; }
+.tile h2 { font-size: 1.1em; margin: 0 0 0.2em 0; }
+.identity, .polled { color: #777; font-size: 0.85em; }
+.tile dl { display: grid; grid-template-columns: auto auto; gap: 0.2em 1em; margin: 0.6em 0; }
+.tile dt { color: #555; }
+.tile dd { margin: 0; font-weight: bold; text-align: right; }
+.message { font-size: 0.85em; margin: 0.3em 0; }
+.tile.error .message { color: #c33; }
+</style>
+</head>
+<body>
+<h1>Power Status</h1>
+{{if not .Tiles}}<p>No sources have been configured.</p>{{end}}
+<div class="tiles">
+{{range .Tiles}}<div class="tile {{.Status}}">
+<h2>{{.Name}}</h2>
+{{if .Identity}}<div class="identity">{{.Identity}}</div>{{end}}
+<dl>
+<dt>On battery</dt><dd>{{.OnBattery}}</dd>
+<dt>Runtime</dt><dd>{{.Runtime}}</dd>
+<dt>Charge</dt><dd>{{.Charge}}</dd>
+<dt>Load</dt><dd>{{.Load}}</dd>
+</dl>
+{{if .Error}}<div class="message">{{.Error}}</div>{{end}}
+{{if .Event}}<div class="message">{{.Event}}</div>{{end}}
+<div class="polled">{{.Polled}}</div>
+</div>
+{{end}}</div>
+</body>
+</html>
+`))
+
+// serveDashboard renders a status tile for each source.
+func (s *Store) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowed(w, r) {
+		return
+	}
+
+	var tiles []tile
+	for _, status := range s.Sources() {
+		tiles = append(tiles, newTile(status))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	dashboardTemplate.Execute(w, struct {
+		Refresh int
+		Tiles   []tile
+	}{
+		Refresh: int(dashboardRefresh / time.Second),
+		Tiles:   tiles,
+	})
+}
+
+// newTile returns the dashboard tile of the given source status.
+func newTile(status SourceStatus) tile {
+	t := tile{
+		Name:      status.Name,
+		Identity:  status.Identity,
+		Status:    "ok",
+		OnBattery: text(status, power.OnBattery.Name),
+		Runtime:   text(status, power.EstimatedMinutesRemaining.Name),
+		Charge:    text(status, power.EstimatedChargeRemaining.Name),
+		Load:      text(status, power.OutputPercentLoad.Name),
+		Error:     status.Error,
+		Event:     status.Event,
+		Polled:    "Not yet polled",
+	}
+	if status.Polled != nil {
+		t.Polled = "Polled " + status.Polled.Format("2006-01-02 15:04:05")
+	}
+	if v, ok := status.value(power.OnBattery.Name); ok && v.Value != nil && *v.Value != 0 {
+		t.Status = "battery"
+	}
+	if status.Error != "" {
+		t.Status = "error"
+	}
+	return t
+}
+
+// text returns the display text of the named statistic, or a dash if its
+// value is not known.
+func text(status SourceStatus, statistic string) string {
+	v, ok := status.value(statistic)
+	if !ok || v.Value == nil {
+		return "–"
+	}
+	if statistic == power.EstimatedMinutesRemaining.Name {
+		return strconv.FormatFloat(*v.Value, 'f', 0, 64) + " min"
+	}
+	return v.Text
+}
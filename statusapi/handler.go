@@ -0,0 +1,277 @@
+package statusapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/scjalliance/power/historyrecipient"
+)
+
+const (
+	sourcesPath = "/api/sources"
+	historyPath = "/api/history"
+)
+
+// defaultHistoryRange is the span of time covered by history queries that
+// don't specify a start time.
+const defaultHistoryRange = 24 * time.Hour
+
+// streamKeepAlive is the interval at which comments are sent to idle
+// streams, which keeps proxies from closing them.
+const streamKeepAlive = 30 * time.Second
+
+// Handler returns an HTTP handler that serves the contents of the store.
+func (s *Store) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveDashboard)
+	mux.HandleFunc(sourcesPath, s.serveSources)
+	mux.HandleFunc(sourcesPath+"/", s.serveSource)
+	mux.HandleFunc("/api/stream", s.serveStream)
+	mux.HandleFunc(historyPath+"/", s.serveHistory)
+	mux.HandleFunc("/healthz", serveHealth)
+	mux.HandleFunc("/readyz", s.serveReady)
+	return mux
+}
+
+// serveSources writes the status of all sources.
+func (s *Store) serveSources(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Sources())
+}
+
+// serveSource writes the status of the source named by the request path.
+func (s *Store) serveSource(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), sourcesPath+"/"))
+	if err != nil || name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+
+	status, ok := s.Source(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// serveStream streams changes to the store as server-sent events until the
+// client disconnects or the store is closed.
+//
+// The "source" and "statistic" query parameters restrict the stream to the
+// given sources and statistics. Each may be repeated or hold a
+// comma-separated list. The stream begins with a "snapshot" event holding
+// the status of the matching sources, which is followed by an event for each
+// message. The name of each event is its message type.
+func (s *Store) serveStream(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	sub := s.Subscribe(Filter{
+		Sources:    list(query["source"]),
+		Statistics: list(query["statistic"]),
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	snapshot := sub.Snapshot
+	if snapshot == nil {
+		snapshot = []SourceStatus{}
+	}
+	if writeEvent(w, "snapshot", snapshot) != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case m, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if writeEvent(w, m.Type, m) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes v as the JSON data of a server-sent event with the given
+// name.
+func writeEvent(w http.ResponseWriter, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+// list splits comma-separated query values into a single list.
+func list(values []string) (elements []string) {
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				elements = append(elements, element)
+			}
+		}
+	}
+	return
+}
+
+// serveHistory writes the stored values of the statistic named by the
+// request path, which has the form "/api/history/{name}/{statistic}".
+//
+// The "from" and "to" query parameters are RFC 3339 times that bound the
+// range, which defaults to the last 24 hours. The "resolution" parameter is
+// one of "auto", "raw", "5m" or "1h", and the "instance" parameter selects a
+// row of a table statistic.
+func (s *Store) serveHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+
+	history := s.History()
+	if history == nil {
+		writeError(w, http.StatusNotFound, "history is not enabled")
+		return
+	}
+
+	elements := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), historyPath+"/"), "/")
+	if len(elements) != 2 {
+		writeError(w, http.StatusNotFound, "expected a source and statistic")
+		return
+	}
+	var q historyrecipient.Query
+	for i, field := range []*string{&q.Source, &q.Statistic} {
+		value, err := url.PathUnescape(elements[i])
+		if err != nil || value == "" {
+			writeError(w, http.StatusNotFound, "expected a source and statistic")
+			return
+		}
+		*field = value
+	}
+
+	query := r.URL.Query()
+	q.Instance = query.Get("instance")
+	q.To = time.Now()
+	if to := query.Get("to"); to != "" {
+		var err error
+		if q.To, err = time.Parse(time.RFC3339, to); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid end time: %v", err))
+			return
+		}
+	}
+	q.From = q.To.Add(-defaultHistoryRange)
+	if from := query.Get("from"); from != "" {
+		var err error
+		if q.From, err = time.Parse(time.RFC3339, from); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid start time: %v", err))
+			return
+		}
+	}
+	q.Resolution = historyrecipient.Auto
+	if resolution := query.Get("resolution"); resolution != "" {
+		var err error
+		if q.Resolution, err = historyrecipient.ParseResolution(resolution); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	points, resolution, err := history.Range(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if points == nil {
+		points = []historyrecipient.Point{}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Source     string                   `json:"source"`
+		Statistic  string                   `json:"statistic"`
+		Instance   string                   `json:"instance,omitempty"`
+		From       time.Time                `json:"from"`
+		To         time.Time                `json:"to"`
+		Resolution string                   `json:"resolution"`
+		Points     []historyrecipient.Point `json:"points"`
+	}{q.Source, q.Statistic, q.Instance, q.From, q.To, resolution.String(), points})
+}
+
+// serveHealth reports that the process is able to answer requests.
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// serveReady reports whether every source has been polled.
+func (s *Store) serveReady(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	if !s.Ready() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "waiting for sources to be polled"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// allowed returns true if the request uses a read-only method. Otherwise it
+// responds with an error and returns false.
+func allowed(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,181 @@
+package statusapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// Sources reported by test stores
+var (
+	rack  = power.Source{Host: "10.0.0.1", Name: "rack"}
+	other = power.Source{Host: "10.0.0.2", Name: "other"}
+)
+
+// at is the time at which test values are recorded.
+var at = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// newTestStore returns a store that expects rack and other, in that order.
+func newTestStore() *Store {
+	s := New()
+	s.SetSources([]power.Source{rack, other})
+	return s
+}
+
+// get performs a GET request for path against the store's handler.
+func get(s *Store, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+// decode unmarshals the JSON body of w into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("response has content type %q", ct)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("unable to decode %q: %v", w.Body.String(), err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	s := newTestStore()
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 42})
+	s.Send(power.Value{Source: rack, Stat: power.OnBattery, Time: at, Value: 1})
+	s.SendIdentity(0, rack, power.Identity{Vendor: &power.VendorAPC, Model: "SMT1500"})
+	s.SendSource(1, other)
+	s.SendQueryError(1, other, errors.New("timeout"))
+	s.EndCycle(1, other)
+
+	w := get(s, "/api/sources")
+	if w.Code != http.StatusOK {
+		t.Fatalf("/api/sources returned %d", w.Code)
+	}
+	var statuses []SourceStatus
+	decode(t, w, &statuses)
+	if len(statuses) != 2 || statuses[0].Name != "rack" || statuses[1].Name != "other" {
+		t.Fatalf("/api/sources returned %+v, want rack then other", statuses)
+	}
+
+	status := statuses[0]
+	if status.Identity != power.VendorAPC.Name+" SMT1500" || status.Polled != nil || status.Error != "" {
+		t.Errorf("rack status is %+v", status)
+	}
+	if len(status.Values) != 2 {
+		t.Fatalf("rack has values %+v, want 2", status.Values)
+	}
+	if v := status.Values[0]; v.Statistic != "OnBattery" || v.Text != "yes" || v.Value == nil || *v.Value != 1 || !v.Time.Equal(at) {
+		t.Errorf("first value is %+v", v)
+	}
+	if v := status.Values[1]; v.Statistic != "OutputPercentLoad" || v.Unit != "%" || v.Value == nil || *v.Value != 42 {
+		t.Errorf("second value is %+v", v)
+	}
+
+	if status := statuses[1]; status.Polled == nil || status.Error != "timeout" || status.Values == nil {
+		t.Errorf("other status is %+v", status)
+	}
+}
+
+func TestSource(t *testing.T) {
+	s := newTestStore()
+	unnamed := power.Source{Host: "10.0.0.3"}
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 42})
+	s.Send(power.Value{Source: unnamed, Stat: power.OutputPercentLoad, Time: at, Value: 7})
+
+	tests := []struct {
+		path   string
+		status int
+		name   string
+	}{
+		{path: "/api/sources/rack", status: http.StatusOK, name: "rack"},
+		{path: "/api/sources/other", status: http.StatusOK, name: "other"},
+		{path: "/api/sources/" + strings.Replace(unnamed.Key(), ":", "%3A", -1), status: http.StatusOK, name: unnamed.Key()},
+		{path: "/api/sources/missing", status: http.StatusNotFound},
+		{path: "/api/sources/RACK", status: http.StatusNotFound},
+		{path: "/api/sources/", status: http.StatusNotFound},
+		{path: "/api/sources/rack/values", status: http.StatusNotFound},
+		{path: "/api/sources/rack%2Fvalues", status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := get(s, test.path)
+		if w.Code != test.status {
+			t.Errorf("%s returned %d, want %d", test.path, w.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			var body map[string]string
+			decode(t, w, &body)
+			if body["error"] == "" {
+				t.Errorf("%s returned %v without an error", test.path, body)
+			}
+			continue
+		}
+		var status SourceStatus
+		decode(t, w, &status)
+		if status.Name != test.name {
+			t.Errorf("%s returned %s", test.path, status.Name)
+		}
+	}
+}
+
+func TestReady(t *testing.T) {
+	s := newTestStore()
+
+	tests := []struct {
+		poll   power.Source
+		status int
+	}{
+		{status: http.StatusServiceUnavailable},
+		{poll: rack, status: http.StatusServiceUnavailable},
+		{poll: other, status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if test.poll.Name != "" {
+			s.EndCycle(0, test.poll)
+		}
+		if w := get(s, "/readyz"); w.Code != test.status {
+			t.Errorf("after polling %q /readyz returned %d, want %d", test.poll.Name, w.Code, test.status)
+		}
+	}
+
+	// Sources that are no longer configured don't hold up readiness
+	s.SetSources([]power.Source{rack, other, {Host: "10.0.0.3"}})
+	if w := get(s, "/readyz"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with an unpolled source returned %d", w.Code)
+	}
+	s.SetSources([]power.Source{rack})
+	if w := get(s, "/readyz"); w.Code != http.StatusOK {
+		t.Errorf("/readyz after removing the unpolled source returned %d", w.Code)
+	}
+
+	if w := get(s, "/healthz"); w.Code != http.StatusOK {
+		t.Errorf("/healthz returned %d", w.Code)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	s := newTestStore()
+	for _, path := range []string{"/api/sources", "/api/sources/rack", "/api/stream", "/healthz", "/readyz"} {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("POST %s returned %d with Allow %q", path, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	if w := get(newTestStore(), "/api/history/rack/OnBattery"); w.Code != http.StatusNotFound {
+		t.Errorf("/api/history without a history returned %d", w.Code)
+	}
+}
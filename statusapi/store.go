@@ -0,0 +1,305 @@
+// Package statusapi serves the latest power statistics of each source over
+// HTTP.
+//
+// A Store is a recipient that remembers the most recent value of every
+// statistic of every source. Its handler exposes them as JSON and renders a
+// dashboard:
+//
+//	/                    Dashboard with a status tile for each source
+//	/api/sources         Status of all sources
+//	/api/sources/{name}  Status of a single source
+//	/api/stream          Server-sent events describing changes as they happen
+//	/api/history/{name}/{statistic}
+//	                     Stored values of a statistic, if a history is attached
+//	/healthz             Reports that the process is running
+//	/readyz              Reports whether every source has been polled
+package statusapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scjalliance/power"
+	"github.com/scjalliance/power/historyrecipient"
+)
+
+// SourceStatus is the latest status of a single source.
+type SourceStatus struct {
+	Name     string        `json:"name"`
+	Source   string        `json:"source"`
+	Identity string        `json:"identity,omitempty"`
+	Polled   *time.Time    `json:"polled,omitempty"` // Completion time of the latest poll
+	Error    string        `json:"error,omitempty"`  // Error of the latest poll
+	Event    string        `json:"event,omitempty"`  // Most recent event
+	Values   []ValueStatus `json:"values"`
+}
+
+// ValueStatus is the latest value of a single statistic.
+type ValueStatus struct {
+	Statistic string    `json:"statistic"`
+	Unit      string    `json:"unit,omitempty"`
+	Instance  string    `json:"instance,omitempty"` // Row label or index of table statistics
+	Value     *float64  `json:"value,omitempty"`
+	Text      string    `json:"text"` // Human-readable value, such as an enumeration label
+	Time      time.Time `json:"time"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// entry holds the state of a source within a store.
+type entry struct {
+	status SourceStatus
+	values map[string]ValueStatus // Keyed by statistic and instance
+	err    error                  // Error of the poll in progress
+}
+
+// Store is a recipient that holds the latest value of each statistic of each
+// source. It is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	entries map[string]*entry // Keyed by source name
+	order   []string          // Source names in order of configuration
+	subs    map[*Subscription]struct{}
+	closed  bool
+	history *historyrecipient.History
+}
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		entries: make(map[string]*entry),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// SetHistory attaches a history that answers queries for stored values.
+func (s *Store) SetHistory(h *historyrecipient.History) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = h
+}
+
+// History returns the attached history, or nil if there is none.
+func (s *Store) History() *historyrecipient.History {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.history
+}
+
+// SetSources sets the sources that are expected to report to the store.
+// The values of sources that are no longer present are discarded.
+func (s *Store) SetSources(sources []power.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make(map[string]*entry, len(sources))
+	var order []string
+	for _, source := range sources {
+		name := source.Key()
+		if _, dup := entries[name]; dup {
+			continue
+		}
+		e, ok := s.entries[name]
+		if !ok {
+			e = &entry{values: make(map[string]ValueStatus)}
+		}
+		e.status.Name = name
+		e.status.Source = source.String()
+		entries[name] = e
+		order = append(order, name)
+	}
+	s.entries = entries
+	s.order = order
+}
+
+// Send records v as the latest value of its statistic.
+//
+// When the value of an enumerated statistic changes, a transition message
+// is published in addition to the value.
+func (s *Store) Send(v power.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(v.Source)
+	status := ValueStatus{
+		Statistic: v.Stat.Name,
+		Unit:      v.Stat.Unit,
+		Instance:  v.Instance(),
+		Text:      v.String(),
+		Time:      v.Time,
+	}
+	if v.Err != nil {
+		status.Error = v.Err.Error()
+	} else {
+		value := v.Value
+		status.Value = &value
+	}
+	key := status.Statistic + "\x00" + status.Instance
+	previous, seen := e.values[key]
+	e.values[key] = status
+
+	name := e.status.Name
+	s.publish(Message{Type: MessageValue, Source: name, Time: v.Time, Value: &status})
+
+	if v.Stat.Enum == nil || !seen || previous.Value == nil || status.Value == nil || *previous.Value == *status.Value {
+		return
+	}
+	s.publish(Message{
+		Type:   MessageTransition,
+		Source: name,
+		Time:   v.Time,
+		Value:  &status,
+		From:   previous.Text,
+		To:     status.Text,
+	})
+}
+
+// SendSource clears the error of a source at the start of a poll.
+func (s *Store) SendSource(i int, source power.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(source).err = nil
+}
+
+// SendIdentity records the identity of a source's device.
+func (s *Store) SendIdentity(i int, source power.Source, id power.Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(source).status.Identity = id.String()
+}
+
+// SendQueryError records the failure of a poll.
+func (s *Store) SendQueryError(i int, source power.Source, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(source).err = err
+}
+
+// SendEvent records the most recent event of a source.
+func (s *Store) SendEvent(e power.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(e.Source)
+	entry.status.Event = e.String()
+	s.publish(Message{Type: MessageEvent, Source: entry.status.Name, Time: time.Now(), Event: entry.status.Event})
+}
+
+// EndCycle records the completion of a poll.
+func (s *Store) EndCycle(i int, source power.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(source)
+	now := time.Now()
+	e.status.Polled = &now
+	e.status.Error = ""
+	if e.err != nil {
+		e.status.Error = e.err.Error()
+	}
+	s.publish(Message{Type: MessagePoll, Source: e.status.Name, Time: now, Error: e.status.Error})
+}
+
+// Sources returns the status of every source in order of configuration.
+// Sources that were not configured by SetSources follow in order of name.
+func (s *Store) Sources() []SourceStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := s.names()
+	statuses := make([]SourceStatus, 0, len(names))
+	for _, name := range names {
+		statuses = append(statuses, s.entries[name].snapshot())
+	}
+	return statuses
+}
+
+// names returns the names of all sources in order of configuration,
+// followed by those that were not configured in order of name. The caller
+// must hold s.mu.
+func (s *Store) names() []string {
+	names := append([]string(nil), s.order...)
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+	var extra []string
+	for name := range s.entries {
+		if !known[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// Source returns the status of the source with the given name.
+func (s *Store) Source(name string) (SourceStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return SourceStatus{}, false
+	}
+	return e.snapshot(), true
+}
+
+// Ready returns true if every source has completed at least one poll.
+func (s *Store) Ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.entries {
+		if e.status.Polled == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// entry returns the entry of source, creating it if necessary. The caller
+// must hold s.mu for writing.
+func (s *Store) entry(source power.Source) *entry {
+	name := source.Key()
+	e, ok := s.entries[name]
+	if !ok {
+		e = &entry{values: make(map[string]ValueStatus)}
+		e.status.Name = name
+		e.status.Source = source.String()
+		s.entries[name] = e
+	}
+	return e
+}
+
+// snapshot returns a copy of the entry's status with its values sorted by
+// statistic and instance.
+func (e *entry) snapshot() SourceStatus {
+	status := e.status
+	if e.status.Polled != nil {
+		polled := *e.status.Polled
+		status.Polled = &polled
+	}
+	status.Values = make([]ValueStatus, 0, len(e.values))
+	for _, v := range e.values {
+		status.Values = append(status.Values, v)
+	}
+	sort.Slice(status.Values, func(i, j int) bool {
+		a, b := status.Values[i], status.Values[j]
+		if a.Statistic != b.Statistic {
+			return a.Statistic < b.Statistic
+		}
+		return a.Instance < b.Instance
+	})
+	return status
+}
+
+// value returns the latest value of the named statistic, if it is known.
+func (status SourceStatus) value(statistic string) (ValueStatus, bool) {
+	for _, v := range status.Values {
+		if v.Statistic == statistic && v.Instance == "" {
+			return v, true
+		}
+	}
+	return ValueStatus{}, false
+}
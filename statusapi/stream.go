@@ -0,0 +1,183 @@
+package statusapi
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSubscriptionBuffer is the number of messages that are buffered for
+// each subscription.
+const DefaultSubscriptionBuffer = 256
+
+// Message types
+const (
+	MessageValue      = "value"      // A new value of a statistic
+	MessageTransition = "transition" // A change of an enumerated statistic, such as OnBattery
+	MessageEvent      = "event"      // An event reported by a source, such as a trap
+	MessagePoll       = "poll"       // The completion of a poll, with its error if it failed
+)
+
+// Message describes a change to the contents of a store.
+type Message struct {
+	Type   string       `json:"type"`
+	Source string       `json:"source"`
+	Time   time.Time    `json:"time"`
+	Value  *ValueStatus `json:"value,omitempty"` // Value and transition messages
+	From   string       `json:"from,omitempty"`  // Previous label of transition messages
+	To     string       `json:"to,omitempty"`    // New label of transition messages
+	Event  string       `json:"event,omitempty"` // Event messages
+	Error  string       `json:"error,omitempty"` // Poll messages for failed polls
+}
+
+// Filter selects the sources and statistics of interest to a subscriber.
+// Empty lists match everything. Names are case insensitive.
+type Filter struct {
+	Sources    []string
+	Statistics []string
+}
+
+// matchSource returns true if the filter includes the named source.
+func (f Filter) matchSource(name string) bool {
+	return matchAny(f.Sources, name)
+}
+
+// match returns true if the filter includes the message. Messages that
+// don't carry a value are matched by source alone.
+func (f Filter) match(m Message) bool {
+	if !f.matchSource(m.Source) {
+		return false
+	}
+	if m.Value == nil {
+		return true
+	}
+	return matchAny(f.Statistics, m.Value.Statistic)
+}
+
+// apply returns the portion of status included by the filter.
+func (f Filter) apply(status SourceStatus) SourceStatus {
+	if len(f.Statistics) == 0 {
+		return status
+	}
+	values := status.Values
+	status.Values = make([]ValueStatus, 0, len(values))
+	for _, v := range values {
+		if matchAny(f.Statistics, v.Statistic) {
+			status.Values = append(status.Values, v)
+		}
+	}
+	return status
+}
+
+// matchAny returns true if list is empty or contains name.
+func matchAny(list []string, name string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, element := range list {
+		if strings.EqualFold(element, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription delivers the messages of a store that match a filter.
+//
+// Messages are buffered. A subscription that falls behind is closed so that
+// its subscriber can resubscribe and receive a fresh snapshot rather than
+// silently missing changes.
+type Subscription struct {
+	// Snapshot holds the status of the matching sources at the time of
+	// subscription. Messages on C describe changes that follow it.
+	Snapshot []SourceStatus
+
+	// C receives messages until the subscription is closed.
+	C <-chan Message
+
+	c         chan Message
+	filter    Filter
+	store     *Store
+	closeOnce sync.Once
+}
+
+// Close ends the subscription and closes C.
+func (sub *Subscription) Close() {
+	sub.store.mu.Lock()
+	defer sub.store.mu.Unlock()
+	sub.close()
+}
+
+// close ends the subscription. The caller must hold the store's lock for
+// writing.
+func (sub *Subscription) close() {
+	sub.closeOnce.Do(func() {
+		delete(sub.store.subs, sub)
+		close(sub.c)
+	})
+}
+
+// Subscribe returns a subscription to the messages of the store that match
+// filter, along with a snapshot of the matching sources.
+//
+// If the store has been closed the subscription's channel is already
+// closed.
+//
+// Subscriptions are provided by the store rather than by the recipient
+// interfaces of the power package. The store is itself a recipient, and a
+// consumer other than the HTTP API subscribes by adding a store to its
+// recipients, so recipients that don't need live updates aren't required to
+// buffer them.
+func (s *Store) Subscribe(filter Filter) *Subscription {
+	c := make(chan Message, DefaultSubscriptionBuffer)
+	sub := &Subscription{
+		C:      c,
+		c:      c,
+		filter: filter,
+		store:  s,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range s.names() {
+		if filter.matchSource(name) {
+			sub.Snapshot = append(sub.Snapshot, filter.apply(s.entries[name].snapshot()))
+		}
+	}
+
+	if s.closed {
+		close(c)
+		return sub
+	}
+	s.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// Close ends all subscriptions to the store. Subsequent subscriptions are
+// closed immediately. The store continues to record values.
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for sub := range s.subs {
+		sub.close()
+	}
+}
+
+// publish delivers m to the matching subscriptions. Subscriptions that can't
+// accept the message are closed. The caller must hold s.mu for writing.
+func (s *Store) publish(m Message) {
+	for sub := range s.subs {
+		if !sub.filter.match(m) {
+			continue
+		}
+		select {
+		case sub.c <- m:
+		default:
+			sub.close()
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package statusapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// receive returns the next message of sub, failing if none arrives.
+func receive(t *testing.T, sub *Subscription) Message {
+	t.Helper()
+	select {
+	case m, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return Message{}
+}
+
+// idle fails if sub holds a message.
+func idle(t *testing.T, sub *Subscription) {
+	t.Helper()
+	select {
+	case m := <-sub.C:
+		t.Fatalf("unexpected message %+v", m)
+	default:
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := newTestStore()
+	s.Send(power.Value{Source: rack, Stat: power.OnBattery, Time: at, Value: 0})
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 42})
+	s.Send(power.Value{Source: other, Stat: power.OnBattery, Time: at, Value: 0})
+
+	sub := s.Subscribe(Filter{Sources: []string{"RACK"}, Statistics: []string{"onbattery"}})
+	defer sub.Close()
+
+	// The snapshot holds the matching sources and statistics
+	if len(sub.Snapshot) != 1 || sub.Snapshot[0].Name != "rack" {
+		t.Fatalf("snapshot is %+v, want rack alone", sub.Snapshot)
+	}
+	if values := sub.Snapshot[0].Values; len(values) != 1 || values[0].Statistic != "OnBattery" {
+		t.Fatalf("snapshot holds %+v, want OnBattery alone", values)
+	}
+	idle(t, sub)
+
+	// Changes to other sources and statistics are filtered out, while
+	// messages without values are matched by source
+	s.Send(power.Value{Source: other, Stat: power.OnBattery, Time: at, Value: 1})
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 43})
+	s.Send(power.Value{Source: rack, Stat: power.OnBattery, Time: at.Add(time.Minute), Value: 1})
+	s.SendEvent(power.Event{Source: rack, Name: "upsTrapOnBattery"})
+	s.SendEvent(power.Event{Source: other, Name: "upsTrapOnBattery"})
+	s.EndCycle(0, rack)
+
+	want := []struct {
+		typ  string
+		text string
+	}{
+		{typ: MessageValue, text: "yes"},
+		{typ: MessageTransition, text: "yes"},
+		{typ: MessageEvent},
+		{typ: MessagePoll},
+	}
+	for _, w := range want {
+		m := receive(t, sub)
+		if m.Type != w.typ || m.Source != "rack" {
+			t.Fatalf("received %+v, want a %s message from rack", m, w.typ)
+		}
+		if w.text != "" && (m.Value == nil || m.Value.Statistic != "OnBattery" || m.Value.Text != w.text) {
+			t.Errorf("%s message holds %+v", m.Type, m.Value)
+		}
+		if m.Type == MessageTransition && (m.From != "no" || m.To != "yes") {
+			t.Errorf("transition from %q to %q", m.From, m.To)
+		}
+		if m.Type == MessageEvent && m.Event != "upsTrapOnBattery" {
+			t.Errorf("event message holds %q", m.Event)
+		}
+	}
+	idle(t, sub)
+
+	// Values that don't change an enumerated statistic aren't transitions
+	s.Send(power.Value{Source: rack, Stat: power.OnBattery, Time: at.Add(2 * time.Minute), Value: 1})
+	if m := receive(t, sub); m.Type != MessageValue {
+		t.Errorf("received %+v, want a value", m)
+	}
+	idle(t, sub)
+}
+
+func TestSubscribeOverflow(t *testing.T) {
+	s := newTestStore()
+	slow := s.Subscribe(Filter{})
+	fast := s.Subscribe(Filter{Sources: []string{"other"}})
+	defer fast.Close()
+
+	// A subscription that falls behind is closed after its buffered
+	// messages
+	for i := 0; i <= DefaultSubscriptionBuffer; i++ {
+		s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: float64(i)})
+	}
+	for i := 0; i < DefaultSubscriptionBuffer; i++ {
+		receive(t, slow)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Fatal("subscription that fell behind is still open")
+	}
+	slow.Close()
+
+	// Other subscriptions are unaffected
+	s.Send(power.Value{Source: other, Stat: power.OutputPercentLoad, Time: at, Value: 1})
+	if m := receive(t, fast); m.Source != "other" {
+		t.Errorf("received %+v", m)
+	}
+}
+
+func TestStoreClose(t *testing.T) {
+	s := newTestStore()
+	sub := s.Subscribe(Filter{})
+	s.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription is open after the store was closed")
+	}
+	sub.Close()
+
+	// Later subscriptions receive a snapshot but no messages, and the store
+	// continues to record values
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 42})
+	sub = s.Subscribe(Filter{Sources: []string{"rack"}})
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription to a closed store is open")
+	}
+	if len(sub.Snapshot) != 1 || len(sub.Snapshot[0].Values) != 1 {
+		t.Errorf("snapshot is %+v", sub.Snapshot)
+	}
+}
+
+// event is a server-sent event.
+type event struct {
+	name string
+	data string
+}
+
+// readEvent returns the next event of r, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) event {
+	t.Helper()
+	var e event
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unable to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && e.name != "":
+			return e
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := newTestStore()
+	s.Send(power.Value{Source: rack, Stat: power.OnBattery, Time: at, Value: 0})
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 42})
+
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/api/stream?source=rack,missing&statistic=OnBattery&statistic=EstimatedMinutesRemaining")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if ct := response.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("stream has content type %q", ct)
+	}
+	r := bufio.NewReader(response.Body)
+
+	// The snapshot comes first, and the subscription is in place once it
+	// has been sent
+	e := readEvent(t, r)
+	if e.name != "snapshot" {
+		t.Fatalf("first event is %q, want snapshot", e.name)
+	}
+	var snapshot []SourceStatus
+	if err := json.Unmarshal([]byte(e.data), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot) != 1 || snapshot[0].Name != "rack" || len(snapshot[0].Values) != 1 || snapshot[0].Values[0].Statistic != "OnBattery" {
+		t.Fatalf("snapshot is %+v", snapshot)
+	}
+
+	s.Send(power.Value{Source: other, Stat: power.OnBattery, Time: at, Value: 1})
+	s.Send(power.Value{Source: rack, Stat: power.OutputPercentLoad, Time: at, Value: 43})
+	s.Send(power.Value{Source: rack, Stat: power.OnBattery, Time: at, Value: 1})
+	s.Send(power.Value{Source: rack, Stat: power.EstimatedMinutesRemaining, Time: at, Value: 30})
+
+	for _, want := range []struct {
+		name      string
+		statistic string
+	}{
+		{name: MessageValue, statistic: "OnBattery"},
+		{name: MessageTransition, statistic: "OnBattery"},
+		{name: MessageValue, statistic: "EstimatedMinutesRemaining"},
+	} {
+		e := readEvent(t, r)
+		var m Message
+		if err := json.Unmarshal([]byte(e.data), &m); err != nil {
+			t.Fatal(err)
+		}
+		if e.name != want.name || m.Type != want.name || m.Source != "rack" || m.Value == nil || m.Value.Statistic != want.statistic {
+			t.Fatalf("received %s event %+v, want %s of %s", e.name, m, want.name, want.statistic)
+		}
+	}
+
+	// Closing the store ends the stream
+	s.Close()
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("stream continued after the store was closed")
+	}
+}
+
+func TestStreamEmpty(t *testing.T) {
+	s := newTestStore()
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/api/stream?source=missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if e := readEvent(t, bufio.NewReader(response.Body)); e.name != "snapshot" || e.data != "[]" {
+		t.Errorf("first event is %+v, want an empty snapshot", e)
+	}
+}
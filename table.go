@@ -0,0 +1,110 @@
+package power
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// walkRepetitions is the max-repetitions value used in the GetBulk requests
+// issued while walking a subtree.
+const walkRepetitions = 25
+
+// queryTable walks the columns of a table statistic in priority order and
+// returns a value for each row of the first column that contains rows. The
+// given value is used as a template for each row.
+//
+// If none of the columns contain rows a single value holding the last error
+// is returned.
+func queryTable(ctx context.Context, snmp *snmpgo.SNMP, template Value) (results []Value) {
+	stat := template.Stat
+
+	var err error
+	for i, column := range stat.OID {
+		var rows snmpgo.VarBinds
+		rows, err = walk(ctx, snmp, column)
+		if err != nil {
+			continue
+		}
+		if len(rows) == 0 {
+			err = ErrNoSuchObject
+			continue
+		}
+
+		// Row labels are optional; failure to retrieve them is not fatal
+		labels := make(map[string]string)
+		if labelColumn := stat.labelOID(i); labelColumn != nil {
+			if labelRows, labelErr := walk(ctx, snmp, labelColumn); labelErr == nil {
+				for _, row := range labelRows {
+					labels[rowIndex(labelColumn, row.Oid)] = octetString(row)
+				}
+			}
+		}
+
+		mapper := stat.mapper(i)
+		for _, row := range rows {
+			value := template
+			value.Index = rowIndex(column, row.Oid)
+			value.Label = labels[value.Index]
+			value.Value, value.Err = mapper(row.Variable)
+			if value.Err != nil {
+				value.Err = fmt.Errorf("unable to parse returned value %s: %s", row.Variable.String(), value.Err)
+			}
+			results = append(results, value)
+		}
+		return
+	}
+
+	template.Err = err
+	return []Value{template}
+}
+
+// walk retrieves all of the variables within the subtree identified by root.
+// Variables that indicate missing objects are omitted.
+func walk(ctx context.Context, snmp *snmpgo.SNMP, root *snmpgo.Oid) (bindings snmpgo.VarBinds, err error) {
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	default:
+	}
+
+	pdu, err := snmp.GetBulkWalk(snmpgo.Oids{root}, 0, walkRepetitions)
+	if err != nil {
+		err = fmt.Errorf("failed to execute SNMP walk: %s", err)
+		return
+	}
+	if pdu.ErrorStatus() != snmpgo.NoError {
+		err = fmt.Errorf("SNMP agent returned an error: [%d] %s", pdu.ErrorIndex(), pdu.ErrorStatus())
+		return
+	}
+
+	for _, binding := range pdu.VarBinds() {
+		if !root.Contains(binding.Oid) {
+			continue
+		}
+		switch binding.Variable.Type() {
+		case "NoSucheInstance", "NoSucheObject", "EndOfMibView":
+			continue
+		}
+		bindings = append(bindings, binding)
+	}
+
+	return
+}
+
+// rowIndex returns the index of a table row as a dotted string, which is
+// made up of the sub-identifiers of oid that follow column.
+func rowIndex(column, oid *snmpgo.Oid) string {
+	if len(oid.Value) <= len(column.Value) {
+		return ""
+	}
+	parts := make([]string, 0, len(oid.Value)-len(column.Value))
+	for _, id := range oid.Value[len(column.Value):] {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ".")
+}
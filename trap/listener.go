@@ -0,0 +1,213 @@
+// Package trap receives SNMP traps and informs from power sources and
+// translates them into power events.
+package trap
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scjalliance/power"
+)
+
+// DefaultAddress is the default address on which traps are received.
+const DefaultAddress = ":162"
+
+// maxMessageSize is the largest SNMP message that will be accepted.
+const maxMessageSize = 65535
+
+// eventQueueSize is the number of events that may wait to be handled. Events
+// received while the queue is full are discarded and counted.
+const eventQueueSize = 64
+
+// Handler is called for each event decoded from a trap.
+type Handler func(power.Event)
+
+// Listener receives SNMPv1 and SNMPv2c traps and SNMPv2c informs.
+//
+// Notifications are only accepted from the configured sources. The sender of
+// each notification is matched against the source hosts, and the community
+// of the notification must match the community of the source.
+type Listener struct {
+	conn   net.PacketConn
+	logger *log.Logger
+
+	dropped uint64
+
+	mu      sync.RWMutex
+	sources []sourceAddrs
+
+	closeOnce sync.Once
+}
+
+// sourceAddrs holds the resolved addresses of a source.
+type sourceAddrs struct {
+	source power.Source
+	addrs  []net.IP
+}
+
+// Listen returns a listener for the given UDP address that accepts traps
+// from the given sources.
+//
+// The host of each source is resolved when the listener is created.
+func Listen(address string, sources []power.Source) (*Listener, error) {
+	if address == "" {
+		address = DefaultAddress
+	}
+
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen for traps on %s: %v", address, err)
+	}
+
+	l := &Listener{
+		conn:   conn,
+		logger: log.New(os.Stderr, "trap: ", log.LstdFlags),
+	}
+	l.SetSources(sources)
+
+	return l, nil
+}
+
+// SetSources replaces the sources from which traps are accepted. It is safe
+// to call while the listener is serving.
+//
+// The host of each source is resolved before the sources are replaced.
+func (l *Listener) SetSources(sources []power.Source) {
+	resolved := make([]sourceAddrs, 0, len(sources))
+	for _, source := range sources {
+		resolved = append(resolved, sourceAddrs{
+			source: source,
+			addrs:  resolve(source.Host),
+		})
+	}
+
+	l.mu.Lock()
+	l.sources = resolved
+	l.mu.Unlock()
+}
+
+// Addr returns the local address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// Dropped returns the number of events that have been discarded because the
+// handler fell behind.
+func (l *Listener) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Serve receives notifications and passes the events decoded from them to
+// handler. It blocks until the listener is closed and the events that were
+// received have been handled.
+//
+// Events are handled in order from a separate goroutine, so a slow handler
+// doesn't delay the receipt of notifications. If the handler falls behind,
+// events are discarded and counted, and the number of discarded events is
+// logged once the handler catches up. Informs are acknowledged before their events are
+// handled.
+func (l *Listener) Serve(handler Handler) error {
+	events := make(chan power.Event, eventQueueSize)
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		var reported uint64
+		for e := range events {
+			handler(e)
+			if dropped := l.Dropped(); dropped != reported {
+				l.logger.Printf("%d events dropped because the queue was full", dropped-reported)
+				reported = dropped
+			}
+		}
+	}()
+	defer func() {
+		close(events)
+		<-handled
+	}()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		source, ok := l.match(udpAddr.IP)
+		if !ok {
+			continue
+		}
+
+		msg, err := decode(buf[:n])
+		if err != nil {
+			continue
+		}
+		if msg.community != source.Community {
+			continue
+		}
+
+		if msg.response != nil {
+			l.conn.WriteTo(msg.response, addr)
+		}
+
+		select {
+		case events <- msg.event(source, time.Now()):
+		default:
+			atomic.AddUint64(&l.dropped, 1)
+		}
+	}
+}
+
+// Close stops the listener.
+func (l *Listener) Close() (err error) {
+	l.closeOnce.Do(func() {
+		err = l.conn.Close()
+	})
+	return
+}
+
+// match returns the source with an address matching ip.
+func (l *Listener) match(ip net.IP) (power.Source, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, candidate := range l.sources {
+		for _, addr := range candidate.addrs {
+			if addr.Equal(ip) {
+				return candidate.source, true
+			}
+		}
+	}
+	return power.Source{}, false
+}
+
+// resolve returns the IP addresses for host. If host cannot be resolved it
+// returns nil.
+func resolve(host string) (addrs []net.IP) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	names, err := net.LookupHost(host)
+	if err != nil {
+		return nil
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			addrs = append(addrs, ip)
+		}
+	}
+	return
+}
@@ -0,0 +1,198 @@
+package trap
+
+import (
+	"encoding/asn1"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+)
+
+// listen returns a listener on the loopback interface that accepts traps from
+// the given sources. Serve is started with handler and the listener is
+// closed when the test completes.
+func listen(t *testing.T, sources []power.Source, handler Handler) *Listener {
+	t.Helper()
+	l, err := Listen("127.0.0.1:0", sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.logger = log.New(io.Discard, "", 0)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- l.Serve(handler)
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		if err := <-served; err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	})
+	return l
+}
+
+// send returns a UDP connection from the loopback interface to l and sends
+// each message over it.
+func send(t *testing.T, l *Listener, messages ...[]byte) net.Conn {
+	t.Helper()
+	return sendFrom(t, "127.0.0.1", l, messages...)
+}
+
+// sendFrom returns a UDP connection from the given local address to l and
+// sends each message over it.
+func sendFrom(t *testing.T, local string, l *Listener, messages ...[]byte) net.Conn {
+	t.Helper()
+	conn, err := net.DialUDP("udp", &net.UDPAddr{IP: net.ParseIP(local)}, l.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for _, msg := range messages {
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return conn
+}
+
+func TestServe(t *testing.T) {
+	sources := []power.Source{
+		{Host: "192.0.2.1", Community: "public", Name: "elsewhere"},
+		{Host: "127.0.0.1", Community: "secret", Name: "local"},
+	}
+	events := make(chan power.Event, 8)
+	l := listen(t, sources, func(e power.Event) { events <- e })
+
+	onBattery := v2Bindings("1.3.6.1.2.1.33.2.0.1")
+	send(t, l,
+		v2Message(t, "public", snmpgo.SNMPTrapV2, 1, onBattery),                         // Wrong community
+		[]byte("not a notification"),                                                    // Malformed
+		v2Message(t, "secret", snmpgo.SNMPTrapV2, 2, v2Bindings("1.3.6.1.4.1.318.0.9")), // Accepted
+	)
+
+	select {
+	case e := <-events:
+		if e.Source.Name != "local" || e.Name != "powerRestored" {
+			t.Fatalf("received %s from %s, want powerRestored from local", e.Name, e.Source.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	// Traps from unknown senders are ignored until they are added as sources
+	sendFrom(t, "127.0.0.2", l, v2Message(t, "secret", snmpgo.SNMPTrapV2, 3, onBattery))
+	send(t, l, v2Message(t, "secret", snmpgo.SNMPTrapV2, 4, v2Bindings("1.3.6.1.2.1.33.2.0.2")))
+	select {
+	case e := <-events:
+		if e.Name != "upsTrapTestCompleted" {
+			t.Fatalf("received %s, want upsTrapTestCompleted", e.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	l.SetSources(append(sources, power.Source{Host: "127.0.0.2", Community: "secret", Name: "added"}))
+	sendFrom(t, "127.0.0.2", l, v2Message(t, "secret", snmpgo.SNMPTrapV2, 5, v2Bindings("1.3.6.1.2.1.33.2.0.2")))
+
+	select {
+	case e := <-events:
+		if e.Source.Name != "added" {
+			t.Fatalf("received %s from %s, want it from added", e.Name, e.Source.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestServeInform(t *testing.T) {
+	events := make(chan power.Event, 1)
+	l := listen(t, []power.Source{{Host: "127.0.0.1", Community: "public"}}, func(e power.Event) { events <- e })
+
+	conn := send(t, l, v2Message(t, "public", snmpgo.InformRequest, 77, v2Bindings("1.3.6.1.2.1.33.2.0.1")))
+
+	// The inform is acknowledged to its sender
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no acknowledgement received: %v", err)
+	}
+	var env envelope
+	if _, err := asn1.Unmarshal(buf[:n], &env); err != nil {
+		t.Fatalf("unable to parse acknowledgement: %v", err)
+	}
+	pdu := snmpgo.NewPdu(snmpgo.V2c, snmpgo.GetResponse)
+	if _, err := pdu.Unmarshal(env.Data.FullBytes); err != nil {
+		t.Fatalf("unable to parse acknowledgement: %v", err)
+	}
+	if pdu.PduType() != snmpgo.GetResponse || pdu.RequestId() != 77 {
+		t.Fatalf("acknowledgement has type %v and request ID %d", pdu.PduType(), pdu.RequestId())
+	}
+
+	select {
+	case e := <-events:
+		if e.Name != "upsTrapOnBattery" {
+			t.Fatalf("received %s, want upsTrapOnBattery", e.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestServeDropped(t *testing.T) {
+	const extra = 3
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := make(chan struct{}, eventQueueSize+extra+1)
+	l := listen(t, []power.Source{{Host: "127.0.0.1", Community: "public"}}, func(e power.Event) {
+		if e.OID == "1.3.6.1.2.1.33.2.0.2" {
+			close(started)
+			<-release
+		}
+		handled <- struct{}{}
+	})
+
+	// The handler is blocked by the first event, so the queue fills and the
+	// events that follow are dropped
+	send(t, l, v2Message(t, "public", snmpgo.SNMPTrapV2, 1, v2Bindings("1.3.6.1.2.1.33.2.0.2")))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	msg := v2Message(t, "public", snmpgo.SNMPTrapV2, 2, v2Bindings("1.3.6.1.2.1.33.2.0.1"))
+	conn := send(t, l)
+	for i := 0; i < eventQueueSize+extra; i++ {
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		// Avoid overrunning the socket buffer, which would lose messages
+		// before they reach the listener
+		time.Sleep(time.Millisecond)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Dropped() < extra && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if dropped := l.Dropped(); dropped != extra {
+		t.Fatalf("Dropped returned %d, want %d", dropped, extra)
+	}
+
+	// The queued events are still handled
+	close(release)
+	for i := 0; i < eventQueueSize+1; i++ {
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d events handled, want %d", i, eventQueueSize+1)
+		}
+	}
+}
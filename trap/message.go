@@ -0,0 +1,199 @@
+package trap
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+)
+
+// SNMP message versions
+const (
+	versionV1  = 0
+	versionV2c = 1
+)
+
+// Object identifiers used by notifications
+var (
+	snmpTrapOID = snmpgo.MustNewOid("1.3.6.1.6.3.1.1.4.1.0")
+	snmpTraps   = snmpgo.MustNewOid("1.3.6.1.6.3.1.1.5")
+)
+
+// v1EnterpriseSpecific is the generic trap value of SNMPv1 traps that are
+// identified by their enterprise and specific trap values.
+const v1EnterpriseSpecific = 6
+
+// envelope is the outer structure of SNMPv1 and SNMPv2c messages.
+type envelope struct {
+	Version   int
+	Community []byte
+	Data      asn1.RawValue
+}
+
+// message is a decoded notification.
+type message struct {
+	community string
+	trapOID   *snmpgo.Oid
+	bindings  snmpgo.VarBinds
+	response  []byte // Acknowledgement to send for informs
+}
+
+// event returns the power event described by the message.
+func (m message) event(source power.Source, t time.Time) power.Event {
+	e := power.Event{
+		Source: source,
+		Time:   t,
+		OID:    m.trapOID.String(),
+	}
+	if n, ok := lookup(m.trapOID); ok {
+		e.Name = n.name
+		e.Message = n.message
+		if n.detail != nil {
+			if detail := n.detail(m.bindings); detail != "" {
+				e.Message = fmt.Sprintf("%s: %s", e.Message, detail)
+			}
+		}
+	} else {
+		e.Name = e.OID
+		e.Message = "unrecognized notification"
+	}
+	return e
+}
+
+// decode parses an SNMPv1 or SNMPv2c notification.
+func decode(b []byte) (msg message, err error) {
+	var env envelope
+	if _, err = asn1.Unmarshal(b, &env); err != nil {
+		return
+	}
+	if env.Data.Class != asn1.ClassContextSpecific || !env.Data.IsCompound {
+		err = errors.New("malformed protocol data unit")
+		return
+	}
+
+	msg.community = string(env.Community)
+
+	switch {
+	case env.Version == versionV1 && env.Data.Tag == int(snmpgo.Trap):
+		return decodeV1Trap(msg, env)
+	case env.Version == versionV2c && env.Data.Tag == int(snmpgo.SNMPTrapV2):
+		return decodeV2Trap(msg, env)
+	case env.Version == versionV2c && env.Data.Tag == int(snmpgo.InformRequest):
+		return decodeV2Inform(msg, env)
+	}
+
+	err = fmt.Errorf("unsupported message: version %d pdu type %d", env.Version, env.Data.Tag)
+	return
+}
+
+// decodeV2Trap parses an SNMPv2c trap.
+func decodeV2Trap(msg message, env envelope) (message, error) {
+	pdu := snmpgo.NewPdu(snmpgo.V2c, snmpgo.SNMPTrapV2)
+	if _, err := pdu.Unmarshal(env.Data.FullBytes); err != nil {
+		return msg, err
+	}
+	return withBindings(msg, pdu.VarBinds())
+}
+
+// decodeV2Inform parses an SNMPv2c inform and prepares its acknowledgement.
+func decodeV2Inform(msg message, env envelope) (message, error) {
+	pdu := snmpgo.NewPdu(snmpgo.V2c, snmpgo.InformRequest)
+	if _, err := pdu.Unmarshal(env.Data.FullBytes); err != nil {
+		return msg, err
+	}
+
+	msg, err := withBindings(msg, pdu.VarBinds())
+	if err != nil {
+		return msg, err
+	}
+
+	ack := snmpgo.NewPduWithVarBinds(snmpgo.V2c, snmpgo.GetResponse, pdu.VarBinds())
+	ack.SetRequestId(pdu.RequestId())
+	data, err := ack.Marshal()
+	if err != nil {
+		return msg, err
+	}
+
+	msg.response, err = asn1.Marshal(envelope{
+		Version:   env.Version,
+		Community: env.Community,
+		Data:      asn1.RawValue{FullBytes: data},
+	})
+	return msg, err
+}
+
+// withBindings adds the variable bindings of an SNMPv2 notification to msg.
+// The notification OID is taken from the snmpTrapOID binding.
+func withBindings(msg message, bindings snmpgo.VarBinds) (message, error) {
+	msg.bindings = bindings
+
+	binding := bindings.MatchOid(snmpTrapOID)
+	if binding == nil {
+		return msg, errors.New("notification does not include snmpTrapOID")
+	}
+	oid, ok := binding.Variable.(*snmpgo.Oid)
+	if !ok {
+		return msg, errors.New("notification includes an invalid snmpTrapOID")
+	}
+	msg.trapOID = oid
+
+	return msg, nil
+}
+
+// decodeV1Trap parses an SNMPv1 trap. The trap is translated into its
+// SNMPv2 notification OID as described in RFC 3584.
+func decodeV1Trap(msg message, env envelope) (message, error) {
+	var (
+		rest         = env.Data.Bytes
+		enterprise   asn1.ObjectIdentifier
+		agentAddress asn1.RawValue
+		genericTrap  int
+		specificTrap int
+		timestamp    asn1.RawValue
+		varBinds     asn1.RawValue
+		err          error
+	)
+
+	for _, field := range []interface{}{&enterprise, &agentAddress, &genericTrap, &specificTrap, &timestamp, &varBinds} {
+		if rest, err = asn1.Unmarshal(rest, field); err != nil {
+			return msg, fmt.Errorf("malformed SNMPv1 trap: %v", err)
+		}
+	}
+
+	// The variable bindings are decoded by wrapping them in a synthetic
+	// response, which shares the structure of other protocol data units
+	var body []byte
+	for i := 0; i < 3; i++ {
+		// Request ID, error status and error index
+		zero, _ := asn1.Marshal(0)
+		body = append(body, zero...)
+	}
+	body = append(body, varBinds.FullBytes...)
+	data, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        int(snmpgo.GetResponse),
+		IsCompound: true,
+		Bytes:      body,
+	})
+	if err != nil {
+		return msg, err
+	}
+
+	pdu := snmpgo.NewPdu(snmpgo.V1, snmpgo.GetResponse)
+	if _, err = pdu.Unmarshal(data); err != nil {
+		return msg, err
+	}
+	msg.bindings = pdu.VarBinds()
+
+	if genericTrap == v1EnterpriseSpecific {
+		msg.trapOID, err = snmpgo.NewOid(fmt.Sprintf("%s.0.%d", enterprise, specificTrap))
+	} else {
+		msg.trapOID, err = snmpgo.NewOid(snmpTraps.String() + "." + strconv.Itoa(genericTrap+1))
+	}
+
+	return msg, err
+}
@@ -0,0 +1,321 @@
+package trap
+
+import (
+	"encoding/asn1"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/k-sone/snmpgo"
+	"github.com/scjalliance/power"
+)
+
+// v2Message returns an encoded SNMPv2c message holding a protocol data unit
+// of type t with the given variable bindings.
+func v2Message(t *testing.T, community string, pduType snmpgo.PduType, requestID int, bindings snmpgo.VarBinds) []byte {
+	t.Helper()
+	pdu := snmpgo.NewPduWithVarBinds(snmpgo.V2c, pduType, bindings)
+	pdu.SetRequestId(requestID)
+	data, err := pdu.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := asn1.Marshal(envelope{
+		Version:   versionV2c,
+		Community: []byte(community),
+		Data:      asn1.RawValue{FullBytes: data},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// v2Bindings returns the variable bindings of an SNMPv2 notification.
+func v2Bindings(trapOID string, extra ...*snmpgo.VarBind) snmpgo.VarBinds {
+	bindings := snmpgo.VarBinds{
+		snmpgo.NewVarBind(snmpgo.MustNewOid("1.3.6.1.2.1.1.3.0"), snmpgo.NewTimeTicks(12345)),
+		snmpgo.NewVarBind(snmpTrapOID, snmpgo.MustNewOid(trapOID)),
+	}
+	return append(bindings, extra...)
+}
+
+// v1Trap returns an encoded SNMPv1 trap.
+func v1Trap(t *testing.T, community, enterprise string, generic, specific int, bindings snmpgo.VarBinds) []byte {
+	t.Helper()
+
+	// The variable bindings are taken from an encoded protocol data unit,
+	// after its request ID, error status and error index
+	data, err := snmpgo.NewPduWithVarBinds(snmpgo.V1, snmpgo.GetResponse, bindings).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pdu asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &pdu); err != nil {
+		t.Fatal(err)
+	}
+	rest := pdu.Bytes
+	for i := 0; i < 3; i++ {
+		var n int
+		if rest, err = asn1.Unmarshal(rest, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var body []byte
+	for _, field := range []interface{}{
+		oid(t, enterprise),
+		asn1.RawValue{Class: asn1.ClassApplication, Tag: 0, Bytes: net.ParseIP("192.0.2.10").To4()},
+		generic,
+		specific,
+		asn1.RawValue{Class: asn1.ClassApplication, Tag: 3, Bytes: []byte{0x30, 0x39}},
+	} {
+		b, err := asn1.Marshal(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = append(body, b...)
+	}
+	body = append(body, rest...)
+
+	b, err := asn1.Marshal(struct {
+		Version   int
+		Community []byte
+		Data      asn1.RawValue
+	}{
+		Version:   versionV1,
+		Community: []byte(community),
+		Data: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        int(snmpgo.Trap),
+			IsCompound: true,
+			Bytes:      body,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// oid parses s as an ASN.1 object identifier.
+func oid(t *testing.T, s string) asn1.ObjectIdentifier {
+	t.Helper()
+	o := snmpgo.MustNewOid(s)
+	return asn1.ObjectIdentifier(o.Value)
+}
+
+func TestDecodeV1(t *testing.T) {
+	alarm := snmpgo.NewVarBind(snmpgo.MustNewOid("1.3.6.1.2.1.33.1.6.2.1.2.7"), snmpgo.MustNewOid("1.3.6.1.2.1.33.1.6.3.3"))
+
+	tests := []struct {
+		name       string
+		enterprise string
+		generic    int
+		specific   int
+		bindings   snmpgo.VarBinds
+		trapOID    string
+		event      string
+		message    string
+	}{
+		{
+			name:       "enterprise specific",
+			enterprise: "1.3.6.1.4.1.318",
+			generic:    v1EnterpriseSpecific,
+			specific:   5,
+			trapOID:    "1.3.6.1.4.1.318.0.5",
+			event:      "upsOnBattery",
+			message:    "UPS has switched to battery backup power",
+		},
+		{
+			name:       "UPS-MIB with bindings",
+			enterprise: "1.3.6.1.2.1.33.2",
+			generic:    v1EnterpriseSpecific,
+			specific:   3,
+			bindings:   snmpgo.VarBinds{alarm},
+			trapOID:    "1.3.6.1.2.1.33.2.0.3",
+			event:      "upsTrapAlarmEntryAdded",
+			message:    "UPS alarm added: lowBattery",
+		},
+		{
+			name:       "Eaton",
+			enterprise: "1.3.6.1.4.1.534.1.11.4.1",
+			generic:    v1EnterpriseSpecific,
+			specific:   3,
+			trapOID:    "1.3.6.1.4.1.534.1.11.4.1.0.3",
+			event:      "xupstdOnBattery",
+			message:    "UPS is operating on battery power",
+		},
+		{
+			name:       "cold start",
+			enterprise: "1.3.6.1.4.1.318",
+			generic:    0,
+			trapOID:    "1.3.6.1.6.3.1.1.5.1",
+			event:      "coldStart",
+			message:    "agent has been restarted",
+		},
+		{
+			name:       "authentication failure",
+			enterprise: "1.3.6.1.4.1.850",
+			generic:    4,
+			trapOID:    "1.3.6.1.6.3.1.1.5.5",
+			event:      "authenticationFailure",
+			message:    "agent received a request with invalid credentials",
+		},
+		{
+			name:       "unrecognized",
+			enterprise: "1.3.6.1.4.1.850.1",
+			generic:    v1EnterpriseSpecific,
+			specific:   42,
+			trapOID:    "1.3.6.1.4.1.850.1.0.42",
+			event:      "1.3.6.1.4.1.850.1.0.42",
+			message:    "unrecognized notification",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := decode(v1Trap(t, "public", test.enterprise, test.generic, test.specific, test.bindings))
+			if err != nil {
+				t.Fatalf("decode returned %v", err)
+			}
+			if msg.community != "public" {
+				t.Errorf("community = %q, want \"public\"", msg.community)
+			}
+			if msg.response != nil {
+				t.Errorf("trap has a response")
+			}
+			if len(msg.bindings) != len(test.bindings) {
+				t.Errorf("decoded %d bindings, want %d", len(msg.bindings), len(test.bindings))
+			}
+			checkEvent(t, msg, test.trapOID, test.event, test.message)
+		})
+	}
+}
+
+func TestDecodeV2(t *testing.T) {
+	removed := snmpgo.NewVarBind(snmpgo.MustNewOid("1.3.6.1.2.1.33.1.6.2.1.2.7"), snmpgo.MustNewOid("1.3.6.1.4.1.99999.1"))
+
+	tests := []struct {
+		name     string
+		bindings snmpgo.VarBinds
+		trapOID  string
+		event    string
+		message  string
+	}{
+		{
+			name:     "UPS-MIB",
+			bindings: v2Bindings("1.3.6.1.2.1.33.2.0.1"),
+			trapOID:  "1.3.6.1.2.1.33.2.0.1",
+			event:    "upsTrapOnBattery",
+			message:  "UPS is operating on battery power",
+		},
+		{
+			name:     "unknown alarm",
+			bindings: v2Bindings("1.3.6.1.2.1.33.2.0.4", removed),
+			trapOID:  "1.3.6.1.2.1.33.2.0.4",
+			event:    "upsTrapAlarmEntryRemoved",
+			message:  "UPS alarm removed: 1.3.6.1.4.1.99999.1",
+		},
+		{
+			name:     "Eaton",
+			bindings: v2Bindings("1.3.6.1.4.1.534.1.11.4.1.0.5"),
+			trapOID:  "1.3.6.1.4.1.534.1.11.4.1.0.5",
+			event:    "xupstdUtilityPowerRestored",
+			message:  "utility power has been restored",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := decode(v2Message(t, "private", snmpgo.SNMPTrapV2, 1, test.bindings))
+			if err != nil {
+				t.Fatalf("decode returned %v", err)
+			}
+			if msg.community != "private" {
+				t.Errorf("community = %q, want \"private\"", msg.community)
+			}
+			if msg.response != nil {
+				t.Errorf("trap has a response")
+			}
+			checkEvent(t, msg, test.trapOID, test.event, test.message)
+		})
+	}
+}
+
+func TestDecodeInform(t *testing.T) {
+	bindings := v2Bindings("1.3.6.1.4.1.318.0.9")
+	msg, err := decode(v2Message(t, "public", snmpgo.InformRequest, 4242, bindings))
+	if err != nil {
+		t.Fatalf("decode returned %v", err)
+	}
+	checkEvent(t, msg, "1.3.6.1.4.1.318.0.9", "powerRestored", "utility power has been restored")
+
+	// The acknowledgement is a response with the same request ID, community
+	// and bindings
+	if msg.response == nil {
+		t.Fatal("inform has no response")
+	}
+	var env envelope
+	if _, err := asn1.Unmarshal(msg.response, &env); err != nil {
+		t.Fatalf("unable to parse response: %v", err)
+	}
+	if env.Version != versionV2c || string(env.Community) != "public" {
+		t.Errorf("response has version %d and community %q", env.Version, env.Community)
+	}
+	pdu := snmpgo.NewPdu(snmpgo.V2c, snmpgo.GetResponse)
+	if _, err := pdu.Unmarshal(env.Data.FullBytes); err != nil {
+		t.Fatalf("unable to parse response: %v", err)
+	}
+	if pdu.PduType() != snmpgo.GetResponse {
+		t.Errorf("response has type %v, want %v", pdu.PduType(), snmpgo.GetResponse)
+	}
+	if pdu.RequestId() != 4242 {
+		t.Errorf("response has request ID %d, want 4242", pdu.RequestId())
+	}
+	if len(pdu.VarBinds()) != len(bindings) {
+		t.Errorf("response has %d bindings, want %d", len(pdu.VarBinds()), len(bindings))
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "garbage", data: []byte{0x30, 0x03, 0x02, 0x01}},
+		{name: "get request", data: v2Message(t, "public", snmpgo.GetRequest, 1, v2Bindings("1.3.6.1.2.1.33.2.0.1"))},
+		{name: "no trap OID", data: v2Message(t, "public", snmpgo.SNMPTrapV2, 1, snmpgo.VarBinds{
+			snmpgo.NewVarBind(snmpgo.MustNewOid("1.3.6.1.2.1.1.3.0"), snmpgo.NewTimeTicks(1)),
+		})},
+		{name: "invalid trap OID", data: v2Message(t, "public", snmpgo.SNMPTrapV2, 1, snmpgo.VarBinds{
+			snmpgo.NewVarBind(snmpTrapOID, snmpgo.NewInteger(1)),
+		})},
+	}
+
+	for _, test := range tests {
+		if msg, err := decode(test.data); err == nil {
+			t.Errorf("%s: decode returned %v, want an error", test.name, msg.trapOID)
+		}
+	}
+}
+
+// checkEvent verifies the notification OID of msg and the event it
+// describes.
+func checkEvent(t *testing.T, msg message, trapOID, name, text string) {
+	t.Helper()
+	if msg.trapOID == nil || msg.trapOID.String() != trapOID {
+		t.Fatalf("notification OID = %v, want %s", msg.trapOID, trapOID)
+	}
+
+	source := power.Source{Host: "192.0.2.10", Community: "public"}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	e := msg.event(source, now)
+	if e.Name != name || e.Message != text || e.OID != trapOID {
+		t.Errorf("event = %q %q %q, want %q %q %q", e.Name, e.Message, e.OID, name, text, trapOID)
+	}
+	if e.Source != source || !e.Time.Equal(now) {
+		t.Errorf("event has source %v at %v", e.Source, e.Time)
+	}
+}
@@ -0,0 +1,130 @@
+package trap
+
+import "github.com/k-sone/snmpgo"
+
+// notification describes a well-known notification.
+type notification struct {
+	name    string
+	message string
+	detail  func(snmpgo.VarBinds) string // Optional source of additional detail
+}
+
+// Notification object identifier prefixes
+//
+// Tripp Lite notifications are not mapped. Tripp Lite cards also send the
+// standard UPS-MIB traps, and their own TRIPPLITE-PRODUCTS notifications
+// only report alarm table changes whose meaning depends on the alarm table
+// of the card. They are delivered as unrecognized notifications.
+const (
+	upsTraps     = "1.3.6.1.2.1.33.2.0."
+	apcTraps     = "1.3.6.1.4.1.318.0."
+	eatonTraps   = "1.3.6.1.4.1.534.1.11.4.1.0."
+	genericTraps = "1.3.6.1.6.3.1.1.5."
+)
+
+// notifications maps notification OIDs to well-known notifications.
+var notifications = map[string]notification{
+	// UPS-MIB (RFC 1628)
+	upsTraps + "1": {name: "upsTrapOnBattery", message: "UPS is operating on battery power"},
+	upsTraps + "2": {name: "upsTrapTestCompleted", message: "UPS diagnostic test completed"},
+	upsTraps + "3": {name: "upsTrapAlarmEntryAdded", message: "UPS alarm added", detail: alarmDescription},
+	upsTraps + "4": {name: "upsTrapAlarmEntryRemoved", message: "UPS alarm removed", detail: alarmDescription},
+
+	// APC PowerNet-MIB
+	apcTraps + "1":  {name: "communicationLost", message: "communication with the UPS has been lost"},
+	apcTraps + "2":  {name: "upsOverload", message: "UPS load capacity has been exceeded"},
+	apcTraps + "3":  {name: "upsDiagnosticsFailed", message: "UPS failed its internal self-test"},
+	apcTraps + "4":  {name: "upsDischarged", message: "UPS batteries are discharged"},
+	apcTraps + "5":  {name: "upsOnBattery", message: "UPS has switched to battery backup power"},
+	apcTraps + "6":  {name: "smartBoostOn", message: "UPS has enabled SmartBoost"},
+	apcTraps + "7":  {name: "lowBattery", message: "UPS batteries are low"},
+	apcTraps + "8":  {name: "communicationEstablished", message: "communication with the UPS has been established"},
+	apcTraps + "9":  {name: "powerRestored", message: "utility power has been restored"},
+	apcTraps + "10": {name: "upsDiagnosticsPassed", message: "UPS passed its internal self-test"},
+	apcTraps + "11": {name: "returnFromLowBattery", message: "UPS has returned from a low battery condition"},
+	apcTraps + "12": {name: "upsTurnedOff", message: "UPS has been turned off"},
+	apcTraps + "13": {name: "upsSleeping", message: "UPS is entering sleep mode"},
+	apcTraps + "14": {name: "upsWokeUp", message: "UPS has returned from sleep mode"},
+	apcTraps + "15": {name: "upsRebootStarted", message: "UPS has started its reboot sequence"},
+
+	// Eaton XUPS-MIB
+	eatonTraps + "1":  {name: "xupstdControlOff", message: "UPS output has been turned off by command"},
+	eatonTraps + "2":  {name: "xupstdControlOn", message: "UPS output has been turned on by command"},
+	eatonTraps + "3":  {name: "xupstdOnBattery", message: "UPS is operating on battery power"},
+	eatonTraps + "4":  {name: "xupstdLowBattery", message: "UPS batteries are low"},
+	eatonTraps + "5":  {name: "xupstdUtilityPowerRestored", message: "utility power has been restored"},
+	eatonTraps + "6":  {name: "xupstdReturnFromLowBattery", message: "UPS has returned from a low battery condition"},
+	eatonTraps + "7":  {name: "xupstdOutputOverload", message: "UPS load capacity has been exceeded"},
+	eatonTraps + "8":  {name: "xupstdInternalFailure", message: "UPS has detected an internal failure"},
+	eatonTraps + "9":  {name: "xupstdBatteryDischarged", message: "UPS batteries are discharged"},
+	eatonTraps + "10": {name: "xupstdInverterFailure", message: "UPS inverter has failed"},
+	eatonTraps + "11": {name: "xupstdOnBypass", message: "UPS is operating on bypass"},
+	eatonTraps + "12": {name: "xupstdBypassNotAvailable", message: "UPS bypass is not available"},
+	eatonTraps + "13": {name: "xupstdOutputOff", message: "UPS output is off"},
+	eatonTraps + "14": {name: "xupstdInputFailure", message: "UPS input power has failed"},
+	eatonTraps + "15": {name: "xupstdBuildingAlarm", message: "building alarm is active"},
+	eatonTraps + "16": {name: "xupstdShutdownImminent", message: "UPS is about to shut down"},
+
+	// SNMPv2-MIB generic traps
+	genericTraps + "1": {name: "coldStart", message: "agent has been restarted"},
+	genericTraps + "2": {name: "warmStart", message: "agent has been reinitialized"},
+	genericTraps + "5": {name: "authenticationFailure", message: "agent received a request with invalid credentials"},
+}
+
+// lookup returns the well-known notification for oid.
+func lookup(oid *snmpgo.Oid) (notification, bool) {
+	n, ok := notifications[oid.String()]
+	return n, ok
+}
+
+// UPS-MIB alarm table objects
+var (
+	upsAlarmDescr      = snmpgo.MustNewOid("1.3.6.1.2.1.33.1.6.2.1.2")
+	upsWellKnownAlarms = snmpgo.MustNewOid("1.3.6.1.2.1.33.1.6.3")
+)
+
+// wellKnownAlarms are the names of the UPS-MIB well-known alarms, in order.
+var wellKnownAlarms = []string{
+	"batteryBad",
+	"onBattery",
+	"lowBattery",
+	"depletedBattery",
+	"tempBad",
+	"inputBad",
+	"outputBad",
+	"outputOverload",
+	"onBypass",
+	"bypassBad",
+	"outputOffAsRequested",
+	"upsOffAsRequested",
+	"chargerFailed",
+	"upsOutputOff",
+	"upsSystemOff",
+	"fanFailure",
+	"fuseFailure",
+	"generalFault",
+	"diagnosticTestFailed",
+	"communicationsLost",
+	"awaitingPower",
+	"shutdownPending",
+	"shutdownImminent",
+	"testInProgress",
+}
+
+// alarmDescription returns the name of the alarm described by an alarm
+// table notification.
+func alarmDescription(bindings snmpgo.VarBinds) string {
+	for _, binding := range bindings.MatchBaseOids(upsAlarmDescr) {
+		alarm, ok := binding.Variable.(*snmpgo.Oid)
+		if !ok {
+			continue
+		}
+		if upsWellKnownAlarms.Contains(alarm) && len(alarm.Value) == len(upsWellKnownAlarms.Value)+1 {
+			if i := alarm.Value[len(alarm.Value)-1] - 1; i >= 0 && i < len(wellKnownAlarms) {
+				return wellKnownAlarms[i]
+			}
+		}
+		return alarm.String()
+	}
+	return ""
+}
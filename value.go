@@ -10,6 +10,8 @@ import (
 type Value struct {
 	Source Source
 	Stat   Statistic
+	Index  string // Row index for table statistics
+	Label  string // Row label for table statistics, such as an outlet name
 	Time   time.Time
 	Value  float64
 	Err    error
@@ -20,6 +22,9 @@ func (v Value) String() string {
 	if v.Err != nil {
 		return v.Err.Error()
 	}
+	if label, ok := v.Stat.Label(v.Value); ok {
+		return label
+	}
 	return fmt.Sprintf("%s %s", strconv.FormatFloat(v.Value, 'f', -1, 64), v.Stat.Unit)
 }
 
@@ -29,5 +34,18 @@ func (v Value) StatName() string {
 	if v.Source.Name == "" {
 		sname = v.Source.Host
 	}
+	if instance := v.Instance(); instance != "" {
+		return fmt.Sprintf("%s %s %s", sname, v.Stat.Name, instance)
+	}
 	return fmt.Sprintf("%s %s", sname, v.Stat.Name)
 }
+
+// Instance returns the row label of a table statistic value, falling back to
+// the row index if the label is empty. It returns an empty string for values
+// that are not part of a table.
+func (v Value) Instance() string {
+	if v.Label != "" {
+		return v.Label
+	}
+	return v.Index
+}
@@ -0,0 +1,147 @@
+package power
+
+import (
+	"strings"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// vendorOIDs records the vendor of each proprietary OID used by the built-in
+// statistics. It is keyed by pointer so that OIDs provided by users, such as
+// those parsed by ParseStatistic, are never attributed to a vendor.
+var vendorOIDs = make(map[*snmpgo.Oid]*Vendor)
+
+// Vendor describes a family of power devices that share a set of proprietary
+// MIBs.
+//
+// A vendor determines which of a statistic's OIDs are used when a source is
+// queried. The proprietary OIDs of the built-in statistics are only retained
+// for the vendor that defines them, while standard OIDs and the OIDs of
+// custom statistics are always retained.
+type Vendor struct {
+	Name        string
+	Enterprises snmpgo.Oids // Private enterprise subtrees owned by the vendor
+	Keywords    []string    // Case insensitive sysDescr keywords that identify the vendor
+	Model       snmpgo.Oids // Proprietary OIDs that report the device model, in priority order
+}
+
+// Well-known vendor profiles
+var (
+	VendorAPC = Vendor{
+		Name:        "APC",
+		Enterprises: snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.318")},
+		Keywords:    []string{"APC", "Schneider Electric"},
+		Model:       snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.318.1.1.1.1.1.1.0")},
+	}
+	VendorEaton = Vendor{
+		Name: "Eaton",
+		Enterprises: snmpgo.Oids{
+			snmpgo.MustNewOid("1.3.6.1.4.1.534"),
+			snmpgo.MustNewOid("1.3.6.1.4.1.705"),
+		},
+		Keywords: []string{"Eaton", "Powerware", "ConnectUPS"},
+		Model:    snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.534.1.1.2.0")},
+	}
+	VendorTrippLite = Vendor{
+		Name:        "Tripp Lite",
+		Enterprises: snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.850")},
+		Keywords:    []string{"Tripp Lite", "TrippLite", "PowerAlert"},
+	}
+	VendorCyberPower = Vendor{
+		Name:        "CyberPower",
+		Enterprises: snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.3808")},
+		Keywords:    []string{"CyberPower"},
+		Model:       snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.3808.1.1.1.1.1.1.0")},
+	}
+	VendorRaritan = Vendor{
+		Name:        "Raritan",
+		Enterprises: snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.13742")},
+		Keywords:    []string{"Raritan", "Dominion PX"},
+	}
+	VendorServerTech = Vendor{
+		Name:        "Server Technology",
+		Enterprises: snmpgo.Oids{snmpgo.MustNewOid("1.3.6.1.4.1.1718")},
+		Keywords:    []string{"Sentry", "Server Technology"},
+	}
+	VendorGeneric = Vendor{
+		Name: "RFC 1628",
+	}
+)
+
+// vendors is the list of vendors considered during detection, in priority
+// order.
+var vendors = []*Vendor{
+	&VendorAPC,
+	&VendorEaton,
+	&VendorTrippLite,
+	&VendorCyberPower,
+	&VendorRaritan,
+	&VendorServerTech,
+}
+
+// DetectVendor returns the vendor matching the given sysObjectID and sysDescr
+// values. If no vendor matches it returns VendorGeneric.
+func DetectVendor(objectID *snmpgo.Oid, description string) *Vendor {
+	if objectID != nil {
+		for _, vendor := range vendors {
+			if vendor.owns(objectID) {
+				return vendor
+			}
+		}
+	}
+
+	description = strings.ToLower(description)
+	for _, vendor := range vendors {
+		for _, keyword := range vendor.Keywords {
+			if strings.Contains(description, strings.ToLower(keyword)) {
+				return vendor
+			}
+		}
+	}
+
+	return &VendorGeneric
+}
+
+// Resolve returns a copy of stat that only includes the OIDs applicable to
+// the vendor. OIDs defined by the built-in statistics of other vendors are
+// removed. The OIDs retain their original priority order.
+func (v *Vendor) Resolve(stat Statistic) Statistic {
+	resolved := stat
+	resolved.OID = nil
+	resolved.Mappers = nil
+	resolved.LabelOID = nil
+	for i, oid := range stat.OID {
+		if owner, found := vendorOIDs[oid]; found && owner != v {
+			continue
+		}
+		resolved.OID = append(resolved.OID, oid)
+		resolved.Mappers = append(resolved.Mappers, stat.mapper(i))
+		if stat.Table {
+			resolved.LabelOID = append(resolved.LabelOID, stat.labelOID(i))
+		}
+	}
+	return resolved
+}
+
+// String returns the name of the vendor.
+func (v *Vendor) String() string {
+	return v.Name
+}
+
+// oid returns the proprietary OID described by s, which must be valid, and
+// records it as belonging to the vendor.
+func (v *Vendor) oid(s string) *snmpgo.Oid {
+	oid := snmpgo.MustNewOid(s)
+	vendorOIDs[oid] = v
+	return oid
+}
+
+// owns returns true if oid belongs to one of the vendor's enterprises.
+func (v *Vendor) owns(oid *snmpgo.Oid) bool {
+	for _, enterprise := range v.Enterprises {
+		if enterprise.Contains(oid) {
+			return true
+		}
+	}
+	return false
+}
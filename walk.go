@@ -0,0 +1,49 @@
+package power
+
+import (
+	"context"
+
+	"github.com/k-sone/snmpgo"
+)
+
+// DefaultWalkRoot is the root of the subtree walked when none is specified.
+var DefaultWalkRoot = snmpgo.MustNewOid("1.3.6.1")
+
+// Walk retrieves all of the variables within the subtree identified by root
+// from the given source using SNMP GetBulk requests.
+func Walk(ctx context.Context, source Source, root *snmpgo.Oid) (snmpgo.VarBinds, error) {
+	if root == nil {
+		root = DefaultWalkRoot
+	}
+
+	snmp, err := dial(source)
+	if err != nil {
+		return nil, err
+	}
+	defer snmp.Close()
+
+	return walk(ctx, snmp, root)
+}
+
+// MatchStatistics returns the registered statistics that are derived from the
+// given variable binding. A value is returned for each statistic, which has
+// been mapped from the binding's variable.
+func MatchStatistics(binding *snmpgo.VarBind) (values []Value) {
+	for _, stat := range statList {
+		for i, oid := range stat.OID {
+			value := Value{Stat: stat}
+			switch {
+			case stat.Table && oid.Contains(binding.Oid) && !oid.Equal(binding.Oid):
+				value.Index = rowIndex(oid, binding.Oid)
+			case !stat.Table && oid.Equal(binding.Oid):
+			default:
+				continue
+			}
+
+			value.Value, value.Err = stat.mapper(i)(binding.Variable)
+			values = append(values, value)
+			break
+		}
+	}
+	return
+}